@@ -0,0 +1,231 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/utils"
+)
+
+const redacted = "[REDACTED]"
+
+// errorLogInterval 访问日志写入失败时输出错误日志的最小间隔, 避免每个请求都输出一次
+const errorLogInterval = time.Minute
+
+const defaultTemplate = `{{.Time.Format "2006-01-02T15:04:05.000Z07:00"}} {{.RequestID}} {{.ClientIP}} "{{.Method}} {{.Path}}" {{.Status}} {{.BytesIn}} {{.BytesOut}} {{.UpstreamLatencyMs}}ms {{.LatencyMs}}ms service={{.Service}} upstream={{.Upstream}}`
+
+// Logger 访问日志输出器, 为 nil 时表示未启用访问日志
+type Logger struct {
+	template      *template.Template
+	sampleRatio   float64
+	headers       []string
+	redactHeaders []string
+	redactQuery   []string
+
+	mu     sync.Mutex
+	writer io.Writer
+	closer io.Closer
+	// 上次输出错误日志的时间与之后失败的次数
+	lastErrorAt time.Time
+	failures    int
+}
+
+// NewLogger 根据配置创建访问日志输出器, 未启用时返回 nil
+func NewLogger(cfg *config.AccessLogConfig) (*Logger, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	cfg.SetDefault()
+
+	var writer io.Writer
+	switch cfg.Output {
+	case config.AccessLogOutputStdout:
+		writer = os.Stdout
+	case config.AccessLogOutputFile:
+		if cfg.File == "" {
+			return nil, fmt.Errorf("access log file is required when output is file")
+		}
+		file, err := utils.NewRotatingFile(cfg.File, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		writer = file
+	default:
+		return nil, fmt.Errorf("unsupported access log output: %s", cfg.Output)
+	}
+
+	l, err := NewLoggerWithWriter(cfg, writer)
+	if err != nil {
+		if closer, ok := writer.(io.Closer); ok && writer != os.Stdout {
+			closer.Close()
+		}
+		return nil, err
+	}
+	if writer != os.Stdout {
+		l.closer, _ = writer.(io.Closer)
+	}
+	return l, nil
+}
+
+// NewLoggerWithWriter 使用指定输出创建访问日志输出器
+func NewLoggerWithWriter(cfg *config.AccessLogConfig, writer io.Writer) (*Logger, error) {
+	cfg.SetDefault()
+
+	l := &Logger{
+		sampleRatio:   cfg.SampleRatio,
+		headers:       cfg.Headers,
+		redactHeaders: cfg.RedactHeaders,
+		redactQuery:   cfg.RedactQuery,
+		writer:        writer,
+	}
+
+	switch cfg.Format {
+	case config.AccessLogFormatJSON:
+	case config.AccessLogFormatTemplate:
+		text := cfg.Template
+		if text == "" {
+			text = defaultTemplate
+		}
+		tmpl, err := template.New("access_log").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parse access log template error: %w", err)
+		}
+		l.template = tmpl
+	default:
+		return nil, fmt.Errorf("unsupported access log format: %s", cfg.Format)
+	}
+	return l, nil
+}
+
+// NewRecord 创建请求的访问日志记录, 路径中的敏感查询参数与请求头会被脱敏
+func (l *Logger) NewRecord(r *http.Request) *Record {
+	if l == nil {
+		return nil
+	}
+
+	record := &Record{
+		Method: r.Method,
+		Path:   l.redactPath(r.URL),
+	}
+	for _, name := range l.headers {
+		value := r.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		if l.isRedactedHeader(name) {
+			value = redacted
+		}
+		if record.Headers == nil {
+			record.Headers = make(map[string]string)
+		}
+		record.Headers[name] = value
+	}
+	return record
+}
+
+// Log 按采样率输出一条访问日志, 服务配置可以关闭日志或覆盖采样率, 5xx 响应始终输出
+func (l *Logger) Log(record *Record, service *config.ServiceAccessLog) {
+	if l == nil || record == nil {
+		return
+	}
+
+	ratio := l.sampleRatio
+	if service != nil {
+		if service.Disabled {
+			return
+		}
+		if service.SampleRatio > 0 {
+			ratio = service.SampleRatio
+		}
+	}
+	if record.Status < http.StatusInternalServerError && ratio < 1 && rand.Float64() >= ratio {
+		return
+	}
+
+	line, err := l.encode(record)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err == nil {
+		_, err = l.writer.Write(line)
+	}
+	if err != nil {
+		l.reportError(err)
+	}
+}
+
+// reportError 记录写入失败, 首次失败立即输出错误日志, 之后每个间隔最多输出一次
+func (l *Logger) reportError(err error) {
+	l.failures++
+	now := time.Now()
+	if !l.lastErrorAt.IsZero() && now.Sub(l.lastErrorAt) < errorLogInterval {
+		return
+	}
+	logging.Errorf("write access log error: %v, failures: %d", err, l.failures)
+	l.lastErrorAt = now
+	l.failures = 0
+}
+
+// Close 关闭访问日志文件, 输出到标准输出时无需关闭
+func (l *Logger) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closer.Close()
+}
+
+func (l *Logger) encode(record *Record) ([]byte, error) {
+	record.mu.Lock()
+	defer record.mu.Unlock()
+
+	var buf bytes.Buffer
+	if l.template != nil {
+		if err := l.template.Execute(&buf, record); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+
+	line := struct {
+		*Record
+		UpstreamLatencyMs float64 `json:"upstream_latency_ms"`
+		LatencyMs         float64 `json:"latency_ms"`
+	}{record, record.UpstreamLatencyMs(), record.LatencyMs()}
+	if err := json.NewEncoder(&buf).Encode(line); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (l *Logger) isRedactedHeader(name string) bool {
+	for _, header := range l.redactHeaders {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactPath 返回脱敏后的路径与查询参数
+func (l *Logger) redactPath(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	return u.Path + "?" + utils.RedactQuery(u.RawQuery, l.redactQuery, redacted)
+}
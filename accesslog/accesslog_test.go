@@ -0,0 +1,113 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestLogJSONWithRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLoggerWithWriter(&config.AccessLogConfig{
+		Enabled: true,
+		Headers: []string{"User-Agent", "Authorization"},
+	}, &buf)
+	if err != nil {
+		t.Fatalf("new logger error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "http://gw.example.com/__test/api?id=1&token=secret&Access_Token=x", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.Header.Set("Authorization", "Bearer secret")
+	record := logger.NewRecord(req)
+	record.SetRoute("^/api$", "10.0.0.1:80")
+	record.GotConn()
+	record.GotConn()
+	record.Status = 200
+	record.Latency = 12500 * time.Microsecond
+	record.UpstreamLatency = 10 * time.Millisecond
+	logger.Log(record, nil)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode log line error: %v, %s", err, buf.String())
+	}
+	assert.Equal(t, "/__test/api?id=1&token=%5BREDACTED%5D&Access_Token=%5BREDACTED%5D", line["path"])
+	assert.Equal(t, "^/api$", line["route"])
+	assert.Equal(t, "10.0.0.1:80", line["upstream"])
+	assert.Equal(t, float64(1), line["retries"])
+	assert.Equal(t, 12.5, line["latency_ms"])
+	assert.Equal(t, float64(10), line["upstream_latency_ms"])
+	assert.Equal(t, map[string]any{"User-Agent": "curl/8.0", "Authorization": "[REDACTED]"}, line["headers"])
+	if strings.Contains(buf.String(), "secret") {
+		t.Fatalf("log line leaks secret: %s", buf.String())
+	}
+}
+
+func TestLogTemplateAndSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLoggerWithWriter(&config.AccessLogConfig{
+		Enabled:     true,
+		Format:      config.AccessLogFormatTemplate,
+		Template:    `{{.Method}} {{.Path}} {{.Status}} {{.Subject}}`,
+		SampleRatio: 0.000001,
+	}, &buf)
+	if err != nil {
+		t.Fatalf("new logger error: %v", err)
+	}
+
+	record := logger.NewRecord(httptest.NewRequest("POST", "/__test/orders", nil))
+	record.Status = 200
+	record.Subject = "alice"
+
+	// 服务级采样率覆盖网关配置
+	logger.Log(record, &config.ServiceAccessLog{SampleRatio: 1})
+	assert.Equal(t, "POST /__test/orders 200 alice\n", buf.String())
+
+	// 服务关闭访问日志
+	buf.Reset()
+	logger.Log(record, &config.ServiceAccessLog{Disabled: true, SampleRatio: 1})
+	assert.Equal(t, "", buf.String())
+
+	// 5xx 响应不受采样影响
+	record.Status = 502
+	logger.Log(record, nil)
+	assert.Equal(t, "POST /__test/orders 502 alice\n", buf.String())
+
+	var disabled *Logger
+	assert.Equal(t, (*Record)(nil), disabled.NewRecord(httptest.NewRequest("GET", "/", nil)))
+}
+
+func TestLogFileCloseAndWriteErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "access.log")
+	logger, err := NewLogger(&config.AccessLogConfig{Enabled: true, Output: config.AccessLogOutputFile, File: file})
+	if err != nil {
+		t.Fatalf("new logger error: %v", err)
+	}
+
+	record := logger.NewRecord(httptest.NewRequest("GET", "http://gw.example.com/__test/api", nil))
+	record.Status = 200
+	logger.Log(record, nil)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("close logger error: %v", err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read log file error: %v", err)
+	}
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+
+	// 关闭后写入失败, 首次失败立即输出错误日志, 间隔内的失败只计数
+	logger.Log(record, nil)
+	logger.Log(record, nil)
+	assert.Equal(t, false, logger.lastErrorAt.IsZero())
+	assert.Equal(t, 1, logger.failures)
+}
@@ -0,0 +1,98 @@
+package accesslog
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Record 一个请求的访问日志记录, 由中间件创建, 路由与代理在处理过程中补充字段
+type Record struct {
+	Time            time.Time         `json:"time"`
+	RequestID       string            `json:"request_id"`
+	TraceID         string            `json:"trace_id,omitempty"`
+	ClientIP        string            `json:"client_ip"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Service         string            `json:"service"`
+	Route           string            `json:"route"`
+	Upstream        string            `json:"upstream"`
+	Status          int               `json:"status"`
+	BytesIn         int64             `json:"bytes_in"`
+	BytesOut        int64             `json:"bytes_out"`
+	UpstreamLatency time.Duration     `json:"-"`
+	Latency         time.Duration     `json:"-"`
+	Retries         int               `json:"retries"`
+	Subject         string            `json:"subject,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+
+	mu          sync.Mutex
+	connections int
+}
+
+// SetRoute 记录匹配的路由与上游地址
+func (r *Record) SetRoute(route, upstream string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Route = route
+	r.Upstream = upstream
+}
+
+// GotConn 记录一次上游连接的获取, 连接池在复用的连接失效后会重新获取连接并重试
+func (r *Record) GotConn() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connections++
+	r.Retries = max(r.connections-1, 0)
+}
+
+// GotFirstResponseByte 记录从请求开始到收到上游首字节的耗时
+func (r *Record) GotFirstResponseByte(start time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.UpstreamLatency = time.Since(start)
+}
+
+// LatencyMs 总耗时, 单位毫秒, 可在模板中使用
+func (r *Record) LatencyMs() float64 {
+	return float64(r.Latency.Microseconds()) / 1000
+}
+
+// UpstreamLatencyMs 上游首字节耗时, 单位毫秒, 可在模板中使用
+func (r *Record) UpstreamLatencyMs() float64 {
+	return float64(r.UpstreamLatency.Microseconds()) / 1000
+}
+
+type recordKey struct{}
+
+func WithRecord(ctx context.Context, record *Record) context.Context {
+	return context.WithValue(ctx, recordKey{}, record)
+}
+
+// FromContext 返回请求的访问日志记录, 未启用访问日志时返回 nil
+func FromContext(ctx context.Context) *Record {
+	record, _ := ctx.Value(recordKey{}).(*Record)
+	return record
+}
+
+// CountingReader 统计请求体实际读取的字节数
+type CountingReader struct {
+	io.ReadCloser
+	N int64
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.N += int64(n)
+	return n, err
+}
@@ -0,0 +1,176 @@
+package action
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/requestid"
+)
+
+const (
+	TypeDirectResponse = "direct_response"
+	TypeRedirect       = "redirect"
+	TypeMock           = "mock"
+)
+
+// Data 模板变量
+type Data struct {
+	Service  string
+	Method   string
+	Path     string // 去除服务前缀后的请求路径
+	RawQuery string
+	Query    url.Values
+	Params   map[string]string // 路由中的路径捕获
+}
+
+// Type 返回路由配置的响应方式, 未配置时返回空字符串表示转发上游
+func Type(upstream *config.Upstream) string {
+	switch {
+	case upstream.DirectResponse != nil:
+		return TypeDirectResponse
+	case upstream.Redirect != nil:
+		return TypeRedirect
+	case upstream.Mock != nil:
+		return TypeMock
+	}
+	return ""
+}
+
+// Serve 按路由配置由网关直接响应请求
+func Serve(w http.ResponseWriter, r *http.Request, upstream *config.Upstream) {
+	data := &Data{
+		Service:  upstream.Service,
+		Method:   r.Method,
+		Path:     upstream.TargetPath,
+		RawQuery: r.URL.RawQuery,
+		Query:    r.URL.Query(),
+		Params:   upstream.PathParams,
+	}
+
+	var err error
+	switch Type(upstream) {
+	case TypeDirectResponse:
+		err = serveDirectResponse(w, upstream.DirectResponse, upstream.ConfigDir, data)
+	case TypeRedirect:
+		err = serveRedirect(w, upstream.Redirect, data)
+	case TypeMock:
+		err = serveMock(w, r, upstream.Mock, upstream.ConfigDir, data)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func serveDirectResponse(w http.ResponseWriter, cfg *config.DirectResponse, configDir string, data *Data) error {
+	templates, err := cfg.Templates(configDir)
+	if err != nil {
+		return err
+	}
+	body, err := templates.Body.Render(data)
+	if err != nil {
+		return err
+	}
+	headers, err := renderHeaders(templates.Headers, data)
+	if err != nil {
+		return err
+	}
+
+	writeResponse(w, cfg.Status, headers, body)
+	return nil
+}
+
+func serveRedirect(w http.ResponseWriter, cfg *config.Redirect, data *Data) error {
+	tmpl, err := cfg.LocationTemplate()
+	if err != nil {
+		return err
+	}
+	location, err := tmpl.Render(data)
+	if err != nil {
+		return err
+	}
+
+	status := cfg.Status
+	if status == 0 {
+		status = http.StatusFound
+	}
+	w.Header().Set("Location", string(location))
+	w.WriteHeader(status)
+	return nil
+}
+
+func serveMock(w http.ResponseWriter, r *http.Request, cfg *config.Mock, configDir string, data *Data) error {
+	templates, err := cfg.Templates()
+	if err != nil {
+		return err
+	}
+
+	file := data.Service + data.Path + ".json"
+	if templates.File != nil {
+		rendered, err := templates.File.Render(data)
+		if err != nil {
+			return err
+		}
+		file = string(rendered)
+	}
+
+	body, err := readMock(configDir, file)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, "mock not found")
+		return nil
+	}
+
+	headers, err := renderHeaders(templates.Headers, data)
+	if err != nil {
+		return err
+	}
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	writeResponse(w, cfg.Status, headers, body)
+	return nil
+}
+
+// readMock 样例路径可能包含路径捕获, 通过 os.Root 读取防止访问 mocks 目录之外的文件
+func readMock(configDir, file string) ([]byte, error) {
+	root, err := os.OpenRoot(filepath.Join(configDir, config.MockDir))
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.ReadFile(filepath.Clean(strings.TrimPrefix(file, "/")))
+}
+
+func renderHeaders(headers config.HeaderTemplates, data *Data) (http.Header, error) {
+	rendered := make(http.Header, len(headers))
+	for name, tmpl := range headers {
+		b, err := tmpl.Render(data)
+		if err != nil {
+			return nil, err
+		}
+		rendered.Set(name, string(b))
+	}
+	return rendered, nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, headers http.Header, body []byte) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	for name, values := range headers {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	b, _ := json.Marshal(requestid.ErrorRet(r.Context(), status, msg))
+	w.Write(b)
+}
@@ -0,0 +1,104 @@
+package action
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func serve(upstream *config.Upstream, target string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	Serve(rr, httptest.NewRequest(http.MethodGet, target, nil), upstream)
+	return rr
+}
+
+func TestServe_DirectResponse(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "user.tmpl"), []byte(`{"id": "{{.Params.id}}"}`), 0644); err != nil {
+		t.Fatalf("write body file error: %v", err)
+	}
+
+	upstream := &config.Upstream{
+		Service:    "user",
+		TargetPath: "/api/users/42",
+		PathParams: map[string]string{"id": "42"},
+		ConfigDir:  dir,
+		DirectResponse: &config.DirectResponse{
+			Status:  http.StatusCreated,
+			Headers: map[string]string{"X-User-Id": "{{.Params.id}}"},
+			Body:    `hello {{.Params.id}} {{.Query.Get "lang"}}`,
+		},
+	}
+	assert.Equal(t, TypeDirectResponse, Type(upstream))
+
+	rr := serve(upstream, "/api/users/42?lang=zh")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "42", rr.Header().Get("X-User-Id"))
+	assert.Equal(t, "hello 42 zh", rr.Body.String())
+
+	upstream.DirectResponse = &config.DirectResponse{BodyFile: "user.tmpl"}
+	rr = serve(upstream, "/api/users/42")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `{"id": "42"}`, rr.Body.String())
+}
+
+func TestServe_Redirect(t *testing.T) {
+	upstream := &config.Upstream{
+		PathParams: map[string]string{"id": "42"},
+		Redirect:   &config.Redirect{Status: http.StatusPermanentRedirect, Location: "https://new.example.com/users/{{.Params.id}}?{{.RawQuery}}"},
+	}
+	assert.Equal(t, TypeRedirect, Type(upstream))
+
+	rr := serve(upstream, "/api/users/42?lang=zh")
+	assert.Equal(t, http.StatusPermanentRedirect, rr.Code)
+	assert.Equal(t, "https://new.example.com/users/42?lang=zh", rr.Header().Get("Location"))
+
+	upstream.Redirect.Status = 0
+	assert.Equal(t, http.StatusFound, serve(upstream, "/api/users/42").Code)
+}
+
+func TestServe_Mock(t *testing.T) {
+	dir := t.TempDir()
+	mockDir := filepath.Join(dir, config.MockDir, "user", "api", "users")
+	if err := os.MkdirAll(mockDir, 0755); err != nil {
+		t.Fatalf("create mock dir error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(mockDir, "1.json"), []byte(`{"id": 1}`), 0644); err != nil {
+		t.Fatalf("write mock error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.json"), []byte(`{"secret": true}`), 0644); err != nil {
+		t.Fatalf("write secret error: %v", err)
+	}
+
+	// 默认按请求路径查找样例
+	upstream := &config.Upstream{
+		Service:    "user",
+		TargetPath: "/api/users/1",
+		ConfigDir:  dir,
+		Mock:       &config.Mock{},
+	}
+	assert.Equal(t, TypeMock, Type(upstream))
+
+	rr := serve(upstream, "/api/users/1")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `{"id": 1}`, rr.Body.String())
+
+	upstream.TargetPath = "/api/users/2"
+	assert.Equal(t, http.StatusNotFound, serve(upstream, "/api/users/2").Code)
+
+	// 模板指定样例文件, 路径捕获不能访问 mocks 目录之外的文件
+	upstream.Mock = &config.Mock{File: "user/api/users/{{.Params.id}}.json", Status: http.StatusAccepted}
+	upstream.PathParams = map[string]string{"id": "1"}
+	rr = serve(upstream, "/api/users/1")
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, `{"id": 1}`, rr.Body.String())
+
+	upstream.PathParams = map[string]string{"id": "../../../../secret"}
+	assert.Equal(t, http.StatusNotFound, serve(upstream, "/api/users/x").Code)
+}
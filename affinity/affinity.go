@@ -0,0 +1,206 @@
+package affinity
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/clientip"
+	"github.com/superwhys/litegate/config"
+)
+
+const (
+	defaultCookie        = "litegate_affinity"
+	defaultClaim         = "sub"
+	defaultEjectDuration = 10 * time.Second
+)
+
+// Manager 为会话保持的请求选择固定的上游地址
+// 上游连接失败时地址被暂停分配一段时间, 固定到该地址的客户端会被重新分配
+type Manager struct {
+	key       []byte
+	randomKey bool
+	warnOnce  sync.Once
+	ejected   sync.Map // address -> 恢复分配的时间 unix nano
+}
+
+// NewManager 创建会话保持管理器, 未配置密钥时随机生成, 重启后已签发的 Cookie 失效并重新分配
+func NewManager(cfg *config.AffinityConfig) *Manager {
+	m := &Manager{}
+	if cfg != nil && cfg.Secret != "" {
+		m.key = []byte(cfg.Secret)
+	} else {
+		m.key = make([]byte, 32)
+		rand.Read(m.key)
+		m.randomKey = true
+	}
+	return m
+}
+
+// Pick 在代理地址中为请求选择上游
+// cookie: 沿用 Cookie 中仍然可用的地址, 否则随机选择并签发新的 Cookie
+// claim: 对声明取值做一致性哈希, 地址增减或不可用时只有固定到该地址的客户端会被重新分配
+// 同一服务下不同路由的代理地址可能不同, Cookie 名称与签名都包含路由, 各路由的 Cookie 互不覆盖
+func (m *Manager) Pick(w http.ResponseWriter, r *http.Request, service, route string, cfg *config.Affinity, addresses config.ProxyConfig) string {
+	if m == nil || cfg == nil || len(addresses) == 0 {
+		return addresses.PickAddress()
+	}
+
+	healthy := m.healthy(addresses)
+	if cfg.Type == config.AffinityClaim {
+		claim := cfg.Claim
+		if claim == "" {
+			claim = defaultClaim
+		}
+		key, _ := auth.ClaimsFromContext(r.Context()).String(claim)
+		if key == "" {
+			key = clientip.FromRequest(r)
+		}
+		return rendezvous(healthy, service+"|"+key)
+	}
+
+	name := cfg.Cookie
+	if name == "" {
+		name = defaultCookie
+	}
+	name += "_" + routeID(route)
+	if cookie, err := r.Cookie(name); err == nil {
+		if id, ok := m.verify(service, route, cookie.Value); ok {
+			for _, address := range healthy {
+				if addressID(address) == id {
+					return address
+				}
+			}
+		}
+	}
+
+	if m.randomKey {
+		m.warnOnce.Do(func() {
+			logging.Warnf("affinity secret is not configured, using a random key: cookies are not accepted by other instances or after restart")
+		})
+	}
+
+	address := healthy.PickAddress()
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    m.sign(service, route, addressID(address)),
+		Path:     "/__" + service,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if ttl, err := time.ParseDuration(cfg.TTL); err == nil && ttl > 0 {
+		cookie.MaxAge = int(ttl.Seconds())
+	}
+	http.SetCookie(w, cookie)
+	return address
+}
+
+// Eject 上游连接失败后暂停分配该地址
+func (m *Manager) Eject(address string, cfg *config.Affinity) {
+	if m == nil || cfg == nil {
+		return
+	}
+	duration := defaultEjectDuration
+	if d, err := time.ParseDuration(cfg.EjectDuration); err == nil && d > 0 {
+		duration = d
+	}
+	m.ejected.Store(address, time.Now().Add(duration).UnixNano())
+}
+
+// Healthy 地址是否可以被分配
+func (m *Manager) Healthy(address string) bool {
+	until, ok := m.ejected.Load(address)
+	if !ok {
+		return true
+	}
+	if time.Now().UnixNano() < until.(int64) {
+		return false
+	}
+	m.ejected.Delete(address)
+	return true
+}
+
+// healthy 返回可分配的地址, 全部不可用时返回全部地址
+func (m *Manager) healthy(addresses config.ProxyConfig) config.ProxyConfig {
+	healthy := make(config.ProxyConfig, 0, len(addresses))
+	for _, address := range addresses {
+		if m.Healthy(address) {
+			healthy = append(healthy, address)
+		}
+	}
+	if len(healthy) == 0 {
+		return addresses
+	}
+	return healthy
+}
+
+// sign Cookie 值为 地址标识.签名, 签名包含服务与路由, 不能用于其他服务或路由
+func (m *Manager) sign(service, route, id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(m.mac(service, route, id))
+}
+
+func (m *Manager) verify(service, route, value string) (string, bool) {
+	id, signature, ok := strings.Cut(value, ".")
+	if !ok {
+		return "", false
+	}
+	expected, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, m.mac(service, route, id)) {
+		return "", false
+	}
+	return id, true
+}
+
+func (m *Manager) mac(service, route, id string) []byte {
+	h := hmac.New(sha256.New, m.key)
+	h.Write([]byte(service + "|" + route + "|" + id))
+	return h.Sum(nil)
+}
+
+// routeID 路由的标识, 路由匹配规则可能包含 Cookie 名称不允许的字符
+func routeID(route string) string {
+	sum := sha256.Sum256([]byte(route))
+	return hex.EncodeToString(sum[:4])
+}
+
+// addressID 地址的标识, 避免在 Cookie 中暴露上游地址
+func addressID(address string) string {
+	sum := sha256.Sum256([]byte(address))
+	return hex.EncodeToString(sum[:8])
+}
+
+// rendezvous 最高随机权重哈希, 选择与 key 组合后哈希值最大的地址
+func rendezvous(addresses config.ProxyConfig, key string) string {
+	var (
+		best      string
+		bestScore uint64
+	)
+	for _, address := range addresses {
+		h := fnv.New64a()
+		h.Write([]byte(key + "|" + address))
+		if score := mix(h.Sum64()); best == "" || score > bestScore {
+			best, bestScore = address, score
+		}
+	}
+	return best
+}
+
+// mix splitmix64 的终结函数, 打散只有末尾不同的输入的哈希值
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
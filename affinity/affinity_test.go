@@ -0,0 +1,159 @@
+package affinity
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/config"
+)
+
+var addresses = config.ProxyConfig{"http://backend-1:8080", "http://backend-2:8080", "http://backend-3:8080"}
+
+// pickWithCookie 携带 cookie 请求, 返回选中的地址与响应中新签发的 cookie
+func pickWithCookie(m *Manager, cfg *config.Affinity, addresses config.ProxyConfig, cookie *http.Cookie) (string, *http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "/__test/api", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	address := m.Pick(rr, req, "test", "/api", cfg, addresses)
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 {
+		return address, nil
+	}
+	return address, cookies[0]
+}
+
+func TestPick_Cookie(t *testing.T) {
+	m := NewManager(&config.AffinityConfig{Secret: "secret"})
+	cfg := &config.Affinity{TTL: "1h"}
+
+	pinned, cookie := pickWithCookie(m, cfg, addresses, nil)
+	if cookie == nil {
+		t.Fatalf("expected affinity cookie")
+	}
+	assert.Equal(t, defaultCookie+"_"+routeID("/api"), cookie.Name)
+	assert.Equal(t, "/__test", cookie.Path)
+	assert.Equal(t, 3600, cookie.MaxAge)
+	if strings.Contains(cookie.Value, "backend") {
+		t.Fatalf("cookie should not expose upstream address: %s", cookie.Value)
+	}
+
+	for i := 0; i < 10; i++ {
+		address, issued := pickWithCookie(m, cfg, addresses, cookie)
+		assert.Equal(t, pinned, address)
+		if issued != nil {
+			t.Fatalf("valid cookie should not be reissued")
+		}
+	}
+
+	// 其他密钥签发的 cookie 无效
+	other := NewManager(&config.AffinityConfig{Secret: "other"})
+	if _, issued := pickWithCookie(other, cfg, addresses, cookie); issued == nil {
+		t.Fatalf("cookie signed by another key should be reissued")
+	}
+
+	// 替换地址标识后签名不匹配
+	for _, address := range addresses {
+		if address != pinned {
+			_, signature, _ := strings.Cut(cookie.Value, ".")
+			tampered := &http.Cookie{Name: cookie.Name, Value: addressID(address) + "." + signature}
+			if _, issued := pickWithCookie(m, cfg, addresses, tampered); issued == nil {
+				t.Fatalf("tampered cookie should be reissued")
+			}
+			break
+		}
+	}
+}
+
+func TestPick_CookieRoutes(t *testing.T) {
+	m := NewManager(&config.AffinityConfig{Secret: "secret"})
+	cfg := &config.Affinity{}
+	_, cookie := pickWithCookie(m, cfg, addresses, nil)
+
+	// 其他路由使用不同的 Cookie 名称, 且本路由签发的值不能用于其他路由
+	req := httptest.NewRequest(http.MethodGet, "/__test/other", nil)
+	req.AddCookie(&http.Cookie{Name: defaultCookie + "_" + routeID("/other"), Value: cookie.Value})
+	rr := httptest.NewRecorder()
+	m.Pick(rr, req, "test", "/other", cfg, addresses)
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("cookie of another route should be reissued")
+	}
+	if cookies[0].Name == cookie.Name {
+		t.Fatalf("routes should use different cookie names")
+	}
+}
+
+func TestPick_CookieRepin(t *testing.T) {
+	m := NewManager(&config.AffinityConfig{Secret: "secret"})
+	cfg := &config.Affinity{}
+
+	pinned, cookie := pickWithCookie(m, cfg, addresses, nil)
+
+	// 固定的地址连接失败后重新分配
+	m.Eject(pinned, cfg)
+	address, issued := pickWithCookie(m, cfg, addresses, cookie)
+	if address == pinned || issued == nil {
+		t.Fatalf("expected repin after eject, got %s", address)
+	}
+
+	// 固定的地址从配置中移除后重新分配
+	var remaining config.ProxyConfig
+	for _, a := range addresses {
+		if a != address {
+			remaining = append(remaining, a)
+		}
+	}
+	repinned, issued := pickWithCookie(m, cfg, remaining, issued)
+	if repinned == address || issued == nil {
+		t.Fatalf("expected repin after removal, got %s", repinned)
+	}
+}
+
+func TestPick_Claim(t *testing.T) {
+	m := NewManager(nil)
+	cfg := &config.Affinity{Type: config.AffinityClaim}
+
+	pick := func(user string, addresses config.ProxyConfig) string {
+		req := httptest.NewRequest(http.MethodGet, "/__test/api", nil)
+		req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": user}))
+		rr := httptest.NewRecorder()
+		address := m.Pick(rr, req, "test", "/api", cfg, addresses)
+		assert.Equal(t, 0, len(rr.Result().Cookies()))
+		return address
+	}
+
+	counts := make(map[string]int)
+	assigned := make(map[string]string)
+	for i := 0; i < 900; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		address := pick(user, addresses)
+		assert.Equal(t, address, pick(user, addresses))
+		counts[address]++
+		assigned[user] = address
+	}
+	for _, address := range addresses {
+		if counts[address] < 200 {
+			t.Fatalf("unbalanced assignment: %v", counts)
+		}
+	}
+
+	// 只有固定到不可用地址的用户被重新分配
+	m.Eject(addresses[0], cfg)
+	for user, address := range assigned {
+		repinned := pick(user, addresses)
+		if address != addresses[0] && repinned != address {
+			t.Fatalf("user %s moved from %s to %s", user, address, repinned)
+		}
+		if repinned == addresses[0] {
+			t.Fatalf("user %s pinned to ejected address", user)
+		}
+	}
+}
@@ -11,29 +11,95 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"time"
 
-	"github.com/miebyte/goutils/ginutils"
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/accesslog"
+	"github.com/superwhys/litegate/affinity"
 	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/clientip"
 	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/cors"
+	"github.com/superwhys/litegate/metrics"
+	"github.com/superwhys/litegate/mirror"
+	"github.com/superwhys/litegate/requestid"
+	"github.com/superwhys/litegate/tracing"
 )
 
 type Agent interface {
 	http.Handler
-	Auth(w http.ResponseWriter, r *http.Request)
+	// Auth 验证请求身份并评估授权规则, 失败时写入响应并返回 false
+	Auth(w http.ResponseWriter, r *http.Request) bool
 }
 
 type agent struct {
 	proxy         *httputil.ReverseProxy
+	service       string
 	auth          *config.Auth
+	authorize     []config.AuthorizeRule
+	pathParams    map[string]string
+	stripClaims   []string
+	stripHeaders  []string
 	timeout       time.Duration
 	authenticator auth.Authenticator
+	tokenIssuer   *auth.TokenIssuer
+	revocation    *auth.RevocationList
+	resolver      *clientip.Resolver
+	forwarded     *config.ForwardedConfig
+	cors          *config.CORSPolicy
+	security      *config.SecurityHeaders
+	upstreamAddr  string
+	target        *url.URL
+	requestID     string
+	mirrors       *mirror.Manager
+	affinity      *affinity.Manager
+	upstreamConf  *config.Upstream
 }
 
-func modifyResponse(resp *http.Response) error {
+type Option func(a *agent)
+
+// WithRevocationList 设置身份验证时检查的令牌吊销列表
+func WithRevocationList(revocation *auth.RevocationList) Option {
+	return func(a *agent) {
+		a.revocation = revocation
+	}
+}
+
+// WithClientIPResolver 设置判断直连对端是否为受信任代理的解析器
+func WithClientIPResolver(resolver *clientip.Resolver) Option {
+	return func(a *agent) {
+		a.resolver = resolver
+	}
+}
+
+// WithMirrorManager 设置发送镜像请求的管理器
+func WithMirrorManager(mirrors *mirror.Manager) Option {
+	return func(a *agent) {
+		a.mirrors = mirrors
+	}
+}
+
+// WithAffinityManager 设置会话保持管理器, 上游连接失败时暂停分配该上游
+func WithAffinityManager(affinity *affinity.Manager) Option {
+	return func(a *agent) {
+		a.affinity = affinity
+	}
+}
+
+// WithTokenIssuer 设置签发上游内部身份令牌使用的签发者
+func WithTokenIssuer(issuer *auth.TokenIssuer) Option {
+	return func(a *agent) {
+		a.tokenIssuer = issuer
+	}
+}
+
+func (a *agent) modifyResponse(resp *http.Response) error {
 	// 移除不必要的响应头
 	resp.Header.Del("Server")
 	resp.Header.Del("X-Powered-By")
@@ -41,6 +107,25 @@ func modifyResponse(resp *http.Response) error {
 	resp.Header.Del("Connection")
 	resp.Header.Del("Content-Length")
 
+	// 跨域响应头由网关根据策略生成
+	if a.cors != nil {
+		for _, header := range cors.ResponseHeaders {
+			resp.Header.Del(header)
+		}
+	}
+	applySecurityHeaders(resp.Header, a.security)
+
+	// 网关已在响应头中写入请求ID, 移除上游返回的同名响应头避免重复
+	if a.requestID != "" && requestid.FromContext(resp.Request.Context()) != "" {
+		resp.Header.Del(a.requestID)
+	}
+
+	span := tracing.SpanFromContext(resp.Request.Context())
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(tracing.StatusError, resp.Status)
+	}
+
 	return nil
 }
 
@@ -60,7 +145,38 @@ func director(target *url.URL, upstreamConf *config.Upstream) func(req *http.Req
 	}
 }
 
-func NewAgent(upstreamConf *config.Upstream, gatewayConf *config.GatewayConfig) (*agent, error) {
+// errorHandler 上游请求失败时返回网关错误, 会话保持的路由同时暂停分配该上游
+func (a *agent) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	tracing.SpanFromContext(r.Context()).RecordError(err)
+
+	// 流式读取请求体时超出大小限制
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		a.affinity.Eject(a.upstreamConf.UpstreamURL, a.upstreamConf.Affinity)
+	}
+
+	// 先写入状态码, 并发限制等依赖响应状态判断上游是否失败
+	status := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	writeError(w, r, status, "服务器繁忙")
+}
+
+// writeError 写入带有请求ID的 JSON 错误响应
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	b, _ := json.Marshal(requestid.ErrorRet(r.Context(), status, message))
+	w.Write(b)
+}
+
+func NewAgent(upstreamConf *config.Upstream, gatewayConf *config.GatewayConfig, opts ...Option) (*agent, error) {
 	target, err := url.Parse(upstreamConf.UpstreamURL)
 	if err != nil {
 		return nil, err
@@ -68,49 +184,132 @@ func NewAgent(upstreamConf *config.Upstream, gatewayConf *config.GatewayConfig)
 
 	proxy := httputil.NewSingleHostReverseProxy(target)
 
-	proxy.Director = director(target, upstreamConf)
-	proxy.Transport = customTransport(gatewayConf.Transport)
+	proxy.Transport = sharedTransport(gatewayConf.Transport)
 	proxy.BufferPool = newBufferPool(gatewayConf.Transport.BufferSize)
 	proxy.FlushInterval = gatewayConf.Transport.FlushInterval
-	proxy.ModifyResponse = modifyResponse
 
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		ret := ginutils.ErrorRet(http.StatusServiceUnavailable, "服务器繁忙")
-		b, _ := json.Marshal(ret)
-		w.Write(b)
-		w.WriteHeader(http.StatusOK)
+	a := &agent{
+		proxy:        proxy,
+		service:      upstreamConf.Service,
+		auth:         upstreamConf.Auth,
+		authorize:    upstreamConf.Authorize,
+		pathParams:   upstreamConf.PathParams,
+		stripClaims:  upstreamConf.StripClaims,
+		stripHeaders: upstreamConf.StripHeaders,
+		timeout:      upstreamConf.Timeout,
+		forwarded:    gatewayConf.Forwarded,
+		cors:         upstreamConf.CORS,
+		security:     upstreamConf.SecurityHeaders,
+		upstreamAddr: metrics.UpstreamAddr(upstreamConf.UpstreamURL),
+		upstreamConf: upstreamConf,
+		target:       target,
+	}
+	if gatewayConf.RequestID != nil {
+		a.requestID = gatewayConf.RequestID.Header
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	proxy.ModifyResponse = a.modifyResponse
+	proxy.ErrorHandler = a.errorHandler
 
-	authenticator, err := auth.NewAuthenticator(upstreamConf.Auth)
+	rewrite := director(target, upstreamConf)
+	proxy.Director = func(req *http.Request) {
+		rewrite(req)
+		a.forwardHeaders(req)
+		tracing.Inject(req.Context(), req.Header)
+		// 镜像与上游收到的请求一致
+		a.mirrors.Mirror(req, a.upstreamConf)
+	}
+
+	a.authenticator, err = auth.NewAuthenticator(upstreamConf.Auth, a.revocation)
 	if err != nil {
 		return nil, err
 	}
 
-	return &agent{
-		proxy:         proxy,
-		auth:          upstreamConf.Auth,
-		timeout:       upstreamConf.Timeout,
-		authenticator: authenticator,
-	}, nil
+	return a, nil
+}
+
+// SetUpstream 更换转发的上游地址, 用于身份验证之后才能确定上游的分流
+func (a *agent) SetUpstream(upstreamURL string) error {
+	target, err := url.Parse(upstreamURL)
+	if err != nil {
+		return err
+	}
+	*a.target = *target
+	a.upstreamConf.UpstreamURL = upstreamURL
+	a.upstreamAddr = metrics.UpstreamAddr(upstreamURL)
+	return nil
 }
 
-func (a *agent) Auth(w http.ResponseWriter, r *http.Request) {
+func (a *agent) Auth(w http.ResponseWriter, r *http.Request) bool {
 	if a.authenticator == nil {
-		return
+		// 授权规则依赖身份验证得到的声明, 未启用身份验证时拒绝请求而不是跳过授权
+		if len(a.authorize) > 0 {
+			metrics.AuthRejections.Inc(a.service, "forbidden")
+			writeError(w, r, http.StatusForbidden, "permission denied")
+			return false
+		}
+		return true
 	}
 
+	*r = *r.WithContext(auth.WithPathParams(r.Context(), a.pathParams))
 	claims, err := a.authenticator.Parse(r)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		// 从请求体读取令牌时超出大小限制, 与转发时的处理一致
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body too large")
+		return false
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
+		metrics.AuthRejections.Inc(a.service, auth.RejectReason(err))
+		writeError(w, r, http.StatusUnauthorized, err.Error())
+		return false
+	}
+
+	if len(a.authorize) > 0 {
+		decision := auth.Authorize(a.authorize, claims, a.pathParams)
+		logging.Debugc(r.Context(), "authorize decision: %s", logging.JsonifyNoIndent(decision))
+		if !decision.Allowed {
+			metrics.AuthRejections.Inc(a.service, "forbidden")
+			writeError(w, r, http.StatusForbidden, "permission denied")
+			return false
+		}
 	}
 
-	*r = *r.WithContext(auth.InjectClaimsToContext(r, claims))
+	ctx := auth.InjectClaimsToContext(r, a.auth, claims)
+	ctx = auth.WithClaims(ctx, claims)
+	if a.auth.UpstreamToken != nil {
+		if a.tokenIssuer == nil {
+			writeError(w, r, http.StatusInternalServerError, "token issuer not configured")
+			return false
+		}
+		token, err := a.tokenIssuer.Mint(a.auth.UpstreamToken, a.service, claims)
+		if err != nil {
+			logging.Errorf("mint upstream token error: %v", err)
+			metrics.AuthRejections.Inc(a.service, "upstream_token")
+			writeError(w, r, http.StatusInternalServerError, "mint upstream token failed")
+			return false
+		}
+		ctx = auth.WithUpstreamToken(ctx, token)
+	}
+
+	*r = *r.WithContext(ctx)
+	return true
 }
 
 func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth.StripClaimsFromRequest(r, a.stripClaims)
+	for _, header := range a.stripHeaders {
+		r.Header.Del(header)
+	}
+
 	if a.auth != nil {
 		auth.InjectClaimsToRequest(r, a.auth)
+		if a.auth.UpstreamToken != nil {
+			auth.InjectUpstreamTokenToRequest(r, a.auth.UpstreamToken)
+		}
 	}
 
 	timeout := a.timeout
@@ -120,6 +319,21 @@ func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
+	record := accesslog.FromContext(ctx)
+	start := time.Now()
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.UpstreamConnectionsAcquired.Inc(a.upstreamAddr, strconv.FormatBool(info.Reused))
+			record.GotConn()
+		},
+		GotFirstResponseByte: func() {
+			record.GotFirstResponseByte(start)
+		},
+	})
+	ctx, span := tracing.Start(ctx, "upstream", tracing.SpanKindClient)
+	defer span.End()
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("server.address", a.upstreamAddr)
 	r = r.WithContext(ctx)
 
 	a.proxy.ServeHTTP(w, r)
@@ -6,13 +6,20 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/assert/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/superwhys/litegate/affinity"
 	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/clientip"
 	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/metrics"
+	"github.com/superwhys/litegate/requestid"
+	"github.com/superwhys/litegate/tracing"
 )
 
 var (
@@ -138,7 +145,7 @@ func TestServeHTTP_InjectsAuthData(t *testing.T) {
 	assert.Equal(t, []any{"42"}, respBody["Query"].(map[string]any)["user_id"])
 }
 
-func TestServeHTTP_TimeoutReturnsBadGateway(t *testing.T) {
+func TestServeHTTP_TimeoutReturnsGatewayTimeout(t *testing.T) {
 	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(200 * time.Millisecond)
 		w.WriteHeader(http.StatusOK)
@@ -160,7 +167,7 @@ func TestServeHTTP_TimeoutReturnsBadGateway(t *testing.T) {
 	a.ServeHTTP(rr, req)
 
 	resp := rr.Result()
-	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -175,3 +182,455 @@ func TestServeHTTP_TimeoutReturnsBadGateway(t *testing.T) {
 
 	assert.Equal(t, "服务器繁忙", respBody["message"])
 }
+
+func TestAuth_DeniesByAuthorizeRules(t *testing.T) {
+	authConfig := &config.Auth{
+		Type:   "jwt",
+		Source: "$header.Authorization",
+		Secret: "secret",
+		Claims: map[string]string{"$header.X-User": "sub"},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "42"}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("SignedString error: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		userId string
+		ok     bool
+		status int
+	}{
+		{"own resource", "42", true, http.StatusOK},
+		{"other resource", "7", false, http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := NewAgent(&config.Upstream{
+				Auth: authConfig,
+				Authorize: []config.AuthorizeRule{
+					{Claim: "sub", Op: auth.AuthorizeOpEq, Values: []string{"$path.id"}},
+				},
+				PathParams:  map[string]string{"id": tc.userId},
+				UpstreamURL: "http://127.0.0.1",
+				TargetPath:  "/users/" + tc.userId,
+			}, gatewayConf)
+			if err != nil {
+				t.Fatalf("NewAgent error: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/users/"+tc.userId, nil)
+			req.Header.Set("Authorization", token)
+			rr := httptest.NewRecorder()
+
+			assert.Equal(t, tc.ok, a.Auth(rr, req))
+			assert.Equal(t, tc.status, rr.Code)
+			if tc.ok {
+				assert.Equal(t, "42", req.Context().Value(auth.ClaimContextKey("$header.X-User")))
+			}
+		})
+	}
+}
+
+func TestAuth_DeniesAuthorizeWithoutAuth(t *testing.T) {
+	a, err := NewAgent(&config.Upstream{
+		Authorize: []config.AuthorizeRule{
+			{Claim: "sub", Op: auth.AuthorizeOpEq, Values: []string{"42"}},
+		},
+		UpstreamURL: "http://127.0.0.1",
+		TargetPath:  "/users/42",
+	}, gatewayConf)
+	if err != nil {
+		t.Fatalf("NewAgent error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/users/42", nil)
+	req = req.WithContext(requestid.WithID(req.Context(), "req-403"))
+	assert.Equal(t, false, a.Auth(rr, req))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// 拒绝响应与其他网关错误一致, 为带有请求ID的 JSON
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal error: %v, body: %s", err, rr.Body.String())
+	}
+	assert.Equal(t, "req-403", body["request_id"])
+	assert.Equal(t, "permission denied", body["message"])
+}
+
+func TestServeHTTP_StripsSpoofedIdentity(t *testing.T) {
+	app := gin.Default()
+	app.Any("/*any", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"Header": gin.H{
+				"X-User":          c.GetHeader("X-User"),
+				"X-Tenant":        c.GetHeader("X-Tenant"),
+				"X-Internal-Role": c.GetHeader("X-Internal-Role"),
+				"X-Trace":         c.GetHeader("X-Trace"),
+			},
+			"Query": c.Request.URL.Query(),
+		})
+	})
+	upstream := httptest.NewServer(app)
+	defer upstream.Close()
+
+	routeConfig := &config.RouteConfig{
+		Proxy:   config.ProxyConfig{upstream.URL},
+		Timeout: "1s",
+		Auth: &config.Auth{
+			Type:   "jwt",
+			Source: "$header.Authorization",
+			Secret: "secret",
+			Claims: map[string]string{
+				"$header.X-User": "sub",
+				"$query.user_id": "uid",
+			},
+		},
+		StripHeaders: []string{"X-Internal-Role"},
+		Routes: []config.Route{
+			{
+				Match: "^/public/.*",
+				Proxy: config.ProxyConfig{upstream.URL},
+				// 禁用身份验证的路由同样不能透传客户端伪造的身份
+				DisableAuth: true,
+			},
+			{
+				Match: "^/tenant/.*",
+				Proxy: config.ProxyConfig{upstream.URL},
+				Auth: &config.Auth{
+					Type:   "jwt",
+					Source: "$header.Authorization",
+					Secret: "secret",
+					Claims: map[string]string{"$header.X-Tenant": "tenant"},
+				},
+			},
+			{
+				Match: "^/api/.*",
+				Proxy: config.ProxyConfig{upstream.URL},
+			},
+		},
+	}
+
+	// token 中不包含 uid 和 tenant 声明
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice"}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("SignedString error: %v", err)
+	}
+
+	testCases := []struct {
+		path   string
+		token  string
+		xUser  string
+		userId []any
+	}{
+		{"/public/hello", "", "", nil},
+		{"/tenant/hello", token, "", nil},
+		{"/api/hello", token, "alice", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com"+tc.path+"?user_id=1&x=1", nil)
+			req.Header.Set("X-User", "admin")
+			req.Header.Set("X-Tenant", "acme")
+			req.Header.Set("X-Internal-Role", "root")
+			req.Header.Set("X-Trace", "keep")
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+
+			upstreamConf := routeConfig.MatchRequest(context.Background(), req)
+			if upstreamConf == nil {
+				t.Fatalf("route not matched: %s", tc.path)
+			}
+			a, err := NewAgent(upstreamConf, gatewayConf)
+			if err != nil {
+				t.Fatalf("NewAgent error: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+			if !a.Auth(rr, req) {
+				t.Fatalf("unexpected auth failure: %d", rr.Code)
+			}
+			a.ServeHTTP(rr, req)
+
+			var respBody map[string]any
+			if err := json.Unmarshal(rr.Body.Bytes(), &respBody); err != nil {
+				t.Fatalf("Unmarshal error: %v", err)
+			}
+			header := respBody["Header"].(map[string]any)
+			query := respBody["Query"].(map[string]any)
+
+			assert.Equal(t, tc.xUser, header["X-User"])
+			assert.Equal(t, "", header["X-Tenant"])
+			assert.Equal(t, "", header["X-Internal-Role"])
+			assert.Equal(t, "keep", header["X-Trace"])
+			assert.Equal(t, nil, query["user_id"])
+			assert.Equal(t, []any{"1"}, query["x"])
+		})
+	}
+}
+
+func TestServeHTTP_StreamingBodyTooLarge(t *testing.T) {
+	app := gin.Default()
+	app.POST("/api/upload", func(c *gin.Context) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.Status(http.StatusOK)
+	})
+	upstream := httptest.NewServer(app)
+	defer upstream.Close()
+
+	a, err := NewAgent(&config.Upstream{
+		UpstreamURL: upstream.URL,
+		TargetPath:  "/api/upload",
+	}, gatewayConf)
+	if err != nil {
+		t.Fatalf("NewAgent error: %v", err)
+	}
+
+	// 分块传输的请求体没有 Content-Length, 只能在转发时发现超出限制
+	body := strings.NewReader(strings.Repeat("x", 4096))
+	req := httptest.NewRequest(http.MethodPost, "http://proxy.example.com/__test/api/upload", io.NopCloser(body))
+	req.ContentLength = -1
+	rr := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rr, req.Body, 1024)
+
+	a.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestServeHTTP_ForwardedHeaders(t *testing.T) {
+	app := gin.Default()
+	app.GET("/api/hello", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"For":       c.GetHeader("X-Forwarded-For"),
+			"Proto":     c.GetHeader("X-Forwarded-Proto"),
+			"Host":      c.GetHeader("X-Forwarded-Host"),
+			"Prefix":    c.GetHeader("X-Forwarded-Prefix"),
+			"Forwarded": c.GetHeader("Forwarded"),
+		})
+	})
+	upstream := httptest.NewServer(app)
+	defer upstream.Close()
+
+	conf := *gatewayConf
+	conf.TrustedProxies = []string{"10.0.0.1"}
+	conf.Forwarded = &config.ForwardedConfig{Headers: []string{
+		"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Forwarded-Prefix", "Forwarded",
+	}}
+	resolver, err := clientip.NewResolver(&conf)
+	if err != nil {
+		t.Fatalf("new resolver error: %v", err)
+	}
+
+	a, err := NewAgent(&config.Upstream{
+		Service:     "test",
+		UpstreamURL: upstream.URL,
+		TargetPath:  "/api/hello",
+	}, &conf, WithClientIPResolver(resolver))
+	if err != nil {
+		t.Fatalf("NewAgent error: %v", err)
+	}
+
+	do := func(remoteAddr string) map[string]string {
+		req := httptest.NewRequest(http.MethodGet, "http://gw.example.com/__test/api/hello", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Prefix", "/edge")
+		req.Header.Set("Forwarded", "for=198.51.100.7;proto=https")
+		rr := httptest.NewRecorder()
+		a.ServeHTTP(rr, req)
+
+		var body map[string]string
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode body error: %v", err)
+		}
+		return body
+	}
+
+	// 受信任代理: 追加转发链, 保留原始协议
+	body := do("10.0.0.1:1234")
+	assert.Equal(t, "198.51.100.7, 10.0.0.1", body["For"])
+	assert.Equal(t, "https", body["Proto"])
+	assert.Equal(t, "gw.example.com", body["Host"])
+	assert.Equal(t, "/edge/__test", body["Prefix"])
+	assert.Equal(t, "for=198.51.100.7;proto=https, for=10.0.0.1;proto=http;host=gw.example.com", body["Forwarded"])
+
+	// 非受信任对端: 覆盖客户端伪造的请求头
+	body = do("203.0.113.9:1234")
+	assert.Equal(t, "203.0.113.9", body["For"])
+	assert.Equal(t, "http", body["Proto"])
+	assert.Equal(t, "/__test", body["Prefix"])
+	assert.Equal(t, "for=203.0.113.9;proto=http;host=gw.example.com", body["Forwarded"])
+}
+
+func TestApplySecurityHeaders(t *testing.T) {
+	policy := &config.SecurityHeaders{
+		Preset:  SecurityPresetAPI,
+		Headers: map[string]string{"content-security-policy": "", "X-Extra": "1"},
+	}
+
+	header := http.Header{}
+	header.Set("X-Frame-Options", "SAMEORIGIN")
+	applySecurityHeaders(header, policy)
+	// 默认保留上游已设置的响应头
+	assert.Equal(t, "SAMEORIGIN", header.Get("X-Frame-Options"))
+	assert.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+	assert.Equal(t, "", header.Get("Content-Security-Policy"))
+	assert.Equal(t, "1", header.Get("X-Extra"))
+
+	policy.Override = true
+	applySecurityHeaders(header, policy)
+	assert.Equal(t, "DENY", header.Get("X-Frame-Options"))
+
+	header = http.Header{}
+	applySecurityHeaders(header, &config.SecurityHeaders{Preset: SecurityPresetNone})
+	assert.Equal(t, 0, len(header))
+}
+
+func TestServeHTTP_PropagatesTraceContext(t *testing.T) {
+	app := gin.Default()
+	app.GET("/api/hello", func(c *gin.Context) {
+		c.JSON(200, gin.H{"traceparent": c.GetHeader("traceparent")})
+	})
+	upstream := httptest.NewServer(app)
+	defer upstream.Close()
+
+	exporter := tracing.NewMemoryExporter()
+	tracer := tracing.NewTracerWithExporter(exporter, 1, nil)
+
+	a, err := NewAgent(&config.Upstream{
+		UpstreamURL: upstream.URL,
+		TargetPath:  "/api/hello",
+	}, gatewayConf)
+	if err != nil {
+		t.Fatalf("NewAgent error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://gw.example.com/__test/api/hello", nil)
+	ctx, server := tracer.StartServer(req.Context(), req.Header, "GET")
+	rr := httptest.NewRecorder()
+	a.ServeHTTP(rr, req.WithContext(ctx))
+	server.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	upstreamSpan := spans[0]
+	assert.Equal(t, tracing.SpanKindClient, upstreamSpan.Kind)
+	assert.Equal(t, 200, upstreamSpan.Attributes["http.response.status_code"])
+	assert.Equal(t, server.SpanContext().SpanID, upstreamSpan.ParentSpanID)
+
+	var body map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body error: %v", err)
+	}
+	expected := "00-" + upstreamSpan.SpanContext.TraceID.String() + "-" + upstreamSpan.SpanContext.SpanID.String() + "-01"
+	assert.Equal(t, expected, body["traceparent"])
+}
+
+func TestServeHTTP_SetUpstream(t *testing.T) {
+	app := gin.Default()
+	app.GET("/api/hello", func(c *gin.Context) {
+		c.String(200, "canary")
+	})
+	canary := httptest.NewServer(app)
+	defer canary.Close()
+
+	// 分流路由在匹配时尚未确定上游
+	a, err := NewAgent(&config.Upstream{
+		TargetPath: "/api/hello",
+		Split:      &config.TrafficSplit{},
+	}, gatewayConf)
+	if err != nil {
+		t.Fatalf("NewAgent error: %v", err)
+	}
+	if err := a.SetUpstream(canary.URL); err != nil {
+		t.Fatalf("SetUpstream error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://gw.example.com/__test/api/hello", nil)
+	rr := httptest.NewRecorder()
+	a.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "canary", rr.Body.String())
+	assert.Equal(t, metrics.UpstreamAddr(canary.URL), a.upstreamAddr)
+}
+
+func TestServeHTTP_EjectsFailedAffinityUpstream(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	manager := affinity.NewManager(nil)
+	a, err := NewAgent(&config.Upstream{
+		UpstreamURL: upstream.URL,
+		TargetPath:  "/api/hello",
+		Affinity:    &config.Affinity{EjectDuration: "1m"},
+	}, gatewayConf, WithAffinityManager(manager))
+	if err != nil {
+		t.Fatalf("NewAgent error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://gw.example.com/__test/api/hello", nil)
+	a.ServeHTTP(httptest.NewRecorder(), req)
+
+	if manager.Healthy(upstream.URL) {
+		t.Fatalf("failed upstream should be ejected")
+	}
+}
+
+func TestServeHTTP_UpstreamCORSHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "https://app.example.com")
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	serve := func(policy *config.CORSPolicy) *httptest.ResponseRecorder {
+		a, err := NewAgent(&config.Upstream{UpstreamURL: upstream.URL, TargetPath: "/api", Timeout: time.Second, CORS: policy}, gatewayConf)
+		if err != nil {
+			t.Fatalf("NewAgent error: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/api", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rr := httptest.NewRecorder()
+		a.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// 未配置跨域策略时保留上游自行返回的跨域响应头
+	rr := serve(nil)
+	assert.Equal(t, "https://app.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+
+	// 配置了跨域策略时由网关生成, 移除上游返回的同名响应头
+	rr = serve(&config.CORSPolicy{AllowOrigins: []string{"*"}})
+	assert.Equal(t, "", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "", rr.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestAuth_BodyTokenTooLarge(t *testing.T) {
+	upstreamConf := &config.Upstream{
+		UpstreamURL: "http://127.0.0.1:8080",
+		Auth:        &config.Auth{Type: "jwt", Source: "$body.token", Secret: "secret"},
+	}
+	a, err := NewAgent(upstreamConf, gatewayConf)
+	if err != nil {
+		t.Fatalf("NewAgent error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "http://proxy.example.com/login", strings.NewReader(`{"token":"`+strings.Repeat("x", 64)+`"}`))
+	req.Body = http.MaxBytesReader(rr, req.Body, 16)
+
+	// 读取令牌时超出请求体大小限制返回 413 而不是 401
+	assert.Equal(t, false, a.Auth(rr, req))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
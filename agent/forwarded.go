@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/superwhys/litegate/utils"
+)
+
+const (
+	HeaderXForwardedFor    = "X-Forwarded-For"
+	HeaderXForwardedProto  = "X-Forwarded-Proto"
+	HeaderXForwardedHost   = "X-Forwarded-Host"
+	HeaderXForwardedPrefix = "X-Forwarded-Prefix"
+	HeaderForwarded        = "Forwarded"
+)
+
+var defaultForwardedHeaders = []string{HeaderXForwardedFor, HeaderXForwardedProto, HeaderXForwardedHost, HeaderXForwardedPrefix}
+
+// forwardHeaders 生成转发给上游的 X-Forwarded-* 与 Forwarded 请求头
+// 直连对端为受信任代理时保留并追加已有请求头, 否则丢弃客户端传入的值
+func (a *agent) forwardHeaders(req *http.Request) {
+	headers := defaultForwardedHeaders
+	if a.forwarded != nil && len(a.forwarded.Headers) > 0 {
+		headers = a.forwarded.Headers
+	}
+	generate := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		generate[http.CanonicalHeaderKey(header)] = true
+	}
+
+	peer := utils.RemoteIP(req)
+	trusted := a.resolver.IsTrusted(peer)
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	prefix := ""
+	if a.service != "" {
+		prefix = "/__" + a.service
+	}
+
+	h := req.Header
+	// X-Forwarded-For 由 httputil 在已有值之后追加直连对端地址, 值为 nil 时不生成
+	switch {
+	case !generate[HeaderXForwardedFor]:
+		h[HeaderXForwardedFor] = nil
+	case !trusted:
+		h.Del(HeaderXForwardedFor)
+	}
+
+	for header, value := range map[string]string{
+		HeaderXForwardedProto: proto,
+		HeaderXForwardedHost:  req.Host,
+	} {
+		if !generate[header] {
+			h.Del(header)
+			continue
+		}
+		if trusted && h.Get(header) != "" {
+			continue
+		}
+		h.Set(header, value)
+	}
+
+	if generate[HeaderXForwardedPrefix] {
+		if existing := h.Get(HeaderXForwardedPrefix); trusted && existing != "" {
+			prefix = strings.TrimSuffix(existing, "/") + prefix
+		}
+		h.Set(HeaderXForwardedPrefix, prefix)
+	} else {
+		h.Del(HeaderXForwardedPrefix)
+	}
+
+	if generate[HeaderForwarded] {
+		element := "for=" + forwardedNode(peer) + ";proto=" + proto
+		if req.Host != "" {
+			element += ";host=" + forwardedValue(req.Host)
+		}
+		if existing := strings.Join(h.Values(HeaderForwarded), ", "); trusted && existing != "" {
+			element = existing + ", " + element
+		}
+		h.Set(HeaderForwarded, element)
+	} else {
+		h.Del(HeaderForwarded)
+	}
+}
+
+// forwardedNode RFC 7239 节点标识, IPv6 地址需加方括号并用引号包裹
+func forwardedNode(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return forwardedValue(ip)
+	}
+	if addr.Is6() && !addr.Is4In6() {
+		return `"[` + addr.String() + `]"`
+	}
+	return addr.Unmap().String()
+}
+
+// forwardedValue 包含非 token 字符时使用引号包裹
+func forwardedValue(value string) string {
+	if strings.ContainsAny(value, `:[]"; ,=`) {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"net/http"
+
+	"github.com/superwhys/litegate/config"
+)
+
+const (
+	SecurityPresetStrict = "strict"
+	SecurityPresetAPI    = "api"
+	SecurityPresetNone   = "none"
+)
+
+var securityPresets = map[string]map[string]string{
+	SecurityPresetStrict: {
+		"Strict-Transport-Security": "max-age=63072000; includeSubDomains; preload",
+		"Content-Security-Policy":   "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "no-referrer",
+		"Permissions-Policy":        "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+	},
+	SecurityPresetAPI: {
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "no-referrer",
+	},
+}
+
+// securityHeaders 合并预设与自定义响应头, 自定义值为空时移除该响应头
+func securityHeaders(policy *config.SecurityHeaders) map[string]string {
+	headers := make(map[string]string)
+	for name, value := range securityPresets[policy.Preset] {
+		headers[name] = value
+	}
+	for name, value := range policy.Headers {
+		name = http.CanonicalHeaderKey(name)
+		if value == "" {
+			delete(headers, name)
+			continue
+		}
+		headers[name] = value
+	}
+	return headers
+}
+
+// applySecurityHeaders 为上游响应添加安全响应头, 未开启 override 时只补充上游未设置的响应头
+func applySecurityHeaders(header http.Header, policy *config.SecurityHeaders) {
+	if policy == nil {
+		return
+	}
+	for name, value := range securityHeaders(policy) {
+		if !policy.Override && header.Get(name) != "" {
+			continue
+		}
+		header.Set(name, value)
+	}
+}
+
+// WithSecurityHeaders 为网关直接响应的路由添加安全响应头, 在写入状态码前补充
+func WithSecurityHeaders(w http.ResponseWriter, policy *config.SecurityHeaders) http.ResponseWriter {
+	if policy == nil {
+		return w
+	}
+	return &securityHeadersWriter{ResponseWriter: w, policy: policy}
+}
+
+type securityHeadersWriter struct {
+	http.ResponseWriter
+	policy      *config.SecurityHeaders
+	wroteHeader bool
+}
+
+func (w *securityHeadersWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		applySecurityHeaders(w.Header(), w.policy)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *securityHeadersWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -8,22 +8,59 @@
 package agent
 
 import (
+	"context"
 	"net"
 	"net/http"
+	"sync"
 
 	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/metrics"
 )
 
+// transports 按传输配置共享的 Transport, 使同一网关的所有代理请求复用连接池
+var transports sync.Map
+
+func sharedTransport(transportConf *config.TransportConfig) *http.Transport {
+	if transport, ok := transports.Load(transportConf); ok {
+		return transport.(*http.Transport)
+	}
+	transport, _ := transports.LoadOrStore(transportConf, customTransport(transportConf))
+	return transport.(*http.Transport)
+}
+
+// trackedConn 记录连接关闭, 用于统计连接池中的连接数
+type trackedConn struct {
+	net.Conn
+	addr string
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() { metrics.UpstreamOpenConnections.Dec(c.addr) })
+	return c.Conn.Close()
+}
+
+func trackedDialer(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		metrics.UpstreamOpenConnections.Inc(addr)
+		return &trackedConn{Conn: conn, addr: addr}, nil
+	}
+}
+
 func customTransport(transportConf *config.TransportConfig) *http.Transport {
 	return &http.Transport{
 		MaxIdleConns:        transportConf.MaxIdleConns,
 		MaxIdleConnsPerHost: transportConf.MaxIdleConnsPerHost,
 		MaxConnsPerHost:     transportConf.MaxConnsPerHost,
 		IdleConnTimeout:     transportConf.IdleConnTimeout,
-		DialContext: (&net.Dialer{
+		DialContext: trackedDialer(&net.Dialer{
 			Timeout:   transportConf.TcpDialTimeout,
 			KeepAlive: transportConf.KeepAlive,
-		}).DialContext,
+		}),
 		TLSHandshakeTimeout:   transportConf.TcpHandshakeTimeout,
 		ResponseHeaderTimeout: transportConf.ResponseHeaderTimeout,
 		ExpectContinueTimeout: transportConf.ExpectContinueTimeout,
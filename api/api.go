@@ -9,27 +9,169 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/miebyte/goutils/ginutils"
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/accesslog"
+	"github.com/superwhys/litegate/affinity"
 	"github.com/superwhys/litegate/api/middleware"
 	"github.com/superwhys/litegate/api/router"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/clientip"
+	"github.com/superwhys/litegate/concurrency"
 	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/cors"
+	"github.com/superwhys/litegate/mirror"
+	"github.com/superwhys/litegate/ratelimit"
+	"github.com/superwhys/litegate/recorder"
+	"github.com/superwhys/litegate/tracing"
 )
 
-func SetupGatewayApp(gatewayConf *config.GatewayConfig, configLoader config.ProxyConfigLoader) http.Handler {
+// Gateway 网关应用, 退出时通过 Close 释放录制文件等资源
+type Gateway struct {
+	http.Handler
+	closers []func() error
+}
+
+// Close 按创建的相反顺序释放网关资源
+func (g *Gateway) Close() error {
+	var errs []error
+	for i := len(g.closers) - 1; i >= 0; i-- {
+		errs = append(errs, g.closers[i]())
+	}
+	return errors.Join(errs...)
+}
+
+func SetupGatewayApp(gatewayConf *config.GatewayConfig, configLoader config.ProxyConfigLoader) (*Gateway, error) {
+	gateway := &Gateway{}
+
+	tokenIssuer, err := auth.NewTokenIssuer(gatewayConf.TokenIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	revocation, err := auth.NewRevocationList(gatewayConf.Revocation)
+	if err != nil {
+		return nil, err
+	}
+	if err := revocation.Watch(); err != nil {
+		return nil, err
+	}
+	gateway.closers = append(gateway.closers, func() error {
+		revocation.StopWatch()
+		return nil
+	})
+
+	rateLimiter, err := ratelimit.NewLimiter(gatewayConf.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	// 启动时拒绝当前存储不支持的限流规则, 热加载的规则在请求时返回错误并按 fail_policy 处理
+	routeConfigs, err := configLoader.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, routeConfig := range routeConfigs {
+		if err := rateLimiter.Validate(routeConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	quota, err := ratelimit.NewQuotaManager(gatewayConf.Quota)
+	if err != nil {
+		return nil, err
+	}
+	gateway.closers = append(gateway.closers, quota.Close)
+
+	resolver, err := clientip.NewResolver(gatewayConf)
+	if err != nil {
+		return nil, err
+	}
+
+	tracer, err := tracing.NewTracer(gatewayConf.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	gateway.closers = append(gateway.closers, tracer.Shutdown)
+
+	accessLogger, err := accesslog.NewLogger(gatewayConf.AccessLog)
+	if err != nil {
+		return nil, err
+	}
+	gateway.closers = append(gateway.closers, accessLogger.Close)
+
+	trafficRecorder, err := recorder.NewRecorder(gatewayConf.Recorder)
+	if err != nil {
+		return nil, err
+	}
+	gateway.closers = append(gateway.closers, trafficRecorder.Close)
+
+	concurrencyManager := concurrency.NewManager()
+	admission := concurrency.NewAdmission(gatewayConf.Admission)
+
+	mirrors := mirror.NewManager()
+	gateway.closers = append(gateway.closers, mirrors.Close)
+
+	var adminToken, metricsToken string
+	if gatewayConf.Admin != nil {
+		adminToken, metricsToken = gatewayConf.Admin.Token, gatewayConf.Admin.MetricsToken
+	}
+	if adminToken == "" {
+		logging.Warnf("admin token is not configured, /admin and /debug endpoints are disabled")
+	}
+	if adminToken == "" && metricsToken == "" {
+		logging.Warnf("admin token and metrics token are not configured, /metrics endpoint is disabled")
+	}
+
 	app := ginutils.NewServerHandler(
 		// debug group
 		ginutils.WithGroupHandlers(
 			ginutils.WithPrefix("/debug"),
-			ginutils.WithRouterHandler(router.DebugRouter(configLoader)),
+			ginutils.WithMiddleware(middleware.AdminAuth(adminToken)),
+			ginutils.WithRouterHandler(router.DebugRouter(configLoader, revocation, concurrencyManager, admission)),
+		),
+		// admin group
+		ginutils.WithGroupHandlers(
+			ginutils.WithPrefix("/admin"),
+			ginutils.WithMiddleware(middleware.AdminAuth(adminToken)),
+			ginutils.WithRouterHandler(router.AdminRouter(revocation, quota)),
+		),
+		ginutils.WithGroupHandlers(
+			ginutils.WithPrefix("/metrics"),
+			ginutils.WithMiddleware(middleware.AdminAuth(adminToken, metricsToken)),
+			ginutils.WithRouterHandler(router.MetricsRouter()),
+		),
+		ginutils.WithGroupHandlers(
+			ginutils.WithPrefix("/.well-known"),
+			ginutils.WithMiddleware(middleware.CORS(cors.DefaultPolicy)),
+			ginutils.WithRouterHandler(router.WellKnownRouter(tokenIssuer)),
 		),
 		ginutils.WithGroupHandlers(
 			ginutils.WithPrefix("/__:serviceName"),
+			ginutils.WithMiddleware(middleware.AccessLog(accessLogger)),
+			ginutils.WithMiddleware(middleware.RequestID(gatewayConf, resolver)),
+			ginutils.WithMiddleware(middleware.ClientIP(resolver)),
+			ginutils.WithMiddleware(middleware.Tracing(tracer)),
+			ginutils.WithMiddleware(middleware.RequestLimits(gatewayConf)),
 			ginutils.WithMiddleware(middleware.ParseProxyConfig(gatewayConf, configLoader)),
-			ginutils.WithAnyHandler("/*any", router.ProxyRouter(gatewayConf)),
+			ginutils.WithMiddleware(middleware.Record(trafficRecorder)),
+			ginutils.WithAnyHandler("/*any", router.ProxyRouter(
+				gatewayConf,
+				router.WithTokenIssuer(tokenIssuer),
+				router.WithRevocationList(revocation),
+				router.WithRateLimiter(rateLimiter),
+				router.WithConcurrencyManager(concurrencyManager),
+				router.WithAdmission(admission),
+				router.WithQuotaManager(quota),
+				router.WithClientIPResolver(resolver),
+				router.WithMirrorManager(mirrors),
+				router.WithAffinityManager(affinity.NewManager(gatewayConf.Affinity)),
+			)),
 		),
 	)
 
-	return app
+	gateway.Handler = app
+	return gateway, nil
 }
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superwhys/litegate/accesslog"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/clientip"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/requestid"
+	"github.com/superwhys/litegate/tracing"
+)
+
+// AccessLog 在请求结束后输出访问日志, 路由与代理在处理过程中向记录补充路由、上游及耗时信息
+func AccessLog(logger *accesslog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record := logger.NewRecord(c.Request)
+		if record == nil {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		record.Time = start
+		record.Service = c.Param("serviceName")
+		var body *accesslog.CountingReader
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body = &accesslog.CountingReader{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+		c.Request = c.Request.WithContext(accesslog.WithRecord(c.Request.Context(), record))
+
+		c.Next()
+
+		ctx := c.Request.Context()
+		record.Latency = time.Since(start)
+		record.Status = c.Writer.Status()
+		record.BytesOut = int64(max(c.Writer.Size(), 0))
+		if body != nil {
+			record.BytesIn = body.N
+		}
+		record.RequestID = requestid.FromContext(ctx)
+		record.ClientIP = clientip.FromRequest(c.Request)
+		if span := tracing.SpanFromContext(ctx); span != nil {
+			record.TraceID = span.SpanContext().TraceID.String()
+		}
+		if subject, ok := auth.ClaimsFromContext(ctx).String("sub"); ok {
+			record.Subject = subject
+		}
+
+		var serviceConf *config.ServiceAccessLog
+		if proxyConfig := GetProxyConfig(c); proxyConfig != nil {
+			serviceConf = proxyConfig.AccessLog
+		}
+		logger.Log(record, serviceConf)
+	}
+}
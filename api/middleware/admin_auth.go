@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 校验管理接口的 Bearer 令牌, 请求携带任一配置的令牌时放行
+// 未配置任何令牌时拒绝所有请求, 管理接口不会在未配置令牌的情况下对外开放
+func AdminAuth(tokens ...string) gin.HandlerFunc {
+	var allowed [][]byte
+	for _, token := range tokens {
+		if token != "" {
+			allowed = append(allowed, []byte(token))
+		}
+	}
+
+	return func(c *gin.Context) {
+		scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="litegate"`)
+			AbortWithStatus(c, http.StatusUnauthorized, "missing admin token")
+			return
+		}
+
+		for _, expected := range allowed {
+			if subtle.ConstantTimeCompare([]byte(token), expected) == 1 {
+				c.Next()
+				return
+			}
+		}
+		c.Header("WWW-Authenticate", `Bearer realm="litegate", error="invalid_token"`)
+		AbortWithStatus(c, http.StatusUnauthorized, "invalid admin token")
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/assert/v2"
+)
+
+func serveAdmin(handler gin.HandlerFunc, authorization string) int {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/admin/revocations", handler, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/revocations", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rr := httptest.NewRecorder()
+	engine.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestAdminAuth(t *testing.T) {
+	handler := AdminAuth("admin-secret")
+
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin(handler, ""))
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin(handler, "Bearer wrong"))
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin(handler, "Basic admin-secret"))
+	assert.Equal(t, http.StatusOK, serveAdmin(handler, "Bearer admin-secret"))
+
+	// 未配置令牌时拒绝所有请求
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin(AdminAuth(""), "Bearer "))
+	assert.Equal(t, http.StatusUnauthorized, serveAdmin(AdminAuth(""), "Bearer anything"))
+}
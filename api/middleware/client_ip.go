@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/clientip"
+)
+
+// ClientIP 解析客户端真实IP, 写入请求上下文供限流、访问控制及日志使用
+func ClientIP(resolver *clientip.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := resolver.Resolve(c.Request)
+		ctx := clientip.WithClientIP(c.Request.Context(), ip)
+		ctx = logging.With(ctx, "clientIP", ip)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/cors"
+)
+
+// CORS 按指定策略处理非代理接口的跨域请求, 预检请求直接响应
+func CORS(policy *config.CORSPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cors.IsPreflight(c.Request) {
+			cors.Preflight(c.Writer, c.Request, policy)
+			c.Abort()
+			return
+		}
+		cors.SetResponseHeaders(c.Writer, c.Request, policy)
+		c.Next()
+	}
+}
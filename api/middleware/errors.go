@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superwhys/litegate/requestid"
+)
+
+// AbortWithStatus 以指定状态码返回网关错误, 响应体附带请求ID
+func AbortWithStatus(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, requestid.ErrorRet(c.Request.Context(), status, message))
+}
+
+// ReturnError 与 ginutils.ReturnError 一致使用 200 状态码返回网关错误, 响应体附带请求ID
+func ReturnError(c *gin.Context, message string) {
+	AbortWithStatus(c, http.StatusOK, message)
+}
@@ -9,18 +9,18 @@
 package middleware
 
 import (
-	"net/http"
 	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/miebyte/goutils/ginutils"
 	"github.com/miebyte/goutils/logging"
 	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/tracing"
 )
 
 const (
 	ProxyConfigKey = "proxyConfig"
+	UpstreamKey    = "upstream"
 )
 
 func GetProxyConfig(c *gin.Context) *config.RouteConfig {
@@ -31,17 +31,26 @@ func GetProxyConfig(c *gin.Context) *config.RouteConfig {
 	return route.(*config.RouteConfig)
 }
 
+// GetUpstream 返回路由匹配的上游配置, 未匹配时返回 nil
+func GetUpstream(c *gin.Context) *config.Upstream {
+	upstream, ok := c.Get(UpstreamKey)
+	if !ok {
+		return nil
+	}
+	return upstream.(*config.Upstream)
+}
+
 func ParseProxyConfig(gatewayConf *config.GatewayConfig, configLoader config.ProxyConfigLoader) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		serviceName := c.Param("serviceName")
 		if !slices.Contains(gatewayConf.Services, serviceName) {
-			ginutils.ReturnError(c, http.StatusOK, "service not found")
+			ReturnError(c, "service not found")
 			return
 		}
 
 		route, err := configLoader.Get(serviceName)
 		if err != nil {
-			ginutils.ReturnError(c, http.StatusOK, err.Error())
+			ReturnError(c, err.Error())
 			return
 		}
 
@@ -51,6 +60,10 @@ func ParseProxyConfig(gatewayConf *config.GatewayConfig, configLoader config.Pro
 
 		ctx := logging.With(c.Request.Context(), "proxyService", serviceName)
 		ctx = logging.With(ctx, "proxyTargetPath", targetPath)
+		if span := tracing.SpanFromContext(ctx); span != nil {
+			ctx = logging.With(ctx, "traceId", span.SpanContext().TraceID.String())
+			ctx = logging.With(ctx, "spanId", span.SpanContext().SpanID.String())
+		}
 
 		c.Request = c.Request.WithContext(ctx)
 		c.Request.URL.Path = targetPath
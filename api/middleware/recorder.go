@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/metrics"
+	"github.com/superwhys/litegate/recorder"
+	"github.com/superwhys/litegate/requestid"
+)
+
+// Record 按采样率录制请求与响应, 消息体只保留配置的最大字节数, 不影响转发
+// 需在 ParseProxyConfig 之后执行, 只录制允许访问的服务, 避免任意服务名创建录制文件
+func Record(rc *recorder.Recorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service := c.Param("serviceName")
+		if !rc.Sampled(service) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		entry := &recorder.Entry{
+			Time:    start,
+			Service: service,
+			Method:  c.Request.Method,
+			Path:    "/__" + service + c.Request.URL.Path,
+		}
+		if c.Request.URL.RawQuery != "" {
+			entry.Path += "?" + rc.RedactQuery(c.Request.URL.RawQuery)
+		}
+		requestHeader := c.Request.Header.Clone()
+
+		var requestBody *recorder.LimitedBuffer
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			requestBody = recorder.NewLimitedBuffer(rc.MaxBodyBytes())
+			c.Request.Body = &teeReadCloser{Reader: io.TeeReader(c.Request.Body, requestBody), Closer: c.Request.Body}
+		}
+		writer := &captureWriter{ResponseWriter: c.Writer, body: recorder.NewLimitedBuffer(rc.MaxBodyBytes())}
+		c.Writer = writer
+
+		c.Next()
+
+		entry.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+		entry.RequestID = requestid.FromContext(c.Request.Context())
+		if upstream := GetUpstream(c); upstream != nil {
+			entry.Route = upstream.Route
+			entry.Upstream = metrics.UpstreamAddr(upstream.UpstreamURL)
+			entry.UpstreamPath = upstream.TargetPath
+			if c.Request.URL.RawQuery != "" {
+				entry.UpstreamPath += "?" + rc.RedactQuery(c.Request.URL.RawQuery)
+			}
+		}
+		entry.Request = rc.NewMessage(0, requestHeader, requestBody)
+		entry.Response = rc.NewMessage(writer.Status(), writer.Header(), writer.body)
+
+		if err := rc.Write(entry); err != nil {
+			logging.Errorc(c.Request.Context(), "write traffic record error: %v", err)
+		}
+	}
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter 在写入客户端的同时保存响应体
+type captureWriter struct {
+	gin.ResponseWriter
+	body *recorder.LimitedBuffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/clientip"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/requestid"
+	"github.com/superwhys/litegate/utils"
+)
+
+// RequestID 沿用或生成请求ID, 写入请求头转发给上游, 并在响应头、日志上下文及错误响应中返回
+func RequestID(gatewayConf *config.GatewayConfig, resolver *clientip.Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conf := gatewayConf.RequestID
+		if conf == nil {
+			conf = &config.RequestIDConfig{Header: "X-Request-ID", Trust: config.RequestIDTrustAlways}
+		}
+
+		id := c.Request.Header.Get(conf.Header)
+		trusted := conf.Trust == config.RequestIDTrustAlways ||
+			conf.Trust == config.RequestIDTrustTrustedProxies && resolver.IsTrusted(utils.RemoteIP(c.Request))
+		if !trusted || !requestid.Valid(id) {
+			id = requestid.New(conf.Generator)
+		}
+
+		c.Request.Header.Set(conf.Header, id)
+		c.Header(conf.Header, id)
+
+		ctx := requestid.WithID(c.Request.Context(), id)
+		ctx = logging.With(ctx, "requestId", id)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superwhys/litegate/config"
+)
+
+// RequestLimits 限制请求 URL 长度以及请求头的数量和大小
+func RequestLimits(gatewayConf *config.GatewayConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limits := gatewayConf.RequestLimits
+		if limits == nil {
+			c.Next()
+			return
+		}
+
+		if limits.MaxURLLength > 0 && len(c.Request.URL.RequestURI()) > limits.MaxURLLength {
+			AbortWithStatus(c, http.StatusRequestURITooLong, "request uri too long")
+			return
+		}
+
+		count, size := 0, 0
+		for name, values := range c.Request.Header {
+			for _, value := range values {
+				count++
+				size += len(name) + len(value)
+			}
+		}
+		if limits.MaxHeaderCount > 0 && count > limits.MaxHeaderCount {
+			AbortWithStatus(c, http.StatusRequestHeaderFieldsTooLarge, "too many request headers")
+			return
+		}
+		if limits.MaxHeaderBytes > 0 && size > limits.MaxHeaderBytes {
+			AbortWithStatus(c, http.StatusRequestHeaderFieldsTooLarge, "request headers too large")
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/superwhys/litegate/clientip"
+	"github.com/superwhys/litegate/tracing"
+)
+
+// Tracing 为每个请求创建服务端跨度, 路由匹配、身份验证及上游请求的跨度均为其子跨度
+func Tracing(tracer *tracing.Tracer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := tracer.StartServer(c.Request.Context(), c.Request.Header, c.Request.Method)
+		if span == nil {
+			c.Next()
+			return
+		}
+		defer span.End()
+
+		span.SetAttribute("http.request.method", c.Request.Method)
+		span.SetAttribute("url.path", c.Request.URL.Path)
+		span.SetAttribute("litegate.service", c.Param("serviceName"))
+		span.SetAttribute("client.address", clientip.FromRequest(c.Request))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.response.status_code", status)
+		if status >= 500 {
+			span.SetStatus(tracing.StatusError, "")
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/miebyte/goutils/ginutils"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/ratelimit"
+)
+
+type adminRouter struct {
+	revocation *auth.RevocationList
+	quota      *ratelimit.QuotaManager
+}
+
+func AdminRouter(revocation *auth.RevocationList, quota *ratelimit.QuotaManager) *adminRouter {
+	return &adminRouter{revocation: revocation, quota: quota}
+}
+
+type revokeJTIRequest struct {
+	JTI string `json:"jti" binding:"required"`
+	// 令牌过期时间, 过期后吊销记录自动清理, 为空时永久保留
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type revokeSubjectRequest struct {
+	Sub string `json:"sub" binding:"required"`
+	// 该时间点之前签发的令牌全部失效, 为空时取当前时间
+	Before time.Time `json:"before"`
+}
+
+func (r *adminRouter) Init(router gin.IRouter) {
+	router.GET("/revocations", func(c *gin.Context) {
+		ginutils.ReturnSuccess(c, r.revocation.Snapshot())
+	})
+
+	router.POST("/revocations/jti", func(c *gin.Context) {
+		var req revokeJTIRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			ginutils.ReturnError(c, http.StatusOK, err.Error())
+			return
+		}
+		if err := r.revocation.RevokeJTI(req.JTI, req.ExpiresAt); err != nil {
+			ginutils.ReturnError(c, http.StatusOK, err.Error())
+			return
+		}
+		ginutils.ReturnSuccess(c, nil)
+	})
+
+	router.DELETE("/revocations/jti/:jti", func(c *gin.Context) {
+		if err := r.revocation.UnrevokeJTI(c.Param("jti")); err != nil {
+			ginutils.ReturnError(c, http.StatusOK, err.Error())
+			return
+		}
+		ginutils.ReturnSuccess(c, nil)
+	})
+
+	// 登出用户的所有会话
+	router.POST("/revocations/subject", func(c *gin.Context) {
+		var req revokeSubjectRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			ginutils.ReturnError(c, http.StatusOK, err.Error())
+			return
+		}
+		if req.Before.IsZero() {
+			req.Before = time.Now()
+		}
+		if err := r.revocation.RevokeSubject(req.Sub, req.Before); err != nil {
+			ginutils.ReturnError(c, http.StatusOK, err.Error())
+			return
+		}
+		ginutils.ReturnSuccess(c, nil)
+	})
+
+	router.DELETE("/revocations/subject/:sub", func(c *gin.Context) {
+		if err := r.revocation.UnrevokeSubject(c.Param("sub")); err != nil {
+			ginutils.ReturnError(c, http.StatusOK, err.Error())
+			return
+		}
+		ginutils.ReturnSuccess(c, nil)
+	})
+
+	// 查询消费者当前周期的配额使用, 例如: GET /admin/quotas?consumer=abc&service=test
+	router.GET("/quotas", func(c *gin.Context) {
+		ginutils.ReturnSuccess(c, r.quota.Usage(c.Query("service"), c.Query("consumer")))
+	})
+
+	// 清零消费者当前周期的配额使用
+	router.DELETE("/quotas", func(c *gin.Context) {
+		consumer := c.Query("consumer")
+		if consumer == "" {
+			ginutils.ReturnError(c, http.StatusOK, "consumer is required")
+			return
+		}
+		ginutils.ReturnSuccess(c, gin.H{"reset": r.quota.Reset(c.Query("service"), consumer)})
+	})
+}
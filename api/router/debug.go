@@ -2,18 +2,34 @@ package router
 
 import (
 	"net/http"
+	"net/url"
 
 	"github.com/gin-gonic/gin"
 	"github.com/miebyte/goutils/ginutils"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/concurrency"
 	"github.com/superwhys/litegate/config"
 )
 
 type debugRouter struct {
 	configLoader config.ProxyConfigLoader
+	revocation   *auth.RevocationList
+	concurrency  *concurrency.Manager
+	admission    *concurrency.Admission
 }
 
-func DebugRouter(configLoader config.ProxyConfigLoader) *debugRouter {
-	return &debugRouter{configLoader: configLoader}
+func DebugRouter(
+	configLoader config.ProxyConfigLoader,
+	revocation *auth.RevocationList,
+	manager *concurrency.Manager,
+	admission *concurrency.Admission,
+) *debugRouter {
+	return &debugRouter{
+		configLoader: configLoader,
+		revocation:   revocation,
+		concurrency:  manager,
+		admission:    admission,
+	}
 }
 
 func (r *debugRouter) Init(router gin.IRouter) {
@@ -35,4 +51,73 @@ func (r *debugRouter) Init(router gin.IRouter) {
 		}
 		ginutils.ReturnSuccess(c, route)
 	})
+
+	router.GET("/explain/:serviceName", r.explain)
+
+	// 各路由的并发上限、在途请求与排队数
+	router.GET("/concurrency", func(c *gin.Context) {
+		ginutils.ReturnSuccess(c, r.concurrency.Stats())
+	})
+
+	// 全局准入各优先级类别的在途、排队、放行与拒绝计数
+	router.GET("/admission", func(c *gin.Context) {
+		ginutils.ReturnSuccess(c, r.admission.Stats())
+	})
+}
+
+type explainResult struct {
+	Upstream  *config.Upstream `json:"upstream"`
+	Claims    auth.Claims      `json:"claims,omitempty"`
+	AuthError string           `json:"auth_error,omitempty"`
+	Decision  *auth.Decision   `json:"decision,omitempty"`
+}
+
+// explain 评估指定路径的路由匹配、身份验证与授权结果
+// 例如: GET /debug/explain/test?path=/users/42&method=GET, 请求头原样用于身份验证
+func (r *debugRouter) explain(c *gin.Context) {
+	serviceName := c.Param("serviceName")
+	route, err := r.configLoader.Get(serviceName)
+	if err != nil {
+		ginutils.ReturnError(c, http.StatusOK, err.Error())
+		return
+	}
+	if route == nil {
+		ginutils.ReturnError(c, http.StatusOK, "service not found")
+		return
+	}
+
+	target, err := url.ParseRequestURI(c.Query("path"))
+	if err != nil {
+		ginutils.ReturnError(c, http.StatusOK, "invalid path: "+err.Error())
+		return
+	}
+
+	req := c.Request.Clone(c)
+	req.Method = c.DefaultQuery("method", http.MethodGet)
+	req.URL = target
+
+	upstream := route.MatchRequest(c, req)
+	if upstream == nil {
+		ginutils.ReturnError(c, http.StatusOK, "route config not found")
+		return
+	}
+
+	ret := &explainResult{Upstream: upstream}
+	authenticator, err := auth.NewAuthenticator(upstream.Auth, r.revocation)
+	if err != nil {
+		ginutils.ReturnError(c, http.StatusOK, err.Error())
+		return
+	}
+	if authenticator != nil {
+		req = req.WithContext(auth.WithPathParams(req.Context(), upstream.PathParams))
+		claims, err := authenticator.Parse(req)
+		if err != nil {
+			ret.AuthError = err.Error()
+		} else {
+			ret.Claims = claims
+			ret.Decision = auth.Authorize(upstream.Authorize, claims, upstream.PathParams)
+		}
+	}
+
+	ginutils.ReturnSuccess(c, ret)
 }
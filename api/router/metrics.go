@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/superwhys/litegate/metrics"
+)
+
+type metricsRouter struct{}
+
+func MetricsRouter() *metricsRouter {
+	return &metricsRouter{}
+}
+
+func (r *metricsRouter) Init(router gin.IRouter) {
+	// Prometheus 文本格式指标
+	router.GET("", gin.WrapH(metrics.Handler()))
+}
@@ -1,41 +1,317 @@
 package router
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/miebyte/goutils/ginutils"
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/accesslog"
+	"github.com/superwhys/litegate/action"
+	"github.com/superwhys/litegate/affinity"
 	"github.com/superwhys/litegate/agent"
 	"github.com/superwhys/litegate/api/middleware"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/clientip"
+	"github.com/superwhys/litegate/concurrency"
 	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/cors"
+	"github.com/superwhys/litegate/fault"
+	"github.com/superwhys/litegate/metrics"
+	"github.com/superwhys/litegate/mirror"
+	"github.com/superwhys/litegate/ratelimit"
+	"github.com/superwhys/litegate/split"
+	"github.com/superwhys/litegate/tracing"
 )
 
-func ProxyRouter(gatewayConf *config.GatewayConfig) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		proxyConfig := middleware.GetProxyConfig(c)
-		if proxyConfig == nil {
-			ginutils.ReturnError(c, http.StatusOK, "proxy config not found")
+type proxyRouter struct {
+	gatewayConf *config.GatewayConfig
+	tokenIssuer *auth.TokenIssuer
+	revocation  *auth.RevocationList
+	rateLimiter *ratelimit.Limiter
+	concurrency *concurrency.Manager
+	admission   *concurrency.Admission
+	quota       *ratelimit.QuotaManager
+	resolver    *clientip.Resolver
+	mirrors     *mirror.Manager
+	affinity    *affinity.Manager
+}
+
+type ProxyOption func(r *proxyRouter)
+
+func WithTokenIssuer(tokenIssuer *auth.TokenIssuer) ProxyOption {
+	return func(r *proxyRouter) {
+		r.tokenIssuer = tokenIssuer
+	}
+}
+
+func WithRevocationList(revocation *auth.RevocationList) ProxyOption {
+	return func(r *proxyRouter) {
+		r.revocation = revocation
+	}
+}
+
+func WithRateLimiter(rateLimiter *ratelimit.Limiter) ProxyOption {
+	return func(r *proxyRouter) {
+		r.rateLimiter = rateLimiter
+	}
+}
+
+func WithConcurrencyManager(manager *concurrency.Manager) ProxyOption {
+	return func(r *proxyRouter) {
+		r.concurrency = manager
+	}
+}
+
+func WithAdmission(admission *concurrency.Admission) ProxyOption {
+	return func(r *proxyRouter) {
+		r.admission = admission
+	}
+}
+
+func WithQuotaManager(quota *ratelimit.QuotaManager) ProxyOption {
+	return func(r *proxyRouter) {
+		r.quota = quota
+	}
+}
+
+func WithClientIPResolver(resolver *clientip.Resolver) ProxyOption {
+	return func(r *proxyRouter) {
+		r.resolver = resolver
+	}
+}
+
+func WithMirrorManager(mirrors *mirror.Manager) ProxyOption {
+	return func(r *proxyRouter) {
+		r.mirrors = mirrors
+	}
+}
+
+func WithAffinityManager(affinity *affinity.Manager) ProxyOption {
+	return func(r *proxyRouter) {
+		r.affinity = affinity
+	}
+}
+
+func ProxyRouter(gatewayConf *config.GatewayConfig, opts ...ProxyOption) gin.HandlerFunc {
+	r := &proxyRouter{gatewayConf: gatewayConf}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r.handle
+}
+
+func (r *proxyRouter) handle(c *gin.Context) {
+	proxyConfig := middleware.GetProxyConfig(c)
+	if proxyConfig == nil {
+		middleware.ReturnError(c, "proxy config not found")
+		return
+	}
+
+	service := c.Param("serviceName")
+	var route, upstream string
+	start := time.Now()
+	metrics.InflightRequests.Inc(service)
+	defer func() {
+		metrics.InflightRequests.Dec(service)
+		metrics.ObserveRequest(service, route, upstream, c.Request.Method, c.Writer.Status(), c.Writer.Size(), time.Since(start))
+	}()
+
+	// 1. parse route config
+	_, matchSpan := tracing.Start(c.Request.Context(), "route.match", tracing.SpanKindInternal)
+	upstreamConf := proxyConfig.MatchRequest(c, c.Request)
+	if upstreamConf == nil {
+		matchSpan.SetStatus(tracing.StatusError, "route config not found")
+		matchSpan.End()
+		middleware.ReturnError(c, "route config not found")
+		return
+	}
+	upstreamConf.Service = service
+	c.Set(middleware.UpstreamKey, upstreamConf)
+	route, upstream = upstreamConf.Route, metrics.UpstreamAddr(upstreamConf.UpstreamURL)
+	if actionType := action.Type(upstreamConf); actionType != "" {
+		upstream = actionType
+	}
+	accesslog.FromContext(c.Request.Context()).SetRoute(route, upstream)
+	matchSpan.SetAttribute("http.route", route)
+	matchSpan.End()
+
+	serverSpan := tracing.SpanFromContext(c.Request.Context())
+	serverSpan.SetName(c.Request.Method + " " + route)
+	serverSpan.SetAttribute("http.route", route)
+	serverSpan.SetAttribute("server.address", upstream)
+
+	// 2. cors, only for routes with a policy so that upstreams handling cors
+	// themselves are untouched, preflight requests are answered without auth or upstream
+	if upstreamConf.CORS != nil {
+		if cors.IsPreflight(c.Request) {
+			cors.Preflight(c.Writer, c.Request, upstreamConf.CORS)
+			c.Abort()
 			return
 		}
+		cors.SetResponseHeaders(c.Writer, c.Request, upstreamConf.CORS)
+	}
+
+	// 3. client ip access control
+	if allowed, err := clientip.Allowed(upstreamConf.IPACL, clientip.FromRequest(c.Request)); err != nil {
+		middleware.ReturnError(c, err.Error())
+		return
+	} else if !allowed {
+		middleware.AbortWithStatus(c, http.StatusForbidden, "ip not allowed")
+		return
+	}
+
+	// 4. rate limit by keys that do not depend on claims, before auth so that
+	// requests failing auth are throttled as well
+	if r.rateLimiter != nil && !r.rateLimiter.Limit(c.Writer, c.Request, upstreamConf, ratelimit.PhaseBeforeAuth) {
+		c.Abort()
+		return
+	}
+
+	// 5. limit request body before it is read by auth or streamed upstream
+	if !r.limitBody(c, upstreamConf) {
+		return
+	}
+
+	// 6. create agent
+	proxyAgent, err := agent.NewAgent(
+		upstreamConf,
+		r.gatewayConf,
+		agent.WithTokenIssuer(r.tokenIssuer),
+		agent.WithRevocationList(r.revocation),
+		agent.WithClientIPResolver(r.resolver),
+		agent.WithMirrorManager(r.mirrors),
+		agent.WithAffinityManager(r.affinity),
+	)
+	if err != nil {
+		middleware.ReturnError(c, err.Error())
+		return
+	}
+
+	// 7. auth route
+	_, authSpan := tracing.Start(c.Request.Context(), "auth", tracing.SpanKindInternal)
+	authorized := proxyAgent.Auth(c.Writer, c.Request)
+	if !authorized {
+		authSpan.SetStatus(tracing.StatusError, "unauthorized")
+	}
+	authSpan.End()
+	if !authorized {
+		c.Abort()
+		return
+	}
+
+	// 8. rate limit by claims
+	if r.rateLimiter != nil && !r.rateLimiter.Limit(c.Writer, c.Request, upstreamConf, ratelimit.PhaseAfterAuth) {
+		c.Abort()
+		return
+	}
+
+	// 9. respond by the gateway, actions never reach an upstream so they skip
+	// upstream selection, fault injection, admission, concurrency and quota
+	if action.Type(upstreamConf) != "" {
+		action.Serve(agent.WithSecurityHeaders(c.Writer, upstreamConf.SecurityHeaders), c.Request, upstreamConf)
+		return
+	}
+
+	// 10. upstream selection, traffic split and session affinity may depend on claims
+	if upstreamConf.Split != nil || upstreamConf.Affinity != nil {
+		if err := proxyAgent.SetUpstream(r.selectUpstream(c, upstreamConf)); err != nil {
+			middleware.ReturnError(c, err.Error())
+			return
+		}
+		upstream = metrics.UpstreamAddr(upstreamConf.UpstreamURL)
+		accesslog.FromContext(c.Request.Context()).SetRoute(route, upstream)
+		serverSpan.SetAttribute("server.address", upstream)
+	}
 
-		// 1. parse route config
-		upstreamConf := proxyConfig.MatchRequest(c, c.Request)
-		if upstreamConf == nil {
-			ginutils.ReturnError(c, http.StatusOK, "route config not found")
+	// 11. fault injection before admission so injected delays do not hold slots,
+	// aborted requests are not counted against the quota
+	if !fault.Inject(c.Writer, c.Request, upstreamConf) {
+		c.Abort()
+		return
+	}
+
+	// 12. gateway admission by priority class
+	if r.admission.Enabled() {
+		claims := auth.ClaimsFromContext(c.Request.Context())
+		class, release, err := r.admission.Acquire(c.Request.Context(), upstreamConf.Priority, claims)
+		if err != nil {
+			logging.Debugc(c.Request.Context(), "admission rejected: class=%s, err=%v", class, err)
+			r.shed(c, r.admission.RetryAfter(), err)
 			return
 		}
+		defer release()
+	}
 
-		// 2. create agent
-		proxyAgent, err := agent.NewAgent(upstreamConf, gatewayConf)
+	// 13. concurrency limit
+	if r.concurrency != nil && upstreamConf.Concurrency != nil {
+		key := upstreamConf.Service + "|" + upstreamConf.Route
+		release, err := r.concurrency.Acquire(c.Request.Context(), key, upstreamConf.Concurrency)
 		if err != nil {
-			ginutils.ReturnError(c, http.StatusOK, err.Error())
+			r.shed(c, concurrency.RetryAfter(upstreamConf.Concurrency), err)
 			return
 		}
 
-		// 3. auth route
-		proxyAgent.Auth(c.Writer, c.Request)
+		start := time.Now()
+		defer func() {
+			release(time.Since(start), c.Writer.Status() >= http.StatusInternalServerError)
+		}()
+	}
+
+	// 14. quota, counted only for requests that will be proxied
+	if r.quota != nil && !r.quota.Limit(c.Writer, c.Request, upstreamConf) {
+		c.Abort()
+		return
+	}
+
+	// 15. proxy request
+	proxyAgent.ServeHTTP(c.Writer, c.Request)
+}
+
+// selectUpstream 按分流结果选择上游分组, 配置了会话保持时在分组内选择固定的上游地址
+func (r *proxyRouter) selectUpstream(c *gin.Context, upstreamConf *config.Upstream) string {
+	addresses := upstreamConf.Proxy
+	if upstreamConf.Split != nil {
+		group := split.Select(c.Request, upstreamConf.Service, upstreamConf.Split)
+		addresses = group.Proxy
+		upstreamConf.Group = group.Name
+		metrics.SplitRequests.Inc(upstreamConf.Service, upstreamConf.Route, group.Name)
+		tracing.SpanFromContext(c.Request.Context()).SetAttribute("litegate.upstream_group", group.Name)
+	}
+
+	if upstreamConf.Affinity != nil {
+		return r.affinity.Pick(c.Writer, c.Request, upstreamConf.Service, upstreamConf.Route, upstreamConf.Affinity, addresses)
+	}
+	return addresses.PickAddress()
+}
+
+// limitBody 按 Content-Length 拒绝过大的请求体, 并限制流式读取的字节数
+func (r *proxyRouter) limitBody(c *gin.Context, upstreamConf *config.Upstream) bool {
+	limit := upstreamConf.MaxBodyBytes
+	if limit == 0 && r.gatewayConf.RequestLimits != nil {
+		limit = r.gatewayConf.RequestLimits.MaxBodyBytes
+	}
+	if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return true
+	}
+
+	if c.Request.ContentLength > limit {
+		status := http.StatusRequestEntityTooLarge
+		middleware.AbortWithStatus(c, status, "request body too large")
+		return false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	return true
+}
 
-		// 4. proxy request
-		proxyAgent.ServeHTTP(c.Writer, c.Request)
+// shed 并发已满时拒绝请求, 返回 503 并提示重试时间
+func (r *proxyRouter) shed(c *gin.Context, retryAfter int, err error) {
+	if !errors.Is(err, concurrency.ErrLimitExceeded) && !errors.Is(err, concurrency.ErrQueueTimeout) {
+		middleware.ReturnError(c, err.Error())
+		return
 	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	middleware.AbortWithStatus(c, http.StatusServiceUnavailable, err.Error())
 }
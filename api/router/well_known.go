@@ -0,0 +1,23 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/superwhys/litegate/auth"
+)
+
+type wellKnownRouter struct {
+	tokenIssuer *auth.TokenIssuer
+}
+
+func WellKnownRouter(tokenIssuer *auth.TokenIssuer) *wellKnownRouter {
+	return &wellKnownRouter{tokenIssuer: tokenIssuer}
+}
+
+func (r *wellKnownRouter) Init(router gin.IRouter) {
+	// 上游服务通过该接口获取网关公钥, 验证网关签发的内部身份令牌
+	router.GET("/jwks.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, r.tokenIssuer.JWKS())
+	})
+}
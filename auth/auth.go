@@ -2,9 +2,16 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/superwhys/litegate/config"
 	"github.com/superwhys/litegate/utils"
 )
@@ -14,41 +21,121 @@ const (
 
 	PlaceHeader = "$header"
 	PlaceQuery  = "$query"
+	PlaceCookie = "$cookie"
+	PlacePath   = "$path"
+	PlaceForm   = "$form"
+	PlaceBody   = "$body"
+	PlaceClaim  = "$claim"
+
+	ClaimArrayJoin = "join"
+	ClaimArrayJSON = "json"
 )
 
+// ErrTokenEmpty 请求中没有携带令牌
+var ErrTokenEmpty = errors.New("token is empty")
+
+// RejectReason 返回身份验证失败的原因分类, 用于监控统计
+func RejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenEmpty):
+		return "missing_token"
+	case errors.Is(err, ErrTokenRevoked):
+		return "revoked"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	default:
+		return "invalid_token"
+	}
+}
+
 type (
 	ClaimContextKey string
-	Claims          map[string]string
+	Claims          map[string]any
 )
 
+// Get 获取声明值, 支持 realm_access.roles 形式的嵌套选择器
+func (c Claims) Get(key string) (any, bool) {
+	if value, ok := c[key]; ok {
+		return value, true
+	}
+	return utils.Select(map[string]any(c), key)
+}
+
+// String 获取声明值的字符串形式, 数组以逗号拼接
+func (c Claims) String(key string) (string, bool) {
+	value, ok := c.Get(key)
+	if !ok {
+		return "", false
+	}
+	return claimString(value, ClaimArrayJoin), true
+}
+
+type claimsContextKey struct{}
+
+// WithClaims 保存验证通过的完整声明, 供限流等后续处理使用
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+func ClaimsFromContext(ctx context.Context) Claims {
+	claims, _ := ctx.Value(claimsContextKey{}).(Claims)
+	return claims
+}
+
 type Authenticator interface {
 	Parse(r *http.Request) (Claims, error)
 }
 
-func NewAuthenticator(cfg *config.Auth) (Authenticator, error) {
+// NewAuthenticator 创建身份验证器, revocation 为空时不检查令牌吊销
+func NewAuthenticator(cfg *config.Auth, revocation *RevocationList) (Authenticator, error) {
 	if cfg == nil {
 		return nil, nil
 	}
 	switch cfg.Type {
 	case AuthTypeJWT:
-		return &jwtAuthenticator{cfg}, nil
+		return &jwtAuthenticator{config: cfg, revocation: revocation}, nil
 	default:
 		return nil, fmt.Errorf("unsupported auth type: %s", cfg.Type)
 	}
 }
 
-func InjectClaimsToContext(r *http.Request, claims Claims) context.Context {
+func InjectClaimsToContext(r *http.Request, auth *config.Auth, claims Claims) context.Context {
 	ctx := r.Context()
-	for place, claimKey := range claims {
-		value, ok := claims[claimKey]
-		if !ok || value == "" {
+	for place, claimKey := range auth.Claims {
+		value, ok := claims.Get(claimKey)
+		if !ok {
+			continue
+		}
+		valueStr := claimString(value, auth.ArrayFormat)
+		if valueStr == "" {
 			continue
 		}
-		ctx = context.WithValue(ctx, ClaimContextKey(place), value)
+		ctx = context.WithValue(ctx, ClaimContextKey(place), valueStr)
 	}
 	return ctx
 }
 
+// claimString 将声明值转换为字符串, 数组按 arrayFormat 拼接或编码为JSON, 对象始终编码为JSON
+func claimString(value any, arrayFormat string) string {
+	switch v := value.(type) {
+	case []any:
+		if arrayFormat == ClaimArrayJSON {
+			b, _ := json.Marshal(v)
+			return string(b)
+		}
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, claimString(item, arrayFormat))
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return toString(v)
+	}
+}
+
 func setValueToRequest(r *http.Request, claimKey string, value string) {
 	place, name := utils.ParsePlace(claimKey)
 	switch place {
@@ -61,7 +148,29 @@ func setValueToRequest(r *http.Request, claimKey string, value string) {
 	}
 }
 
+// StripClaimsFromRequest 清除请求中客户端自带的声明注入目标, 避免未注入的声明被客户端伪造后透传给上游
+func StripClaimsFromRequest(r *http.Request, places []string) {
+	var query url.Values
+	for _, claimKey := range places {
+		place, name := utils.ParsePlace(claimKey)
+		switch place {
+		case PlaceHeader:
+			r.Header.Del(name)
+		case PlaceQuery:
+			if query == nil {
+				query = r.URL.Query()
+			}
+			query.Del(name)
+		}
+	}
+	if query != nil {
+		r.URL.RawQuery = query.Encode()
+	}
+}
+
 func InjectClaimsToRequest(r *http.Request, auth *config.Auth) {
+	StripClaimsFromRequest(r, slices.Collect(maps.Keys(auth.Claims)))
+
 	for place := range auth.Claims {
 		value := r.Context().Value(ClaimContextKey(place))
 		if value == nil {
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/utils"
+)
+
+const (
+	AuthorizeOpEq          = "eq"
+	AuthorizeOpIn          = "in"
+	AuthorizeOpContains    = "contains"
+	AuthorizeOpContainsAll = "contains_all"
+)
+
+// RuleResult 单条授权规则的评估结果
+type RuleResult struct {
+	Claim    string   `json:"claim"`
+	Op       string   `json:"op"`
+	Expected []string `json:"expected"`
+	Actual   any      `json:"actual"`
+	Allowed  bool     `json:"allowed"`
+	Reason   string   `json:"reason,omitempty"`
+}
+
+// Decision 授权评估结果
+type Decision struct {
+	Allowed bool          `json:"allowed"`
+	Rules   []*RuleResult `json:"rules"`
+}
+
+// Authorize 依次评估所有授权规则, 全部通过才允许访问
+func Authorize(rules []config.AuthorizeRule, claims Claims, pathParams map[string]string) *Decision {
+	decision := &Decision{Allowed: true}
+	for _, rule := range rules {
+		result := evaluateRule(rule, claims, pathParams)
+		if !result.Allowed {
+			decision.Allowed = false
+		}
+		decision.Rules = append(decision.Rules, result)
+	}
+	return decision
+}
+
+func evaluateRule(rule config.AuthorizeRule, claims Claims, pathParams map[string]string) *RuleResult {
+	result := &RuleResult{
+		Claim:    rule.Claim,
+		Op:       rule.Op,
+		Expected: resolveValues(rule.Values, pathParams),
+	}
+
+	value, ok := claims.Get(rule.Claim)
+	if !ok {
+		result.Reason = "claim not found"
+		return result
+	}
+	result.Actual = value
+
+	switch rule.Op {
+	case AuthorizeOpEq:
+		result.Allowed = len(result.Expected) > 0 && toString(value) == result.Expected[0]
+	case AuthorizeOpIn:
+		result.Allowed = slices.Contains(result.Expected, toString(value))
+	case AuthorizeOpContains:
+		actual := claimValues(value)
+		result.Allowed = slices.ContainsFunc(result.Expected, func(v string) bool {
+			return slices.Contains(actual, v)
+		})
+	case AuthorizeOpContainsAll:
+		actual := claimValues(value)
+		result.Allowed = len(result.Expected) > 0 && !slices.ContainsFunc(result.Expected, func(v string) bool {
+			return !slices.Contains(actual, v)
+		})
+	default:
+		result.Reason = fmt.Sprintf("unsupported op: %s", rule.Op)
+		return result
+	}
+
+	if !result.Allowed {
+		result.Reason = "claim mismatch"
+	}
+	return result
+}
+
+// resolveValues 将 $path.xxx 形式的值替换为路径捕获的实际值
+func resolveValues(values []string, pathParams map[string]string) []string {
+	resolved := make([]string, 0, len(values))
+	for _, value := range values {
+		place, name := utils.ParsePlace(value)
+		if place == PlacePath {
+			value = pathParams[name]
+			if value == "" {
+				continue
+			}
+		}
+		resolved = append(resolved, value)
+	}
+	return resolved
+}
+
+// claimValues 将数组声明或空格分隔的字符串声明(如 OAuth2 scope)展开为列表
+func claimValues(value any) []string {
+	switch v := value.(type) {
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, toString(item))
+		}
+		return values
+	case []string:
+		return v
+	case string:
+		return strings.Fields(v)
+	default:
+		return []string{toString(v)}
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestAuthorize(t *testing.T) {
+	claims := Claims{
+		"sub":   "42",
+		"tier":  "premium",
+		"roles": []any{"user", "admin"},
+		"scope": "orders:read orders:write",
+		"realm_access": map[string]any{
+			"roles": []any{"billing"},
+		},
+	}
+	pathParams := map[string]string{"id": "42"}
+
+	testCases := []struct {
+		name    string
+		rule    config.AuthorizeRule
+		allowed bool
+	}{
+		{"eq match", config.AuthorizeRule{Claim: "tier", Op: AuthorizeOpEq, Values: []string{"premium"}}, true},
+		{"eq mismatch", config.AuthorizeRule{Claim: "tier", Op: AuthorizeOpEq, Values: []string{"free"}}, false},
+		{"eq path capture", config.AuthorizeRule{Claim: "sub", Op: AuthorizeOpEq, Values: []string{"$path.id"}}, true},
+		{"eq missing capture", config.AuthorizeRule{Claim: "sub", Op: AuthorizeOpEq, Values: []string{"$path.uid"}}, false},
+		{"in", config.AuthorizeRule{Claim: "tier", Op: AuthorizeOpIn, Values: []string{"gold", "premium"}}, true},
+		{"contains role", config.AuthorizeRule{Claim: "roles", Op: AuthorizeOpContains, Values: []string{"admin"}}, true},
+		{"contains missing role", config.AuthorizeRule{Claim: "roles", Op: AuthorizeOpContains, Values: []string{"root"}}, false},
+		{"contains nested role", config.AuthorizeRule{Claim: "realm_access.roles", Op: AuthorizeOpContains, Values: []string{"billing"}}, true},
+		{"contains_all scope", config.AuthorizeRule{Claim: "scope", Op: AuthorizeOpContainsAll, Values: []string{"orders:read", "orders:write"}}, true},
+		{"contains_all partial scope", config.AuthorizeRule{Claim: "scope", Op: AuthorizeOpContainsAll, Values: []string{"orders:read", "orders:delete"}}, false},
+		{"missing claim", config.AuthorizeRule{Claim: "org", Op: AuthorizeOpEq, Values: []string{"acme"}}, false},
+		{"unsupported op", config.AuthorizeRule{Claim: "tier", Op: "regex", Values: []string{".*"}}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decision := Authorize([]config.AuthorizeRule{tc.rule}, claims, pathParams)
+			assert.Equal(t, tc.allowed, decision.Allowed)
+			assert.Equal(t, 1, len(decision.Rules))
+		})
+	}
+}
+
+func TestAuthorize_AllRulesMustPass(t *testing.T) {
+	claims := Claims{"sub": "42", "roles": []any{"user"}}
+	rules := []config.AuthorizeRule{
+		{Claim: "sub", Op: AuthorizeOpEq, Values: []string{"$path.id"}},
+		{Claim: "roles", Op: AuthorizeOpContains, Values: []string{"admin"}},
+	}
+
+	decision := Authorize(rules, claims, map[string]string{"id": "42"})
+	assert.Equal(t, false, decision.Allowed)
+	assert.Equal(t, true, decision.Rules[0].Allowed)
+	assert.Equal(t, false, decision.Rules[1].Allowed)
+	assert.Equal(t, "claim mismatch", decision.Rules[1].Reason)
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/utils"
+)
+
+const (
+	DefaultUpstreamTokenTTL    = 60 * time.Second
+	DefaultUpstreamTokenTarget = config.DefaultUpstreamTokenTarget
+)
+
+type upstreamTokenContextKey struct{}
+
+// JWK 公钥的 JSON Web Key 表示
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// ECDSA
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// TokenIssuer 使用网关私钥签发上游内部身份令牌
+type TokenIssuer struct {
+	issuer string
+	method jwt.SigningMethod
+	key    crypto.Signer
+	jwk    *JWK
+}
+
+func NewTokenIssuer(cfg *config.TokenIssuerConfig) (*TokenIssuer, error) {
+	if cfg == nil {
+		cfg = &config.TokenIssuerConfig{}
+	}
+
+	var (
+		key crypto.Signer
+		err error
+	)
+	if cfg.KeyFile != "" {
+		key, err = loadSigningKey(cfg.KeyFile)
+	} else {
+		logging.Infof("token issuer key file not configured, generate ephemeral ECDSA P-256 key")
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	jwk, method, err := publicJWK(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	jwk.Kid = cfg.KeyID
+	if jwk.Kid == "" {
+		jwk.Kid, err = keyThumbprint(key.Public())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	issuer := cfg.Issuer
+	if issuer == "" {
+		issuer = "litegate"
+	}
+
+	return &TokenIssuer{
+		issuer: issuer,
+		method: method,
+		key:    key,
+		jwk:    jwk,
+	}, nil
+}
+
+func loadSigningKey(keyFile string) (crypto.Signer, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem key file: %s", keyFile)
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type: %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key format: %s", keyFile)
+}
+
+func publicJWK(pub crypto.PublicKey) (*JWK, jwt.SigningMethod, error) {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return &JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			N:   encode(key.N.Bytes()),
+			E:   encode(big.NewInt(int64(key.E)).Bytes()),
+		}, jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		var method jwt.SigningMethod
+		switch key.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, nil, errors.New("unsupported ecdsa curve")
+		}
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			return nil, nil, err
+		}
+		// 非压缩格式: 0x04 || X || Y
+		point := ecdhKey.Bytes()
+		size := (len(point) - 1) / 2
+		return &JWK{
+			Kty: "EC",
+			Use: "sig",
+			Alg: method.Alg(),
+			Crv: key.Curve.Params().Name,
+			X:   encode(point[1 : 1+size]),
+			Y:   encode(point[1+size:]),
+		}, method, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported public key type: %T", pub)
+	}
+}
+
+func keyThumbprint(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// JWKS 返回用于验证内部身份令牌的公钥集合
+func (ti *TokenIssuer) JWKS() *JWKS {
+	return &JWKS{Keys: []*JWK{ti.jwk}}
+}
+
+// Mint 根据客户端令牌声明签发内部身份令牌
+func (ti *TokenIssuer) Mint(cfg *config.UpstreamToken, audience string, claims Claims) (string, error) {
+	ttl := DefaultUpstreamTokenTTL
+	if cfg.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(cfg.TTL)
+		if err != nil {
+			return "", fmt.Errorf("parse upstream token ttl error: %w", err)
+		}
+	}
+
+	mapping := cfg.Claims
+	if len(mapping) == 0 {
+		mapping = map[string]string{"sub": "sub"}
+	}
+
+	now := time.Now()
+	tokenClaims := jwt.MapClaims{}
+	for name, claimKey := range mapping {
+		if value, ok := claims.Get(claimKey); ok {
+			tokenClaims[name] = value
+		}
+	}
+	tokenClaims["iss"] = ti.issuer
+	tokenClaims["aud"] = audience
+	tokenClaims["iat"] = now.Unix()
+	tokenClaims["nbf"] = now.Unix()
+	tokenClaims["exp"] = now.Add(ttl).Unix()
+	tokenClaims["jti"] = utils.RandomID()
+
+	token := jwt.NewWithClaims(ti.method, tokenClaims)
+	token.Header["kid"] = ti.jwk.Kid
+	return token.SignedString(ti.key)
+}
+
+func WithUpstreamToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, upstreamTokenContextKey{}, token)
+}
+
+// InjectUpstreamTokenToRequest 将签发的内部身份令牌写入请求, 注入到 Authorization 头时使用 Bearer 形式
+func InjectUpstreamTokenToRequest(r *http.Request, cfg *config.UpstreamToken) {
+	token, ok := r.Context().Value(upstreamTokenContextKey{}).(string)
+	if !ok || token == "" {
+		return
+	}
+
+	target := cfg.TargetPlace()
+	if place, name := utils.ParsePlace(target); place == PlaceHeader && strings.EqualFold(name, "Authorization") {
+		token = bearerPrefix + token
+	}
+	setValueToRequest(r, target, token)
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/superwhys/litegate/config"
+)
+
+// publicKeyFromJWK 模拟上游服务通过 JWKS 还原网关公钥
+func publicKeyFromJWK(t *testing.T, jwk *JWK) any {
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decode jwk error: %v", err)
+		}
+		return b
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decode(jwk.N)),
+			E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64()),
+		}
+	case "EC":
+		point := append([]byte{0x04}, decode(jwk.X)...)
+		point = append(point, decode(jwk.Y)...)
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			t.Fatalf("parse ec public key error: %v", err)
+		}
+		return key
+	}
+	t.Fatalf("unexpected kty: %s", jwk.Kty)
+	return nil
+}
+
+func TestTokenIssuer_Mint(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "gateway.pem")
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	if err := os.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		cfg  *config.TokenIssuerConfig
+		kty  string
+		alg  string
+	}{
+		{"ephemeral ecdsa", &config.TokenIssuerConfig{Issuer: "litegate"}, "EC", "ES256"},
+		{"rsa key file", &config.TokenIssuerConfig{Issuer: "litegate", KeyFile: keyFile, KeyID: "k1"}, "RSA", "RS256"},
+	}
+
+	clientClaims := Claims{
+		"sub":          "alice",
+		"email":        "alice@example.com",
+		"realm_access": map[string]any{"roles": []any{"admin"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			issuer, err := NewTokenIssuer(tc.cfg)
+			if err != nil {
+				t.Fatalf("NewTokenIssuer error: %v", err)
+			}
+
+			jwks := issuer.JWKS()
+			assert.Equal(t, 1, len(jwks.Keys))
+			assert.Equal(t, tc.kty, jwks.Keys[0].Kty)
+			assert.Equal(t, tc.alg, jwks.Keys[0].Alg)
+			if tc.cfg.KeyID != "" {
+				assert.Equal(t, tc.cfg.KeyID, jwks.Keys[0].Kid)
+			}
+
+			token, err := issuer.Mint(&config.UpstreamToken{
+				TTL:    "30s",
+				Claims: map[string]string{"user": "sub", "roles": "realm_access.roles"},
+			}, "orders", clientClaims)
+			if err != nil {
+				t.Fatalf("Mint error: %v", err)
+			}
+
+			claims := jwt.MapClaims{}
+			tok, err := jwt.ParseWithClaims(token, claims, func(tok *jwt.Token) (any, error) {
+				assert.Equal(t, jwks.Keys[0].Kid, tok.Header["kid"])
+				return publicKeyFromJWK(t, jwks.Keys[0]), nil
+			}, jwt.WithAudience("orders"), jwt.WithIssuer("litegate"), jwt.WithValidMethods([]string{tc.alg}))
+			if err != nil {
+				t.Fatalf("verify minted token error: %v", err)
+			}
+			assert.Equal(t, true, tok.Valid)
+			assert.Equal(t, "alice", claims["user"])
+			assert.Equal(t, []any{"admin"}, claims["roles"])
+			assert.Equal(t, nil, claims["email"])
+
+			exp, err := claims.GetExpirationTime()
+			if err != nil {
+				t.Fatalf("GetExpirationTime error: %v", err)
+			}
+			if time.Until(exp.Time) > 30*time.Second {
+				t.Errorf("unexpected token ttl: %v", time.Until(exp.Time))
+			}
+		})
+	}
+}
+
+func TestInjectUpstreamTokenToRequest(t *testing.T) {
+	testCases := []struct {
+		target string
+		header string
+		want   string
+	}{
+		{"", "Authorization", "Bearer minted"},
+		{"$header.X-Internal-Token", "X-Internal-Token", "minted"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil)
+			req.Header.Set("Authorization", "Bearer client")
+			req = req.WithContext(WithUpstreamToken(req.Context(), "minted"))
+
+			InjectUpstreamTokenToRequest(req, &config.UpstreamToken{Target: tc.target})
+			assert.Equal(t, tc.want, req.Header.Get(tc.header))
+			if !strings.EqualFold(tc.header, "Authorization") {
+				assert.Equal(t, "Bearer client", req.Header.Get("Authorization"))
+			}
+		})
+	}
+}
@@ -3,31 +3,24 @@ package auth
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/superwhys/litegate/config"
-	"github.com/superwhys/litegate/utils"
 )
 
 type jwtAuthenticator struct {
-	config *config.Auth
-}
-
-func (j *jwtAuthenticator) getValueFromRequest(r *http.Request, claimKey string) string {
-	place, name := utils.ParsePlace(claimKey)
-	switch place {
-	case PlaceHeader:
-		return r.Header.Get(name)
-	case PlaceQuery:
-		return r.URL.Query().Get(name)
-	}
-	return ""
+	config     *config.Auth
+	revocation *RevocationList
 }
 
 func (j *jwtAuthenticator) Parse(r *http.Request) (Claims, error) {
-	token := j.getValueFromRequest(r, j.config.Source)
+	token, err := getValueFromRequest(r, j.config.Source)
+	if err != nil {
+		return nil, fmt.Errorf("read token error: %w", err)
+	}
 	if token == "" {
-		return nil, fmt.Errorf("token is empty")
+		return nil, ErrTokenEmpty
 	}
 
 	tok, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
@@ -46,16 +39,31 @@ func (j *jwtAuthenticator) Parse(r *http.Request) (Claims, error) {
 		return nil, fmt.Errorf("unexpected claims type")
 	}
 
+	if j.revocation != nil && j.isRevoked(claims) {
+		return nil, ErrTokenRevoked
+	}
+
 	result := make(Claims, len(claims))
 	for k, v := range claims {
 		if v == nil {
 			continue
 		}
-		result[k] = toString(v)
+		result[k] = v
 	}
 	return result, nil
 }
 
+func (j *jwtAuthenticator) isRevoked(claims jwt.MapClaims) bool {
+	jti, _ := claims["jti"].(string)
+	sub, _ := claims.GetSubject()
+
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
+	}
+	return j.revocation.IsRevoked(jti, sub, issuedAt)
+}
+
 func toString(v any) string {
 	switch t := v.(type) {
 	case string:
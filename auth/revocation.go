@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/config"
+)
+
+var ErrTokenRevoked = errors.New("token revoked")
+
+// RevocationData 吊销列表文件内容
+type RevocationData struct {
+	// 已吊销的 jti -> 令牌过期时间, 过期后自动清理
+	JTIs map[string]time.Time `json:"jtis"`
+	// 用户(sub) -> 吊销时间点, 该时间点之前签发的令牌全部失效, 用于登出所有会话
+	Subjects map[string]time.Time `json:"subjects"`
+}
+
+// RevocationList 令牌吊销列表
+// 读操作通过原子替换的快照完成, 请求路径上的查询不需要加锁
+type RevocationList struct {
+	file     string
+	snapshot atomic.Pointer[RevocationData]
+
+	// mu 串行化吊销列表的修改与文件写入
+	mu       sync.Mutex
+	watcher  *fsnotify.Watcher
+	stopChan chan struct{}
+}
+
+func NewRevocationList(cfg *config.RevocationConfig) (*RevocationList, error) {
+	rl := &RevocationList{stopChan: make(chan struct{})}
+	if cfg != nil {
+		rl.file = cfg.File
+	}
+	rl.snapshot.Store(&RevocationData{
+		JTIs:     make(map[string]time.Time),
+		Subjects: make(map[string]time.Time),
+	})
+
+	if rl.file == "" {
+		return rl, nil
+	}
+	if err := rl.load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return rl, nil
+}
+
+func (rl *RevocationList) load() error {
+	data, err := os.ReadFile(rl.file)
+	if err != nil {
+		return err
+	}
+
+	revocation := new(RevocationData)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, revocation); err != nil {
+			return err
+		}
+	}
+	if revocation.JTIs == nil {
+		revocation.JTIs = make(map[string]time.Time)
+	}
+	if revocation.Subjects == nil {
+		revocation.Subjects = make(map[string]time.Time)
+	}
+	pruneExpired(revocation, time.Now())
+
+	rl.snapshot.Store(revocation)
+	logging.Infof("load revocation list: %d jtis, %d subjects", len(revocation.JTIs), len(revocation.Subjects))
+	return nil
+}
+
+func pruneExpired(revocation *RevocationData, now time.Time) {
+	for jti, expiresAt := range revocation.JTIs {
+		if !expiresAt.IsZero() && expiresAt.Before(now) {
+			delete(revocation.JTIs, jti)
+		}
+	}
+}
+
+// IsRevoked 判断令牌是否已被吊销, issuedAt 为零值时只要用户被吊销即视为失效
+func (rl *RevocationList) IsRevoked(jti, sub string, issuedAt time.Time) bool {
+	revocation := rl.snapshot.Load()
+	if jti != "" {
+		if _, ok := revocation.JTIs[jti]; ok {
+			return true
+		}
+	}
+	if sub != "" {
+		if before, ok := revocation.Subjects[sub]; ok {
+			return issuedAt.IsZero() || !issuedAt.After(before)
+		}
+	}
+	return false
+}
+
+// Snapshot 返回当前吊销列表
+func (rl *RevocationList) Snapshot() *RevocationData {
+	return rl.snapshot.Load()
+}
+
+// RevokeJTI 吊销单个令牌, expiresAt 为令牌过期时间, 过期后记录会被清理
+func (rl *RevocationList) RevokeJTI(jti string, expiresAt time.Time) error {
+	return rl.update(func(revocation *RevocationData) {
+		revocation.JTIs[jti] = expiresAt
+	})
+}
+
+// RevokeSubject 吊销用户在 before 之前签发的所有令牌
+func (rl *RevocationList) RevokeSubject(sub string, before time.Time) error {
+	return rl.update(func(revocation *RevocationData) {
+		revocation.Subjects[sub] = before
+	})
+}
+
+func (rl *RevocationList) UnrevokeJTI(jti string) error {
+	return rl.update(func(revocation *RevocationData) {
+		delete(revocation.JTIs, jti)
+	})
+}
+
+func (rl *RevocationList) UnrevokeSubject(sub string) error {
+	return rl.update(func(revocation *RevocationData) {
+		delete(revocation.Subjects, sub)
+	})
+}
+
+// update 基于当前快照生成新快照, 并在配置了文件时持久化
+func (rl *RevocationList) update(modify func(revocation *RevocationData)) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	current := rl.snapshot.Load()
+	revocation := &RevocationData{
+		JTIs:     maps.Clone(current.JTIs),
+		Subjects: maps.Clone(current.Subjects),
+	}
+	modify(revocation)
+	pruneExpired(revocation, time.Now())
+
+	if rl.file != "" {
+		if err := rl.save(revocation); err != nil {
+			return err
+		}
+	}
+	rl.snapshot.Store(revocation)
+	return nil
+}
+
+func (rl *RevocationList) save(revocation *RevocationData) error {
+	data, err := json.MarshalIndent(revocation, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rl.file), 0755); err != nil {
+		return err
+	}
+
+	tmpFile := rl.file + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, rl.file)
+}
+
+// Watch 监听吊销列表文件变化并热加载
+func (rl *RevocationList) Watch() error {
+	if rl.file == "" {
+		return nil
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// 监听所在目录, 以便处理编辑器及原子替换写入产生的重命名事件
+	dir := filepath.Dir(rl.file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		watcher.Close()
+		return err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+	rl.watcher = watcher
+
+	go rl.watchLoop()
+
+	logging.Infof("start watch revocation file: %s", rl.file)
+	return nil
+}
+
+func (rl *RevocationList) StopWatch() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.watcher != nil {
+		close(rl.stopChan)
+		rl.watcher.Close()
+		rl.watcher = nil
+		logging.Infof("stop watch revocation file: %s", rl.file)
+	}
+}
+
+func (rl *RevocationList) watchLoop() {
+	watcher := rl.watcher
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(rl.file) {
+				continue
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+				rl.mu.Lock()
+				err := rl.load()
+				rl.mu.Unlock()
+				if err != nil {
+					logging.Errorf("reload revocation file error: %s, %v", rl.file, err)
+				}
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logging.Errorf("watch revocation file error: %v", err)
+		case <-rl.stopChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestRevocationList_IsRevoked(t *testing.T) {
+	rl, err := NewRevocationList(nil)
+	if err != nil {
+		t.Fatalf("NewRevocationList error: %v", err)
+	}
+
+	logoutAt := time.Now()
+	if err := rl.RevokeJTI("leaked", logoutAt.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeJTI error: %v", err)
+	}
+	if err := rl.RevokeJTI("expired", logoutAt.Add(-time.Hour)); err != nil {
+		t.Fatalf("RevokeJTI error: %v", err)
+	}
+	if err := rl.RevokeSubject("alice", logoutAt); err != nil {
+		t.Fatalf("RevokeSubject error: %v", err)
+	}
+
+	assert.Equal(t, true, rl.IsRevoked("leaked", "bob", logoutAt))
+	// 已过期令牌的吊销记录会被清理
+	assert.Equal(t, 1, len(rl.Snapshot().JTIs))
+	assert.Equal(t, false, rl.IsRevoked("expired", "bob", logoutAt))
+
+	assert.Equal(t, true, rl.IsRevoked("", "alice", logoutAt.Add(-time.Minute)))
+	assert.Equal(t, true, rl.IsRevoked("", "alice", time.Time{}))
+	assert.Equal(t, false, rl.IsRevoked("", "alice", logoutAt.Add(time.Minute)))
+
+	if err := rl.UnrevokeSubject("alice"); err != nil {
+		t.Fatalf("UnrevokeSubject error: %v", err)
+	}
+	assert.Equal(t, false, rl.IsRevoked("", "alice", logoutAt.Add(-time.Minute)))
+}
+
+func TestRevocationList_FileReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "revocation.json")
+	rl, err := NewRevocationList(&config.RevocationConfig{File: file})
+	if err != nil {
+		t.Fatalf("NewRevocationList error: %v", err)
+	}
+	if err := rl.Watch(); err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	defer rl.StopWatch()
+
+	// 通过接口吊销的记录会持久化到文件
+	if err := rl.RevokeJTI("leaked", time.Time{}); err != nil {
+		t.Fatalf("RevokeJTI error: %v", err)
+	}
+	reloaded, err := NewRevocationList(&config.RevocationConfig{File: file})
+	if err != nil {
+		t.Fatalf("NewRevocationList error: %v", err)
+	}
+	assert.Equal(t, true, reloaded.IsRevoked("leaked", "", time.Time{}))
+
+	// 直接修改文件后热加载
+	content := `{"jtis": {"other": "0001-01-01T00:00:00Z"}, "subjects": {}}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !rl.IsRevoked("other", "", time.Time{}) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, true, rl.IsRevoked("other", "", time.Time{}))
+	assert.Equal(t, false, rl.IsRevoked("leaked", "", time.Time{}))
+}
+
+func TestJwtAuthenticator_RejectsRevokedToken(t *testing.T) {
+	rl, err := NewRevocationList(nil)
+	if err != nil {
+		t.Fatalf("NewRevocationList error: %v", err)
+	}
+	authenticator, err := NewAuthenticator(&config.Auth{
+		Type:   AuthTypeJWT,
+		Source: "$header.Authorization",
+		Secret: "secret",
+	}, rl)
+	if err != nil {
+		t.Fatalf("NewAuthenticator error: %v", err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"jti": "t1",
+		"sub": "alice",
+		"iat": time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("SignedString error: %v", err)
+	}
+
+	parse := func() error {
+		req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		_, err := authenticator.Parse(req)
+		return err
+	}
+
+	assert.Equal(t, nil, parse())
+
+	if err := rl.RevokeJTI("t1", time.Time{}); err != nil {
+		t.Fatalf("RevokeJTI error: %v", err)
+	}
+	assert.Equal(t, true, errors.Is(parse(), ErrTokenRevoked))
+
+	if err := rl.UnrevokeJTI("t1"); err != nil {
+		t.Fatalf("UnrevokeJTI error: %v", err)
+	}
+	if err := rl.RevokeSubject("alice", time.Now()); err != nil {
+		t.Fatalf("RevokeSubject error: %v", err)
+	}
+	assert.Equal(t, true, errors.Is(parse(), ErrTokenRevoked))
+}
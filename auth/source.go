@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/superwhys/litegate/utils"
+)
+
+const bearerPrefix = "Bearer "
+
+type pathParamsContextKey struct{}
+
+// WithPathParams 将路由匹配得到的路径捕获保存到 context 中, 供 $path 来源使用
+func WithPathParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, pathParamsContextKey{}, params)
+}
+
+func PathParamsFromContext(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(pathParamsContextKey{}).(map[string]string)
+	return params
+}
+
+// getValueFromRequest 按照 $header.xxx / $query.xxx / $cookie.xxx / $path.xxx / $form.xxx / $body.a.b 读取请求中的值
+// 只有读取请求体失败时返回错误, 如请求体超过大小限制时返回 *http.MaxBytesError
+func getValueFromRequest(r *http.Request, source string) (string, error) {
+	place, name := utils.ParsePlace(source)
+	switch place {
+	case PlaceHeader:
+		value := r.Header.Get(name)
+		if strings.EqualFold(name, "Authorization") && len(value) >= len(bearerPrefix) &&
+			strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+			value = strings.TrimSpace(value[len(bearerPrefix):])
+		}
+		return value, nil
+	case PlaceQuery:
+		return r.URL.Query().Get(name), nil
+	case PlaceCookie:
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return "", nil
+		}
+		return cookie.Value, nil
+	case PlacePath:
+		return PathParamsFromContext(r.Context())[name], nil
+	case PlaceForm:
+		body, err := peekBody(r)
+		if err != nil || body == nil {
+			return "", err
+		}
+		clone := r.Clone(r.Context())
+		clone.Body = io.NopCloser(bytes.NewReader(body))
+		return clone.PostFormValue(name), nil
+	case PlaceBody:
+		body, err := peekBody(r)
+		if err != nil || body == nil {
+			return "", err
+		}
+		var data any
+		if err := json.Unmarshal(body, &data); err != nil {
+			return "", nil
+		}
+		value, ok := utils.Select(data, name)
+		if !ok {
+			return "", nil
+		}
+		return toString(value), nil
+	}
+	return "", nil
+}
+
+// peekBody 读取请求体并将其还原, 以便后续继续转发给上游
+// 请求体大小由网关按 max_body_bytes 限制, 超出时返回 *http.MaxBytesError
+func peekBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestGetValueFromRequest(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://proxy.example.com/users/42?token=q", nil)
+		req.Header.Set("Authorization", "bearer h")
+		req.Header.Set("X-Token", "Bearer raw")
+		req.AddCookie(&http.Cookie{Name: "session", Value: "c"})
+		return req.WithContext(WithPathParams(req.Context(), map[string]string{"token": "p"}))
+	}
+
+	testCases := []struct {
+		source string
+		want   string
+	}{
+		{"$header.Authorization", "h"},
+		{"$header.X-Token", "Bearer raw"},
+		{"$query.token", "q"},
+		{"$cookie.session", "c"},
+		{"$cookie.missing", ""},
+		{"$path.token", "p"},
+		{"$unknown.token", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			value, err := getValueFromRequest(newRequest(), tc.source)
+			if err != nil {
+				t.Fatalf("getValueFromRequest error: %v", err)
+			}
+			assert.Equal(t, tc.want, value)
+		})
+	}
+}
+
+func TestGetValueFromRequest_BodyIsRestored(t *testing.T) {
+	testCases := []struct {
+		source      string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"$form.token", "application/x-www-form-urlencoded", "token=f&x=1", "f"},
+		{"$body.auth.token", "application/json", `{"auth":{"token":"j"}}`, "j"},
+		{"$body.tokens.1", "application/json", `{"tokens":["a","b"]}`, "b"},
+		{"$body.auth.token", "application/json", `not json`, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://proxy.example.com/login", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			value, err := getValueFromRequest(req, tc.source)
+			if err != nil {
+				t.Fatalf("getValueFromRequest error: %v", err)
+			}
+			assert.Equal(t, tc.want, value)
+
+			// 请求体需要完整保留给上游
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("ReadAll error: %v", err)
+			}
+			assert.Equal(t, tc.body, string(body))
+		})
+	}
+}
+
+func TestGetValueFromRequest_BodyTooLarge(t *testing.T) {
+	body := `{"auth":{"token":"j"},"padding":"` + strings.Repeat("x", 64) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "http://proxy.example.com/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	// 网关按 max_body_bytes 限制请求体, 读取令牌时同样受该限制
+	req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, 32)
+
+	_, err := getValueFromRequest(req, "$body.auth.token")
+	var maxBytesErr *http.MaxBytesError
+	assert.Equal(t, true, errors.As(err, &maxBytesErr))
+
+	// 未超出 max_body_bytes 时可以从超过 1MB 的请求体中读取令牌
+	body = `{"auth":{"token":"j"},"padding":"` + strings.Repeat("x", 2<<20) + `"}`
+	req = httptest.NewRequest(http.MethodPost, "http://proxy.example.com/login", strings.NewReader(body))
+	req.Body = http.MaxBytesReader(httptest.NewRecorder(), req.Body, 4<<20)
+	value, err := getValueFromRequest(req, "$body.auth.token")
+	if err != nil {
+		t.Fatalf("getValueFromRequest error: %v", err)
+	}
+	assert.Equal(t, "j", value)
+}
+
+func TestInjectClaimsToContext_NestedAndArrayClaims(t *testing.T) {
+	claims := Claims{
+		"sub":          "42",
+		"org":          map[string]any{"id": "acme"},
+		"realm_access": map[string]any{"roles": []any{"user", "admin"}},
+	}
+
+	testCases := []struct {
+		arrayFormat string
+		roles       string
+	}{
+		{"", "user,admin"},
+		{ClaimArrayJoin, "user,admin"},
+		{ClaimArrayJSON, `["user","admin"]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.arrayFormat, func(t *testing.T) {
+			cfg := &config.Auth{
+				ArrayFormat: tc.arrayFormat,
+				Claims: map[string]string{
+					"$header.X-User":  "sub",
+					"$header.X-Org":   "org.id",
+					"$header.X-Roles": "realm_access.roles",
+					"$header.X-Realm": "realm_access",
+					"$header.X-None":  "org.name",
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil)
+			ctx := InjectClaimsToContext(req, cfg, claims)
+
+			assert.Equal(t, "42", ctx.Value(ClaimContextKey("$header.X-User")))
+			assert.Equal(t, "acme", ctx.Value(ClaimContextKey("$header.X-Org")))
+			assert.Equal(t, tc.roles, ctx.Value(ClaimContextKey("$header.X-Roles")))
+			assert.Equal(t, `{"roles":["user","admin"]}`, ctx.Value(ClaimContextKey("$header.X-Realm")))
+			assert.Equal(t, nil, ctx.Value(ClaimContextKey("$header.X-None")))
+		})
+	}
+}
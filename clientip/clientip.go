@@ -0,0 +1,129 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/utils"
+)
+
+const (
+	HeaderForwardedFor = "X-Forwarded-For"
+	HeaderRealIP       = "X-Real-IP"
+)
+
+type clientIPContextKey struct{}
+
+// Resolver 根据受信任代理列表解析客户端真实IP
+// 只有直连对端是受信任代理时才读取转发头, X-Forwarded-For 从右向左跳过受信任代理取第一个地址
+type Resolver struct {
+	trusted []netip.Prefix
+	headers []string
+}
+
+func NewResolver(gatewayConf *config.GatewayConfig) (*Resolver, error) {
+	trusted, err := utils.ParsePrefixes(gatewayConf.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("parse trusted proxies error: %w", err)
+	}
+
+	headers := gatewayConf.ClientIPHeaders
+	if len(headers) == 0 {
+		headers = []string{HeaderForwardedFor, HeaderRealIP}
+	}
+	return &Resolver{trusted: trusted, headers: headers}, nil
+}
+
+// IsTrusted 判断地址是否为受信任代理
+func (rv *Resolver) IsTrusted(ip string) bool {
+	if rv == nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return utils.ContainsAddr(rv.trusted, addr)
+}
+
+// Resolve 解析请求的客户端IP
+func (rv *Resolver) Resolve(r *http.Request) string {
+	peer := utils.RemoteIP(r)
+	if !rv.IsTrusted(peer) {
+		return peer
+	}
+
+	for _, header := range rv.headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+		if ip := rv.fromList(values); ip != "" {
+			return ip
+		}
+	}
+	return peer
+}
+
+// fromList 从逗号分隔的地址列表中由右向左取第一个非受信任代理的地址
+func (rv *Resolver) fromList(values []string) string {
+	var ips []string
+	for _, value := range values {
+		for _, ip := range strings.Split(value, ",") {
+			ips = append(ips, strings.TrimSpace(ip))
+		}
+	}
+
+	leftmost := ""
+	for i := len(ips) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(ips[i])
+		if err != nil {
+			// 无法解析的地址之前的内容不可信
+			break
+		}
+		leftmost = addr.Unmap().String()
+		if !utils.ContainsAddr(rv.trusted, addr) {
+			return leftmost
+		}
+	}
+	return leftmost
+}
+
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPContextKey{}, ip)
+}
+
+// FromRequest 返回已解析的客户端IP, 未解析时返回直连对端IP
+func FromRequest(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return utils.RemoteIP(r)
+}
+
+// Allowed 判断客户端IP是否满足访问控制规则
+func Allowed(acl *config.IPACL, ip string) (bool, error) {
+	if acl == nil {
+		return true, nil
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false, nil
+	}
+
+	allow, deny, err := acl.Prefixes()
+	if err != nil {
+		return false, err
+	}
+	if utils.ContainsAddr(deny, addr) {
+		return false, nil
+	}
+	if len(acl.Allow) == 0 {
+		return true, nil
+	}
+	return utils.ContainsAddr(allow, addr), nil
+}
@@ -0,0 +1,102 @@
+package clientip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	resolver, err := NewResolver(&config.GatewayConfig{TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"}})
+	if err != nil {
+		t.Fatalf("new resolver error: %v", err)
+	}
+
+	newRequest := func(remoteAddr string, headers map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+
+	// 非受信任对端伪造的转发头被忽略
+	r := newRequest("203.0.113.9:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"})
+	assert.Equal(t, "203.0.113.9", resolver.Resolve(r))
+
+	// 从右向左跳过受信任代理, 左侧客户端伪造的地址被忽略
+	r = newRequest("10.0.0.2:1234", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.7, 192.168.1.1"})
+	assert.Equal(t, "198.51.100.7", resolver.Resolve(r))
+
+	r = newRequest("192.168.1.1:1234", map[string]string{"X-Real-IP": "198.51.100.8"})
+	assert.Equal(t, "198.51.100.8", resolver.Resolve(r))
+
+	r = newRequest("10.0.0.2:1234", nil)
+	assert.Equal(t, "10.0.0.2", resolver.Resolve(r))
+}
+
+func TestAllowed(t *testing.T) {
+	acl := &config.IPACL{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.1.0.0/16"}}
+
+	for ip, expected := range map[string]bool{
+		"10.0.0.1":    true,
+		"10.1.2.3":    false,
+		"203.0.113.1": false,
+	} {
+		allowed, err := Allowed(acl, ip)
+		if err != nil {
+			t.Fatalf("check ip acl error: %v", err)
+		}
+		assert.Equal(t, expected, allowed)
+	}
+
+	allowed, _ := Allowed(&config.IPACL{Deny: []string{"203.0.113.1"}}, "203.0.113.2")
+	assert.Equal(t, true, allowed)
+
+	_, err := Allowed(&config.IPACL{Allow: []string{"not-an-ip"}}, "10.0.0.1")
+	if err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	rd := bufio.NewReader(strings.NewReader("PROXY TCP4 198.51.100.7 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"))
+	addr, err := readProxyHeader(rd)
+	if err != nil {
+		t.Fatalf("read v1 header error: %v", err)
+	}
+	assert.Equal(t, "198.51.100.7:56324", addr.String())
+	rest, _ := rd.ReadString('\n')
+	assert.Equal(t, "GET / HTTP/1.1\r\n", rest)
+
+	var v2 bytes.Buffer
+	v2.Write(proxyProtocolV2Sig)
+	v2.Write([]byte{0x21, 0x11})
+	binary.Write(&v2, binary.BigEndian, uint16(12))
+	v2.Write([]byte{198, 51, 100, 8, 10, 0, 0, 1})
+	binary.Write(&v2, binary.BigEndian, uint16(40000))
+	binary.Write(&v2, binary.BigEndian, uint16(443))
+	v2.WriteString("GET / HTTP/1.1\r\n")
+
+	rd = bufio.NewReader(&v2)
+	addr, err = readProxyHeader(rd)
+	if err != nil {
+		t.Fatalf("read v2 header error: %v", err)
+	}
+	assert.Equal(t, "198.51.100.8:40000", addr.String())
+
+	// 没有协议头时不消费任何数据
+	rd = bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n"))
+	addr, _ = readProxyHeader(rd)
+	assert.Equal(t, nil, addr)
+	rest, _ = rd.ReadString('\n')
+	assert.Equal(t, "GET / HTTP/1.1\r\n", rest)
+}
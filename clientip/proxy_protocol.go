@@ -0,0 +1,169 @@
+package clientip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const proxyProtocolTimeout = 5 * time.Second
+
+var (
+	proxyProtocolV1Prefix = []byte("PROXY ")
+	proxyProtocolV2Sig    = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// proxyProtocolListener 解析 PROXY protocol v1/v2 协议头, 将连接的远端地址替换为协议头中的源地址
+// 仅解析来自受信任代理的协议头, 其他连接原样处理
+type proxyProtocolListener struct {
+	net.Listener
+	resolver *Resolver
+}
+
+func NewProxyProtocolListener(ln net.Listener, resolver *Resolver) net.Listener {
+	return &proxyProtocolListener{Listener: ln, resolver: resolver}
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, rd: bufio.NewReader(conn), resolver: l.resolver}, nil
+}
+
+// proxyConn 在首次读取或获取远端地址时解析协议头, 避免阻塞 Accept
+type proxyConn struct {
+	net.Conn
+	rd       *bufio.Reader
+	resolver *Resolver
+
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		peer, _, _ := net.SplitHostPort(c.Conn.RemoteAddr().String())
+		if !c.resolver.IsTrusted(peer) {
+			return
+		}
+
+		c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+		c.remoteAddr, c.err = readProxyHeader(c.rd)
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.rd.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader 读取协议头, 没有协议头或为 LOCAL/UNKNOWN 时返回 nil 地址
+func readProxyHeader(rd *bufio.Reader) (net.Addr, error) {
+	prefix, err := rd.Peek(len(proxyProtocolV1Prefix))
+	if err != nil {
+		// 连接在发送足够数据前关闭, 交由上层处理
+		return nil, nil
+	}
+	if bytes.Equal(prefix, proxyProtocolV1Prefix) {
+		return readProxyHeaderV1(rd)
+	}
+
+	if prefix[0] != proxyProtocolV2Sig[0] {
+		return nil, nil
+	}
+	sig, err := rd.Peek(len(proxyProtocolV2Sig))
+	if err == nil && bytes.Equal(sig, proxyProtocolV2Sig) {
+		return readProxyHeaderV2(rd)
+	}
+	return nil, nil
+}
+
+// readProxyHeaderV1 例如: PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readProxyHeaderV1(rd *bufio.Reader) (net.Addr, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) > 107 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errors.New("invalid proxy protocol v1 header")
+	}
+
+	fields := strings.Fields(strings.TrimSuffix(line, "\r\n"))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid proxy protocol v1 header: %q", line)
+	}
+
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+func readProxyHeaderV2(rd *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(rd, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, errors.New("invalid proxy protocol v2 version")
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(rd, body); err != nil {
+		return nil, err
+	}
+
+	// LOCAL 命令为代理自身的健康检查等连接
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+
+	switch header[13] >> 4 {
+	case 1: // AF_INET
+		if len(body) < 12 {
+			return nil, errors.New("invalid proxy protocol v2 ipv4 address")
+		}
+		addr := netip.AddrFrom4([4]byte(body[0:4]))
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, binary.BigEndian.Uint16(body[8:10]))), nil
+	case 2: // AF_INET6
+		if len(body) < 36 {
+			return nil, errors.New("invalid proxy protocol v2 ipv6 address")
+		}
+		addr := netip.AddrFrom16([16]byte(body[0:16])).Unmap()
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, binary.BigEndian.Uint16(body[32:34]))), nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,107 @@
+package concurrency
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/superwhys/litegate/config"
+)
+
+// adjuster 根据单次请求的上游耗时计算新的并发上限
+type adjuster interface {
+	adjust(limit float64, inflight int, latency time.Duration, dropped bool) float64
+}
+
+func newAdjuster(initial int, cfg *config.AdaptiveLimit) (adjuster, error) {
+	minLimit := float64(max(cfg.MinLimit, 1))
+	maxLimit := float64(cfg.MaxLimit)
+	if maxLimit <= 0 {
+		maxLimit = float64(initial * 10)
+	}
+	if maxLimit < minLimit {
+		return nil, fmt.Errorf("invalid adaptive limit range: [%d, %d]", cfg.MinLimit, cfg.MaxLimit)
+	}
+
+	switch cfg.Algorithm {
+	case "", AlgorithmAIMD:
+		threshold := DefaultLatencyThreshold
+		if cfg.LatencyThreshold != "" {
+			var err error
+			threshold, err = time.ParseDuration(cfg.LatencyThreshold)
+			if err != nil {
+				return nil, fmt.Errorf("parse latency threshold error: %w", err)
+			}
+		}
+		ratio := cfg.BackoffRatio
+		if ratio <= 0 || ratio >= 1 {
+			ratio = DefaultBackoffRatio
+		}
+		return &aimd{minLimit: minLimit, maxLimit: maxLimit, threshold: threshold, backoffRatio: ratio}, nil
+	case AlgorithmGradient:
+		return &gradient{minLimit: minLimit, maxLimit: maxLimit}, nil
+	default:
+		return nil, fmt.Errorf("unsupported adaptive algorithm: %s", cfg.Algorithm)
+	}
+}
+
+// aimd 加性增大乘性减小: 延迟超过阈值或上游出错时按比例减小, 并发被充分使用时加一
+type aimd struct {
+	minLimit     float64
+	maxLimit     float64
+	threshold    time.Duration
+	backoffRatio float64
+}
+
+func (a *aimd) adjust(limit float64, inflight int, latency time.Duration, dropped bool) float64 {
+	switch {
+	case dropped || latency > a.threshold:
+		limit *= a.backoffRatio
+	case float64(inflight)*2 >= limit:
+		limit++
+	}
+	return clamp(limit, a.minLimit, a.maxLimit)
+}
+
+const (
+	gradientTolerance = 1.5
+	gradientSmoothing = 0.2
+	gradientWindow    = 600
+)
+
+// gradient 比较长期平均延迟与当前延迟: 当前延迟升高时按比例收缩, 延迟平稳时以 sqrt(limit) 的余量增长
+type gradient struct {
+	minLimit float64
+	maxLimit float64
+	// 长期平均延迟, 指数移动平均
+	longRTT float64
+}
+
+func (g *gradient) adjust(limit float64, inflight int, latency time.Duration, dropped bool) float64 {
+	rtt := float64(latency)
+	if rtt <= 0 {
+		return limit
+	}
+	if g.longRTT == 0 {
+		g.longRTT = rtt
+	} else {
+		g.longRTT += (rtt - g.longRTT) / gradientWindow
+	}
+
+	ratio := clamp(gradientTolerance*g.longRTT/rtt, 0.5, 1)
+	if dropped {
+		ratio = 0.5
+	}
+	// 并发未被充分使用时不继续增长
+	if ratio == 1 && float64(inflight) < limit/2 {
+		return limit
+	}
+
+	target := limit*ratio + math.Sqrt(limit)
+	limit = limit*(1-gradientSmoothing) + target*gradientSmoothing
+	return clamp(limit, g.minLimit, g.maxLimit)
+}
+
+func clamp(value, lower, upper float64) float64 {
+	return math.Min(math.Max(value, lower), upper)
+}
@@ -0,0 +1,211 @@
+package concurrency
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/config"
+)
+
+// ClassStats 单个优先级类别的计数
+type ClassStats struct {
+	Priority int   `json:"priority"`
+	Inflight int   `json:"inflight"`
+	Queued   int   `json:"queued"`
+	Admitted int64 `json:"admitted"`
+	Rejected int64 `json:"rejected"`
+}
+
+type class struct {
+	config.PriorityClass
+	// 该类别可以占用的在途请求上限, 为全局阈值减去更高优先级类别的预留
+	limit   int
+	waiters []chan struct{}
+	stats   ClassStats
+}
+
+// Admission 网关全局准入控制
+// 各类别的在途请求上限为全局阈值减去更高优先级类别的预留, 负载升高时低优先级类别先被拒绝,
+// 超过上限后各类别按自身队列长度排队或直接拒绝, 有请求结束时优先唤醒高优先级类别的等待请求
+type Admission struct {
+	maxInflight  int
+	queueTimeout time.Duration
+	defaultClass *class
+	classes      map[string]*class
+	// 按优先级从高到低排列
+	ordered []*class
+
+	mu       sync.Mutex
+	inflight int
+}
+
+func NewAdmission(cfg *config.AdmissionConfig) *Admission {
+	if cfg == nil {
+		cfg = &config.AdmissionConfig{}
+	}
+
+	a := &Admission{
+		maxInflight:  cfg.MaxInflight,
+		queueTimeout: cfg.QueueTimeout,
+		classes:      make(map[string]*class),
+	}
+	if a.queueTimeout <= 0 {
+		a.queueTimeout = DefaultQueueTimeout
+	}
+	for _, pc := range cfg.Classes {
+		c := &class{PriorityClass: pc}
+		c.stats.Priority = pc.Priority
+		a.classes[pc.Name] = c
+		a.ordered = append(a.ordered, c)
+	}
+	a.defaultClass = a.classes[cfg.DefaultClass]
+	if a.defaultClass == nil {
+		a.defaultClass = &class{PriorityClass: config.PriorityClass{Name: "default"}}
+		if cfg.DefaultClass != "" {
+			a.defaultClass.Name = cfg.DefaultClass
+		}
+		a.classes[a.defaultClass.Name] = a.defaultClass
+		a.ordered = append(a.ordered, a.defaultClass)
+	}
+	sort.SliceStable(a.ordered, func(i, j int) bool {
+		return a.ordered[i].Priority > a.ordered[j].Priority
+	})
+	for _, c := range a.ordered {
+		c.limit = a.maxInflight
+		for _, higher := range a.ordered {
+			if higher.Priority > c.Priority {
+				c.limit -= max(higher.Reserved, 0)
+			}
+		}
+		c.limit = max(c.limit, 0)
+	}
+	return a
+}
+
+// Enabled 是否配置了全局在途请求阈值
+func (a *Admission) Enabled() bool {
+	return a != nil && a.maxInflight > 0
+}
+
+// classify 取路由指定类别与声明匹配类别中优先级最高的一个
+func (a *Admission) classify(routeClass string, claims auth.Claims) *class {
+	selected := a.defaultClass
+	if c, ok := a.classes[routeClass]; ok {
+		selected = c
+	}
+	for _, c := range a.ordered {
+		if c.Priority <= selected.Priority {
+			break
+		}
+		if len(c.Claims) > 0 && claims != nil && auth.Authorize(c.Claims, claims, nil).Allowed {
+			selected = c
+			break
+		}
+	}
+	return selected
+}
+
+// Acquire 申请全局准入许可, 返回请求所属的类别名称
+func (a *Admission) Acquire(ctx context.Context, routeClass string, claims auth.Claims) (string, func(), error) {
+	c := a.classify(routeClass, claims)
+
+	a.mu.Lock()
+	if a.inflight < c.limit {
+		a.admit(c)
+		a.mu.Unlock()
+		return c.Name, a.releaser(c), nil
+	}
+	if len(c.waiters) >= c.MaxQueue {
+		c.stats.Rejected++
+		a.mu.Unlock()
+		return c.Name, nil, ErrLimitExceeded
+	}
+
+	ready := make(chan struct{})
+	c.waiters = append(c.waiters, ready)
+	c.stats.Queued++
+	a.mu.Unlock()
+
+	timer := time.NewTimer(a.queueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return c.Name, a.releaser(c), nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, waiter := range c.waiters {
+		if waiter == ready {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.stats.Queued--
+			c.stats.Rejected++
+			return c.Name, nil, err
+		}
+	}
+	return c.Name, a.releaser(c), nil
+}
+
+func (a *Admission) admit(c *class) {
+	a.inflight++
+	c.stats.Inflight++
+	c.stats.Admitted++
+}
+
+func (a *Admission) releaser(c *class) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { a.release(c) })
+	}
+}
+
+func (a *Admission) release(c *class) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.inflight--
+	c.stats.Inflight--
+
+	for _, next := range a.ordered {
+		if a.inflight >= a.maxInflight {
+			return
+		}
+		for len(next.waiters) > 0 && a.inflight < next.limit {
+			ready := next.waiters[0]
+			next.waiters = next.waiters[1:]
+			next.stats.Queued--
+			a.admit(next)
+			close(ready)
+		}
+	}
+}
+
+// RetryAfter 被拒绝时建议的重试等待秒数
+func (a *Admission) RetryAfter() int {
+	return max(int(math.Ceil(a.queueTimeout.Seconds())), 1)
+}
+
+// Stats 返回各类别的计数
+func (a *Admission) Stats() map[string]ClassStats {
+	stats := make(map[string]ClassStats)
+	if a == nil {
+		return stats
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name, c := range a.classes {
+		stats[name] = c.stats
+	}
+	return stats
+}
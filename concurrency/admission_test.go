@@ -0,0 +1,122 @@
+package concurrency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/config"
+)
+
+func newTestAdmission() *Admission {
+	return NewAdmission(&config.AdmissionConfig{
+		MaxInflight:  1,
+		QueueTimeout: time.Second,
+		DefaultClass: "normal",
+		Classes: []config.PriorityClass{
+			{Name: "analytics", Priority: -10},
+			{Name: "normal", Priority: 0, MaxQueue: 1},
+			{
+				Name:     "premium",
+				Priority: 10,
+				MaxQueue: 1,
+				Claims:   []config.AuthorizeRule{{Claim: "tier", Op: "eq", Values: []string{"premium"}}},
+			},
+		},
+	})
+}
+
+func TestAdmission_Classify(t *testing.T) {
+	a := newTestAdmission()
+
+	assert.Equal(t, "normal", a.classify("", nil).Name)
+	assert.Equal(t, "analytics", a.classify("analytics", nil).Name)
+	// 声明匹配的类别优先级更高时覆盖路由类别
+	assert.Equal(t, "premium", a.classify("analytics", auth.Claims{"tier": "premium"}).Name)
+	assert.Equal(t, "normal", a.classify("", auth.Claims{"tier": "free"}).Name)
+}
+
+func TestAdmission_HighPriorityFirst(t *testing.T) {
+	a := newTestAdmission()
+	ctx := context.Background()
+
+	_, release, err := a.Acquire(ctx, "", nil)
+	if err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+
+	// 超过阈值后低优先级类别直接拒绝
+	_, _, err = a.Acquire(ctx, "analytics", nil)
+	assert.Equal(t, ErrLimitExceeded, err)
+
+	order := make(chan string, 2)
+	enqueue := func(routeClass string, claims auth.Claims) {
+		class, release, err := a.Acquire(ctx, routeClass, claims)
+		if err != nil {
+			t.Errorf("queued acquire error: %v", err)
+			return
+		}
+		order <- class
+		release()
+	}
+	go enqueue("", nil)
+	for a.Stats()["normal"].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	go enqueue("", auth.Claims{"tier": "premium"})
+	for a.Stats()["premium"].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	release()
+	assert.Equal(t, "premium", <-order)
+	assert.Equal(t, "normal", <-order)
+
+	stats := a.Stats()
+	assert.Equal(t, int64(1), stats["analytics"].Rejected)
+	assert.Equal(t, int64(2), stats["normal"].Admitted)
+	assert.Equal(t, 0, stats["premium"].Inflight)
+}
+
+func TestAdmission_ReservedCapacity(t *testing.T) {
+	a := NewAdmission(&config.AdmissionConfig{
+		MaxInflight:  3,
+		QueueTimeout: time.Second,
+		DefaultClass: "normal",
+		Classes: []config.PriorityClass{
+			{Name: "analytics", Priority: -10},
+			{Name: "normal", Priority: 0, Reserved: 1},
+			{Name: "checkout", Priority: 10, Reserved: 1},
+		},
+	})
+	ctx := context.Background()
+
+	// 低优先级类别不能占用为更高优先级类别预留的容量
+	_, releaseAnalytics, err := a.Acquire(ctx, "analytics", nil)
+	if err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+	_, _, err = a.Acquire(ctx, "analytics", nil)
+	assert.Equal(t, ErrLimitExceeded, err)
+
+	_, releaseNormal, err := a.Acquire(ctx, "normal", nil)
+	if err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+	_, _, err = a.Acquire(ctx, "normal", nil)
+	assert.Equal(t, ErrLimitExceeded, err)
+
+	_, releaseCheckout, err := a.Acquire(ctx, "checkout", nil)
+	if err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+	_, _, err = a.Acquire(ctx, "checkout", nil)
+	assert.Equal(t, ErrLimitExceeded, err)
+
+	releaseCheckout()
+	releaseNormal()
+	releaseAnalytics()
+	assert.Equal(t, 0, a.inflight)
+}
@@ -0,0 +1,238 @@
+package concurrency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/superwhys/litegate/config"
+)
+
+const (
+	AlgorithmAIMD     = "aimd"
+	AlgorithmGradient = "gradient"
+
+	DefaultQueueTimeout     = time.Second
+	DefaultLatencyThreshold = time.Second
+	DefaultBackoffRatio     = 0.9
+)
+
+var (
+	// ErrLimitExceeded 并发已满且等待队列已满
+	ErrLimitExceeded = errors.New("concurrency limit exceeded")
+	// ErrQueueTimeout 排队等待超时
+	ErrQueueTimeout = errors.New("concurrency queue timeout")
+)
+
+// ReleaseFunc 请求结束时调用, 传入上游耗时以及上游是否出错, 用于自适应调整并发上限
+type ReleaseFunc func(latency time.Duration, dropped bool)
+
+// Stats 单个并发限制器的状态
+type Stats struct {
+	Limit    int   `json:"limit"`
+	Inflight int   `json:"inflight"`
+	Queued   int   `json:"queued"`
+	Rejected int64 `json:"rejected"`
+}
+
+// Manager 按路由维护并发限制器, 配置热更新后自动重建
+type Manager struct {
+	limiters sync.Map
+}
+
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// Acquire 获取指定路由的并发许可, 失败时返回 ErrLimitExceeded 或 ErrQueueTimeout
+func (m *Manager) Acquire(ctx context.Context, key string, cfg *config.ConcurrencyLimit) (ReleaseFunc, error) {
+	l, err := m.limiter(key, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx)
+}
+
+// limiter 返回与配置一致的限制器, 配置变化时以比较并交换的方式替换, 并发请求不会互相覆盖已生效的限制器
+func (m *Manager) limiter(key string, cfg *config.ConcurrencyLimit) (*limiter, error) {
+	if value, ok := m.limiters.Load(key); ok && value.(*limiter).cfg == cfg {
+		return value.(*limiter), nil
+	}
+
+	hash := configHash(cfg)
+	for {
+		value, ok := m.limiters.Load(key)
+		if ok && value.(*limiter).hash == hash {
+			return value.(*limiter), nil
+		}
+
+		l, err := newLimiter(cfg)
+		if err != nil {
+			return nil, err
+		}
+		l.hash = hash
+
+		// 旧限制器上的请求结束后自然释放, 新请求全部进入新限制器
+		if !ok {
+			if _, loaded := m.limiters.LoadOrStore(key, l); !loaded {
+				return l, nil
+			}
+		} else if m.limiters.CompareAndSwap(key, value, l) {
+			return l, nil
+		}
+		// 其他请求已替换限制器, 重新比较配置
+	}
+}
+
+// configHash 计算并发限制配置的摘要, 用于判断热加载后配置是否变化
+func configHash(cfg *config.ConcurrencyLimit) string {
+	b, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Stats 返回所有路由的并发状态
+func (m *Manager) Stats() map[string]Stats {
+	stats := make(map[string]Stats)
+	m.limiters.Range(func(key, value any) bool {
+		stats[key.(string)] = value.(*limiter).stats()
+		return true
+	})
+	return stats
+}
+
+// limiter 带有限等待队列的并发限制器
+type limiter struct {
+	cfg          *config.ConcurrencyLimit
+	hash         string
+	maxQueue     int
+	queueTimeout time.Duration
+	adjuster     adjuster
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	waiters  []chan struct{}
+	rejected int64
+}
+
+func newLimiter(cfg *config.ConcurrencyLimit) (*limiter, error) {
+	if cfg.MaxInflight <= 0 {
+		return nil, fmt.Errorf("invalid max inflight: %d", cfg.MaxInflight)
+	}
+
+	queueTimeout := DefaultQueueTimeout
+	if cfg.QueueTimeout != "" {
+		var err error
+		queueTimeout, err = time.ParseDuration(cfg.QueueTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("parse queue timeout error: %w", err)
+		}
+	}
+
+	l := &limiter{
+		cfg:          cfg,
+		maxQueue:     max(cfg.MaxQueue, 0),
+		queueTimeout: queueTimeout,
+		limit:        float64(cfg.MaxInflight),
+	}
+	if cfg.Adaptive != nil {
+		adjuster, err := newAdjuster(cfg.MaxInflight, cfg.Adaptive)
+		if err != nil {
+			return nil, err
+		}
+		l.adjuster = adjuster
+	}
+	return l, nil
+}
+
+func (l *limiter) acquire(ctx context.Context) (ReleaseFunc, error) {
+	l.mu.Lock()
+	if l.inflight < l.currentLimit() {
+		l.inflight++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	if len(l.waiters) >= l.maxQueue {
+		l.rejected++
+		l.mu.Unlock()
+		return nil, ErrLimitExceeded
+	}
+
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return l.release, nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, waiter := range l.waiters {
+		if waiter == ready {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			l.rejected++
+			return nil, err
+		}
+	}
+	// 超时的同时已被唤醒, 许可已经分配给当前请求
+	return l.release, nil
+}
+
+func (l *limiter) release(latency time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.adjuster != nil {
+		l.limit = l.adjuster.adjust(l.limit, l.inflight, latency, dropped)
+	}
+	l.inflight--
+
+	// 按先进先出顺序唤醒等待中的请求
+	for len(l.waiters) > 0 && l.inflight < l.currentLimit() {
+		ready := l.waiters[0]
+		l.waiters = l.waiters[1:]
+		l.inflight++
+		close(ready)
+	}
+}
+
+func (l *limiter) currentLimit() int {
+	return max(int(l.limit), 1)
+}
+
+func (l *limiter) stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{
+		Limit:    l.currentLimit(),
+		Inflight: l.inflight,
+		Queued:   len(l.waiters),
+		Rejected: l.rejected,
+	}
+}
+
+// RetryAfter 被拒绝时建议的重试等待时间, 取排队超时时间, 至少 1 秒
+func RetryAfter(cfg *config.ConcurrencyLimit) int {
+	timeout, err := time.ParseDuration(cfg.QueueTimeout)
+	if err != nil || timeout <= 0 {
+		timeout = DefaultQueueTimeout
+	}
+	return max(int(math.Ceil(timeout.Seconds())), 1)
+}
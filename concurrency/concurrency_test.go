@@ -0,0 +1,124 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestManager_QueueAndShed(t *testing.T) {
+	m := NewManager()
+	cfg := &config.ConcurrencyLimit{MaxInflight: 1, MaxQueue: 1, QueueTimeout: "1s"}
+
+	release, err := m.Acquire(context.Background(), "k", cfg)
+	if err != nil {
+		t.Fatalf("acquire error: %v", err)
+	}
+
+	acquired := make(chan ReleaseFunc)
+	go func() {
+		queued, err := m.Acquire(context.Background(), "k", cfg)
+		if err != nil {
+			t.Errorf("queued acquire error: %v", err)
+		}
+		acquired <- queued
+	}()
+
+	// 等待第二个请求进入队列
+	for m.Stats()["k"].Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 队列已满, 第三个请求直接拒绝
+	_, err = m.Acquire(context.Background(), "k", cfg)
+	assert.Equal(t, ErrLimitExceeded, err)
+
+	release(time.Millisecond, false)
+	queued := <-acquired
+	assert.Equal(t, 1, m.Stats()["k"].Inflight)
+	queued(time.Millisecond, false)
+	assert.Equal(t, 0, m.Stats()["k"].Inflight)
+}
+
+func TestManager_QueueTimeout(t *testing.T) {
+	m := NewManager()
+	cfg := &config.ConcurrencyLimit{MaxInflight: 1, MaxQueue: 1, QueueTimeout: "20ms"}
+
+	release, _ := m.Acquire(context.Background(), "k", cfg)
+	defer release(0, false)
+
+	_, err := m.Acquire(context.Background(), "k", cfg)
+	assert.Equal(t, ErrQueueTimeout, err)
+	assert.Equal(t, 0, m.Stats()["k"].Queued)
+	assert.Equal(t, int64(1), m.Stats()["k"].Rejected)
+}
+
+func TestManager_ReloadKeepsLimiter(t *testing.T) {
+	m := NewManager()
+	release, _ := m.Acquire(context.Background(), "k", &config.ConcurrencyLimit{MaxInflight: 2})
+	defer release(0, false)
+
+	// 热加载后配置未变化时沿用原限制器, 在途计数不丢失
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.limiter("k", &config.ConcurrencyLimit{MaxInflight: 2})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, m.Stats()["k"].Inflight)
+
+	// 配置变化时替换限制器
+	l, err := m.limiter("k", &config.ConcurrencyLimit{MaxInflight: 3})
+	if err != nil {
+		t.Fatalf("limiter error: %v", err)
+	}
+	assert.Equal(t, 3, l.currentLimit())
+	assert.Equal(t, 0, m.Stats()["k"].Inflight)
+}
+
+func TestAIMD_Adjust(t *testing.T) {
+	a, err := newAdjuster(10, &config.AdaptiveLimit{LatencyThreshold: "100ms", MinLimit: 2})
+	if err != nil {
+		t.Fatalf("new adjuster error: %v", err)
+	}
+
+	// 并发充分使用且延迟正常时加一
+	assert.Equal(t, 11.0, a.adjust(10, 10, 10*time.Millisecond, false))
+	// 并发未充分使用时保持不变
+	assert.Equal(t, 10.0, a.adjust(10, 2, 10*time.Millisecond, false))
+	// 延迟超过阈值或上游出错时乘性减小
+	assert.Equal(t, 9.0, a.adjust(10, 10, time.Second, false))
+	assert.Equal(t, 9.0, a.adjust(10, 10, 10*time.Millisecond, true))
+	// 不低于最小值
+	assert.Equal(t, 2.0, a.adjust(2, 2, time.Second, false))
+}
+
+func TestGradient_ShrinksOnLatencySpike(t *testing.T) {
+	g, err := newAdjuster(20, &config.AdaptiveLimit{Algorithm: AlgorithmGradient})
+	if err != nil {
+		t.Fatalf("new adjuster error: %v", err)
+	}
+
+	limit := 20.0
+	for i := 0; i < 100; i++ {
+		limit = g.adjust(limit, int(limit), 10*time.Millisecond, false)
+	}
+	if limit <= 20 {
+		t.Fatalf("limit should grow under stable latency, got %v", limit)
+	}
+
+	grown := limit
+	for i := 0; i < 20; i++ {
+		limit = g.adjust(limit, int(limit), 200*time.Millisecond, false)
+	}
+	if limit >= grown {
+		t.Fatalf("limit should shrink on latency spike, got %v >= %v", limit, grown)
+	}
+}
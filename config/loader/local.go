@@ -11,6 +11,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/miebyte/goutils/logging"
 	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/metrics"
 )
 
 type localConfigLoader struct {
@@ -50,6 +51,9 @@ func (ll *localConfigLoader) loadAllConfigs() {
 		}
 
 		if d.IsDir() {
+			if ll.isMockDir(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -82,6 +86,11 @@ func (ll *localConfigLoader) loadConfigFile(filePath string) error {
 	if err := json.Unmarshal(data, &routeConfig); err != nil {
 		return err
 	}
+	// 响应内容文件相对于代理配置目录, 校验前设置
+	routeConfig.Dir = ll.configDir
+	if err := routeConfig.Validate(); err != nil {
+		return err
+	}
 
 	serviceName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
 
@@ -201,6 +210,9 @@ func (ll *localConfigLoader) addSubdirsToWatch() error {
 		if err != nil {
 			return err
 		}
+		if d.IsDir() && ll.isMockDir(path) {
+			return filepath.SkipDir
+		}
 		if d.IsDir() && path != ll.configDir {
 			return ll.watcher.Add(path)
 		}
@@ -210,7 +222,13 @@ func (ll *localConfigLoader) addSubdirsToWatch() error {
 
 func (ll *localConfigLoader) isConfigFile(filename string) bool {
 	ext := filepath.Ext(filename)
-	return ext == ".json"
+	return ext == ".json" && !ll.isMockDir(filepath.Dir(filename))
+}
+
+// isMockDir 样例目录及其子目录中的 JSON 文件不是代理配置
+func (ll *localConfigLoader) isMockDir(path string) bool {
+	rel, err := filepath.Rel(filepath.Join(ll.configDir, config.MockDir), path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 func (ll *localConfigLoader) isDirectory(path string) bool {
@@ -219,7 +237,12 @@ func (ll *localConfigLoader) isDirectory(path string) bool {
 }
 
 func (ll *localConfigLoader) onConfigChanged(filename string) {
-	if err := ll.loadConfigFile(filename); err != nil {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	err := ll.loadConfigFile(filename)
+	metrics.ConfigReloaded(err)
+	if err != nil {
 		logging.Errorf("reload config file error: %s, %v", filename, err)
 	}
 }
@@ -101,3 +101,34 @@ func TestLocalConfigLoader_SubdirWatch(t *testing.T) {
 
 	t.Log("子目录文件监听测试完成")
 }
+
+func TestLocalConfigLoader_SkipMockDir(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// mocks 目录中的样例不是代理配置
+	mockDir := filepath.Join(tempDir, "mocks", "user")
+	if err := os.MkdirAll(mockDir, 0755); err != nil {
+		t.Fatalf("创建样例目录失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(mockDir, "users.json"), []byte(`[{"id": 1}]`), 0644); err != nil {
+		t.Fatalf("创建样例文件失败: %v", err)
+	}
+	testContent := `{"proxy": ["http://localhost:8080"], "routes": [{"match": "/test"}]}`
+	if err := os.WriteFile(filepath.Join(tempDir, "user.json"), []byte(testContent), 0644); err != nil {
+		t.Fatalf("创建测试配置文件失败: %v", err)
+	}
+
+	loader := NewLocalConfigLoader(tempDir)
+	configs, _ := loader.GetAll()
+	if len(configs) != 1 {
+		t.Fatalf("期望加载 1 个配置, 实际 %d 个", len(configs))
+	}
+
+	config, _ := loader.Get("user")
+	if config == nil || config.Dir != tempDir {
+		t.Fatalf("配置目录未设置: %+v", config)
+	}
+	if !loader.isMockDir(mockDir) || loader.isMockDir(tempDir) {
+		t.Fatalf("样例目录判断错误")
+	}
+}
@@ -10,12 +10,18 @@ package config
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/utils"
 )
 
 type ProxyConfigLoader interface {
@@ -25,22 +31,111 @@ type ProxyConfigLoader interface {
 }
 
 type Upstream struct {
+	// 服务名称, 同时作为内部身份令牌的 audience
+	Service string
+	// 匹配到的路由规则
+	Route       string
 	Auth        *Auth
+	Authorize   []AuthorizeRule
 	Timeout     time.Duration
 	UpstreamURL string
 	TargetPath  string
+	// 路由正则中的命名捕获, 如 /users/{id} 中的 id
+	PathParams map[string]string
+	// 转发前需要从请求中清除的声明注入目标, 如 $header.X-User / $query.user_id
+	StripClaims []string
+	// 转发前需要从请求中清除的请求头
+	StripHeaders []string
+	// 限流规则, 服务级与路由级规则同时生效
+	RateLimits []ScopedRateLimit
+	// 并发限制
+	Concurrency *ConcurrencyLimit
+	// 准入优先级类别
+	Priority string
+	// 配额规则, 服务级与路由级规则同时生效
+	Quotas []ScopedQuota
+	// 请求体大小上限, 0 表示使用网关默认值, 负数表示不限制
+	MaxBodyBytes int64
+	// 客户端IP访问控制
+	IPACL *IPACL
+	// 跨域策略
+	CORS *CORSPolicy
+	// 安全响应头策略
+	SecurityHeaders *SecurityHeaders
+	// 流量镜像
+	Mirror *Mirror
+	// 按权重分流的上游分组, 配置后由分流结果决定 UpstreamURL
+	Split *TrafficSplit
+	// 分流选中的上游分组
+	Group string
+	// 路由的代理地址列表, 会话保持在其中选择固定的上游
+	Proxy ProxyConfig
+	// 会话保持
+	Affinity *Affinity
+	// 故障注入
+	Fault *Fault
+	// 直接响应, 配置后不转发上游
+	DirectResponse *DirectResponse
+	// 重定向, 配置后不转发上游
+	Redirect *Redirect
+	// 返回 mocks 目录中的样例, 配置后不转发上游
+	Mock *Mock
+	// 代理配置目录, 用于读取响应文件与样例
+	ConfigDir string
+}
+
+// ScopedQuota 带作用域的配额规则, 相同作用域的请求共享配额
+type ScopedQuota struct {
+	Scope string
+	*Quota
+}
+
+// ScopedRateLimit 带作用域的限流规则, 相同作用域的请求共享计数
+type ScopedRateLimit struct {
+	Scope string
+	*RateLimit
 }
 
 // RouteConfig 路由配置
 type RouteConfig struct {
-	// 代理地址列表
-	Proxy ProxyConfig `json:"proxy" validate:"required"`
+	// 代理地址列表, 路由未配置 proxy 时使用
+	Proxy ProxyConfig `json:"proxy"`
 	// 超时时间（选填，默认30秒）
 	Timeout string `json:"timeout"`
 	// 身份验证配置（选填）
 	Auth *Auth `json:"auth,omitempty"`
+	// 授权规则（选填）
+	Authorize []AuthorizeRule `json:"authorize,omitempty" validate:"omitempty,dive"`
+	// 转发前始终清除的请求头（选填）
+	StripHeaders []string `json:"strip_headers,omitempty"`
+	// 服务级限流, 作用于该服务下的所有路由（选填）
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	// 并发限制, 每个路由独立计数（选填）
+	Concurrency *ConcurrencyLimit `json:"concurrency,omitempty"`
+	// 准入优先级类别（选填）
+	Priority string `json:"priority,omitempty"`
+	// 服务级配额, 该服务下的所有路由共享（选填）
+	Quota *Quota `json:"quota,omitempty"`
+	// 请求体大小上限, 为空时使用网关默认值, 负数表示不限制（选填）
+	MaxBodyBytes int64 `json:"max_body_bytes,omitempty"`
+	// 客户端IP访问控制（选填）
+	IPACL *IPACL `json:"ip_acl,omitempty"`
+	// 跨域策略, 配置后预检请求由网关直接响应（选填）
+	CORS *CORSPolicy `json:"cors,omitempty"`
+	// 安全响应头策略（选填）
+	SecurityHeaders *SecurityHeaders `json:"security_headers,omitempty"`
+	// 访问日志, 覆盖网关的启用状态与采样率（选填）
+	AccessLog *ServiceAccessLog `json:"access_log,omitempty"`
+	// 流量镜像（选填）
+	Mirror *Mirror `json:"mirror,omitempty"`
+	// 会话保持（选填）
+	Affinity *Affinity `json:"affinity,omitempty"`
+	// 故障注入（选填）
+	Fault *Fault `json:"fault,omitempty"`
 	// 路由配置（必填）
-	Routes []Route `json:"routes" validate:"required,min=1"`
+	Routes []Route `json:"routes" validate:"required,min=1,dive"`
+	// 代理配置目录, 由加载器设置
+	Dir string `json:"-"`
 }
 
 func (rc *RouteConfig) MatchRequest(ctx context.Context, req *http.Request) *Upstream {
@@ -50,7 +145,7 @@ func (rc *RouteConfig) MatchRequest(ctx context.Context, req *http.Request) *Ups
 		}
 
 		logging.Debugc(ctx, "try match route: %s", logging.JsonifyNoIndent(route))
-		regex, err := regexp.Compile(route.Match)
+		regex, err := compileMatch(route.Match)
 		if err != nil {
 			logging.Errorf("compile regex error: %v", err)
 			return nil
@@ -74,12 +169,88 @@ func (rc *RouteConfig) MatchRequest(ctx context.Context, req *http.Request) *Ups
 			auth = route.Auth
 		}
 
-		if regex.MatchString(req.URL.Path) {
+		// 禁用身份验证的路由不继承服务级授权规则
+		authorize := rc.Authorize
+		if route.DisableAuth {
+			authorize = nil
+		}
+		if len(route.Authorize) > 0 {
+			authorize = route.Authorize
+		}
+
+		concurrency := rc.Concurrency
+		if route.Concurrency != nil {
+			concurrency = route.Concurrency
+		}
+
+		priority := rc.Priority
+		if route.Priority != "" {
+			priority = route.Priority
+		}
+
+		maxBodyBytes := rc.MaxBodyBytes
+		if route.MaxBodyBytes != 0 {
+			maxBodyBytes = route.MaxBodyBytes
+		}
+
+		ipACL := rc.IPACL
+		if route.IPACL != nil {
+			ipACL = route.IPACL
+		}
+
+		cors := rc.CORS
+		if route.CORS != nil {
+			cors = route.CORS
+		}
+
+		mirror := rc.Mirror
+		if route.Mirror != nil {
+			mirror = route.Mirror
+		}
+
+		affinity := rc.Affinity
+		if route.Affinity != nil {
+			affinity = route.Affinity
+		}
+
+		proxy := rc.Proxy
+		if len(route.Proxy) > 0 {
+			proxy = route.Proxy
+		}
+
+		fault := rc.Fault
+		if route.Fault != nil {
+			fault = route.Fault
+		}
+
+		if matches := regex.FindStringSubmatch(req.URL.Path); matches != nil {
 			upstream := &Upstream{
-				Auth:        auth,
-				Timeout:     timeout,
-				UpstreamURL: route.Proxy.pickAddress(),
-				TargetPath:  req.URL.Path,
+				Route:           route.Match,
+				Auth:            auth,
+				Authorize:       authorize,
+				Timeout:         timeout,
+				UpstreamURL:     proxy.PickAddress(),
+				TargetPath:      req.URL.Path,
+				PathParams:      pathParams(regex, matches),
+				StripClaims:     rc.stripClaims(),
+				StripHeaders:    rc.StripHeaders,
+				RateLimits:      rc.rateLimits(route),
+				Concurrency:     concurrency,
+				Priority:        priority,
+				Quotas:          rc.quotas(route),
+				MaxBodyBytes:    maxBodyBytes,
+				IPACL:           ipACL,
+				CORS:            cors,
+				SecurityHeaders: rc.SecurityHeaders,
+				Mirror:          mirror,
+				Split:           route.Split,
+				Proxy:           proxy,
+				Affinity:        affinity,
+				Fault:           fault,
+				DirectResponse:  route.DirectResponse,
+				Redirect:        route.Redirect,
+				Mock:            route.Mock,
+				ConfigDir:       rc.Dir,
 			}
 			logging.Debugc(ctx, "matched route: %s", logging.JsonifyNoIndent(upstream))
 			return upstream
@@ -89,13 +260,97 @@ func (rc *RouteConfig) MatchRequest(ctx context.Context, req *http.Request) *Ups
 	return nil
 }
 
+// stripClaims 汇总服务及其所有路由上配置的声明注入目标
+// 即使路由禁用了身份验证也需要清除, 防止客户端自行携带这些字段伪造身份
+func (rc *RouteConfig) stripClaims() []string {
+	var places []string
+	addPlaces := func(auth *Auth) {
+		if auth == nil {
+			return
+		}
+		for place := range auth.Claims {
+			places = append(places, place)
+		}
+		// 默认注入到 Authorization 请求头, 与自定义注入位置一样需要清除客户端携带的值
+		if auth.UpstreamToken != nil {
+			places = append(places, auth.UpstreamToken.TargetPlace())
+		}
+	}
+
+	addPlaces(rc.Auth)
+	for _, route := range rc.Routes {
+		addPlaces(route.Auth)
+	}
+	return places
+}
+
+// HasCORSPolicy 服务或其任一路由是否配置了跨域策略
+func (rc *RouteConfig) HasCORSPolicy() bool {
+	if rc.CORS != nil {
+		return true
+	}
+	for _, route := range rc.Routes {
+		if route.CORS != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (rc *RouteConfig) quotas(route Route) []ScopedQuota {
+	var quotas []ScopedQuota
+	if rc.Quota != nil {
+		quotas = append(quotas, ScopedQuota{Scope: "*", Quota: rc.Quota})
+	}
+	if route.Quota != nil {
+		quotas = append(quotas, ScopedQuota{Scope: route.Match, Quota: route.Quota})
+	}
+	return quotas
+}
+
+func (rc *RouteConfig) rateLimits(route Route) []ScopedRateLimit {
+	var limits []ScopedRateLimit
+	if rc.RateLimit != nil {
+		limits = append(limits, ScopedRateLimit{Scope: "*", RateLimit: rc.RateLimit})
+	}
+	if route.RateLimit != nil {
+		limits = append(limits, ScopedRateLimit{Scope: route.Match, RateLimit: route.RateLimit})
+	}
+	return limits
+}
+
+var capturePattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// compileMatch 编译路由正则, 并将 {name} 形式的占位符转换为命名捕获组
+func compileMatch(match string) (*regexp.Regexp, error) {
+	return regexp.Compile(capturePattern.ReplaceAllString(match, `(?P<$1>[^/]+)`))
+}
+
+func pathParams(regex *regexp.Regexp, matches []string) map[string]string {
+	var params map[string]string
+	for i, name := range regex.SubexpNames() {
+		if name == "" || i >= len(matches) {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = matches[i]
+	}
+	return params
+}
+
 // Auth 身份验证配置
 type Auth struct {
 	// Token类型，固定为jwt
 	Type string `json:"type" validate:"required,eq=jwt"`
 	// Token在请求中的位置
-	// $header.token
+	// $header.token (Authorization 头会自动去除 Bearer 前缀)
 	// $query.token
+	// $cookie.token
+	// $path.token (路由中的路径捕获)
+	// $form.token
+	// $body.auth.token (JSON请求体中的字段路径)
 	Source string `json:"source" validate:"required"`
 	// JWT密钥
 	Secret string `json:"secret" validate:"required"`
@@ -107,7 +362,51 @@ type Auth struct {
 	// 该示例表示
 	// 1. 将JWT解码后的 `user_id` 数据存储到请求 query中, key为 user_id
 	// 2. 将JWT解码后的 `userName` 数据存储到请求 header中, key为 X-User
-	Claims map[string]string `json:"claims" validate:"required"`
+	// 声明名称支持 realm_access.roles 形式的嵌套选择器
+	Claims map[string]string `json:"claims"`
+	// 数组类型声明的注入方式: join(逗号拼接, 默认) / json
+	ArrayFormat string `json:"array_format" validate:"omitempty,oneof=join json"`
+	// 验证通过后由网关签发内部身份令牌注入给上游（选填）
+	UpstreamToken *UpstreamToken `json:"upstream_token,omitempty"`
+}
+
+// DefaultUpstreamTokenTarget 内部身份令牌默认注入位置
+const DefaultUpstreamTokenTarget = "$header.Authorization"
+
+// UpstreamToken 网关签发的内部身份令牌配置, 令牌的 audience 为目标服务名称
+type UpstreamToken struct {
+	// 令牌注入位置, 默认 $header.Authorization (以 Bearer 形式注入)
+	Target string `json:"target"`
+	// 有效期, 默认 60s
+	TTL string `json:"ttl"`
+	// 声明映射: 内部令牌声明名称 -> 客户端令牌声明名称(支持嵌套选择器), 默认仅复制 sub
+	// {
+	// 	"sub": "sub",
+	// 	"roles": "realm_access.roles",
+	// }
+	Claims map[string]string `json:"claims"`
+}
+
+// TargetPlace 返回令牌注入位置, 未配置时为 DefaultUpstreamTokenTarget
+func (t *UpstreamToken) TargetPlace() string {
+	if t.Target == "" {
+		return DefaultUpstreamTokenTarget
+	}
+	return t.Target
+}
+
+// AuthorizeRule 基于JWT声明的授权规则, 同一路由下的所有规则需全部满足
+type AuthorizeRule struct {
+	// JWT声明字段名称
+	Claim string `json:"claim" validate:"required"`
+	// 比较方式
+	// eq: 声明值等于 values[0]
+	// in: 声明值为 values 中任意一个
+	// contains: 声明(数组或空格分隔字符串)包含 values 中任意一个, 如角色判断
+	// contains_all: 声明(数组或空格分隔字符串)包含 values 中全部, 如 scope 判断
+	Op string `json:"op" validate:"required,oneof=eq in contains contains_all"`
+	// 比较值, 支持 $path.id 引用路由中的路径捕获
+	Values []string `json:"values" validate:"required,min=1"`
 }
 
 // Route 路由配置
@@ -122,6 +421,427 @@ type Route struct {
 	DisableAuth bool `json:"disable_auth"`
 	// 身份验证配置覆盖
 	Auth *Auth `json:"auth,omitempty"`
+	// 授权规则覆盖
+	Authorize []AuthorizeRule `json:"authorize,omitempty" validate:"omitempty,dive"`
+	// 路由级限流（选填）
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	// 并发限制覆盖
+	Concurrency *ConcurrencyLimit `json:"concurrency,omitempty"`
+	// 准入优先级类别覆盖
+	Priority string `json:"priority,omitempty"`
+	// 路由级配额（选填）
+	Quota *Quota `json:"quota,omitempty"`
+	// 请求体大小上限覆盖
+	MaxBodyBytes int64 `json:"max_body_bytes,omitempty"`
+	// 客户端IP访问控制覆盖
+	IPACL *IPACL `json:"ip_acl,omitempty"`
+	// 跨域策略覆盖
+	CORS *CORSPolicy `json:"cors,omitempty"`
+	// 流量镜像覆盖
+	Mirror *Mirror `json:"mirror,omitempty"`
+	// 按权重分流到多个上游分组, 配置后忽略 proxy（选填）
+	Split *TrafficSplit `json:"split,omitempty"`
+	// 会话保持覆盖
+	Affinity *Affinity `json:"affinity,omitempty"`
+	// 故障注入覆盖
+	Fault *Fault `json:"fault,omitempty"`
+	// 直接响应, 配置后忽略 proxy（选填）
+	DirectResponse *DirectResponse `json:"direct_response,omitempty"`
+	// 重定向, 配置后忽略 proxy（选填）
+	Redirect *Redirect `json:"redirect,omitempty"`
+	// 返回 mocks 目录中的 JSON 样例, 配置后忽略 proxy（选填）
+	Mock *Mock `json:"mock,omitempty"`
+}
+
+// MockDir 代理配置目录下存放样例的子目录, 加载代理配置时跳过
+const MockDir = "mocks"
+
+// DirectResponse 由网关直接返回的响应, 响应头与响应内容支持 text/template 模板
+// 模板变量: .Service / .Method / .Path / .RawQuery / .Query / .Params(路径捕获, 如 {{.Params.id}})
+type DirectResponse struct {
+	// 状态码, 默认 200
+	Status int `json:"status" validate:"omitempty,min=100,max=599"`
+	// 响应头
+	Headers map[string]string `json:"headers"`
+	// 响应内容
+	Body string `json:"body"`
+	// 从文件读取响应内容, 相对于代理配置目录, 配置后忽略 body
+	BodyFile string `json:"body_file"`
+
+	// 加载配置时解析的模板
+	templates *DirectResponseTemplates
+}
+
+// Redirect 重定向
+type Redirect struct {
+	// 状态码: 301 / 302(默认) / 307 / 308
+	Status int `json:"status" validate:"omitempty,oneof=301 302 307 308"`
+	// 跳转地址, 支持与 DirectResponse 相同的模板变量, 如 https://new.example.com/users/{{.Params.id}}
+	Location string `json:"location" validate:"required"`
+
+	// 加载配置时解析的跳转地址模板
+	location *Template
+}
+
+// Mock 从代理配置目录的 mocks 子目录读取 JSON 样例作为响应
+type Mock struct {
+	// 样例文件, 相对于 mocks 目录, 支持模板变量; 为空时为 {service}{path}.json, 如 user/api/users/1.json
+	File string `json:"file"`
+	// 状态码, 默认 200
+	Status int `json:"status" validate:"omitempty,min=100,max=599"`
+	// 响应头, 默认 Content-Type: application/json; charset=utf-8
+	Headers map[string]string `json:"headers"`
+
+	// 加载配置时解析的模板
+	templates *MockTemplates
+}
+
+// TrafficSplit 按权重将路由流量分配到多个命名的上游分组, 如 stable 95 / canary 5
+type TrafficSplit struct {
+	// 上游分组
+	Groups []UpstreamGroup `json:"groups" validate:"required,min=1,dive"`
+	// 强制指定分组的规则, 按顺序检查, 第一个命中的规则生效
+	Overrides []SplitOverride `json:"overrides,omitempty" validate:"omitempty,dive"`
+	// 粘性分配依据, 同一取值始终分到同一分组
+	// $claim.sub / $cookie.uid / $header.X-User-Id / $query.uid / $ip
+	// 取不到值时按客户端IP分配, 为空时每个请求独立按权重随机
+	Sticky string `json:"sticky"`
+}
+
+// UpstreamGroup 上游分组
+type UpstreamGroup struct {
+	// 分组名称, 如 stable / canary
+	Name string `json:"name" validate:"required"`
+	// 权重, 为 0 时只能通过 overrides 命中
+	Weight int `json:"weight" validate:"min=0"`
+	// 分组的代理地址列表
+	Proxy ProxyConfig `json:"proxy" validate:"required,min=1"`
+}
+
+// SplitOverride 强制指定分组的规则
+type SplitOverride struct {
+	// 取值位置: $header.X-Canary / $cookie.canary / $query.canary / $claim.beta
+	Source string `json:"source" validate:"required"`
+	// 取值等于 value 时命中, 为空时取值本身作为分组名称
+	Value string `json:"value"`
+	// 命中时使用的分组, value 为空时可不填
+	Group string `json:"group"`
+	// value 为空时允许客户端指定的分组, 为空时只允许权重大于 0 的分组
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Allows 判断取值本身作为分组名称时是否允许选中该分组
+// 未配置 groups 时不允许选中权重为 0 的分组, 避免客户端自行进入未放量的分组
+func (o *SplitOverride) Allows(group *UpstreamGroup) bool {
+	if len(o.Groups) == 0 {
+		return group.Weight > 0
+	}
+	return slices.Contains(o.Groups, group.Name)
+}
+
+const (
+	AffinityCookie = "cookie"
+	AffinityClaim  = "claim"
+)
+
+// Affinity 会话保持, 将同一客户端固定到同一个上游地址
+// 固定的地址连接失败或从代理地址列表中移除后, 客户端会被重新分配
+type Affinity struct {
+	// 依据: cookie(网关签发的签名 Cookie, 默认) / claim(JWT 声明的哈希)
+	Type string `json:"type" validate:"omitempty,oneof=cookie claim"`
+	// Cookie 名称, 默认 litegate_affinity
+	Cookie string `json:"cookie"`
+	// Cookie 有效期, 为空时为会话 Cookie
+	TTL string `json:"ttl"`
+	// 声明名称, 默认 sub, 取不到值时按客户端IP分配
+	Claim string `json:"claim"`
+	// 上游连接失败后暂停分配的时长, 默认 10s
+	EjectDuration string `json:"eject_duration"`
+}
+
+// SecurityHeaders 安全响应头策略, 默认只补充上游未设置的响应头
+type SecurityHeaders struct {
+	// 预设: strict(面向浏览器页面) / api(面向接口) / none(默认, 不添加)
+	Preset string `json:"preset" validate:"omitempty,oneof=strict api none"`
+	// 在预设基础上添加或替换的响应头, 值为空字符串时不设置该响应头
+	Headers map[string]string `json:"headers"`
+	// 是否覆盖上游已设置的同名响应头
+	Override bool `json:"override"`
+}
+
+// Mirror 流量镜像, 将请求副本发送到另一个上游且忽略其响应, 不影响主请求
+type Mirror struct {
+	// 镜像上游地址, 如 http://127.0.0.1:9000
+	Upstream string `json:"upstream" validate:"required"`
+	// 镜像的请求百分比[0, 100], 为空时镜像全部请求, 0 表示不镜像
+	Percentage *float64 `json:"percentage,omitempty"`
+	// 镜像请求超时时间, 默认 1s
+	Timeout string `json:"timeout"`
+	// 同时进行的镜像请求上限, 超出时放弃镜像, 默认 16
+	MaxConcurrency int `json:"max_concurrency"`
+	// 缓冲请求体的最大字节数, 超出时不镜像该请求, 默认 1MB
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// 镜像请求保留客户端凭证与网关签发的内部令牌, 默认移除
+	ForwardCredentials bool `json:"forward_credentials"`
+
+	// 加载配置时解析的镜像上游地址与超时时间
+	target   *url.URL
+	timeout  time.Duration
+	compiled bool
+}
+
+// Compile 解析镜像上游地址与超时时间, 加载配置时调用, 配置无效时返回错误
+func (m *Mirror) Compile() error {
+	target, timeout, err := m.parse()
+	if err != nil {
+		return err
+	}
+	m.target, m.timeout, m.compiled = target, timeout, true
+	return nil
+}
+
+// Endpoint 返回解析后的镜像上游地址与超时时间, 未配置超时时间时为 0, 未经加载器解析的配置在调用时解析
+func (m *Mirror) Endpoint() (*url.URL, time.Duration, error) {
+	if m.compiled {
+		return m.target, m.timeout, nil
+	}
+	return m.parse()
+}
+
+func (m *Mirror) parse() (*url.URL, time.Duration, error) {
+	target, err := url.Parse(m.Upstream)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse mirror upstream error: %w", err)
+	}
+	if target.Scheme == "" || target.Host == "" {
+		return nil, 0, fmt.Errorf("invalid mirror upstream: %s", m.Upstream)
+	}
+	if m.Percentage != nil && (*m.Percentage < 0 || *m.Percentage > 100) {
+		return nil, 0, fmt.Errorf("invalid mirror percentage: %v", *m.Percentage)
+	}
+
+	var timeout time.Duration
+	if m.Timeout != "" {
+		if timeout, err = time.ParseDuration(m.Timeout); err != nil {
+			return nil, 0, fmt.Errorf("parse mirror timeout error: %w", err)
+		}
+	}
+	return target, timeout, nil
+}
+
+// Fault 故障注入, 用于演练上游故障时客户端的表现, 延迟后的请求继续转发到上游, 中止的请求不会转发
+type Fault struct {
+	// 延迟请求
+	Delay *FaultDelay `json:"delay,omitempty"`
+	// 中止请求并返回指定状态码
+	Abort *FaultAbort `json:"abort,omitempty"`
+	// 只对满足条件的请求注入故障（选填）
+	When *FaultCondition `json:"when,omitempty"`
+}
+
+// FaultDelay 延迟注入, 配置 max_duration 时在 duration 与 max_duration 之间随机延迟
+type FaultDelay struct {
+	// 注入的请求百分比[0, 100], 为空时对全部请求注入, 0 表示不注入
+	Percentage *float64 `json:"percentage,omitempty"`
+	// 延迟时长, 如 500ms
+	Duration string `json:"duration" validate:"required"`
+	// 随机延迟的上限（选填）
+	MaxDuration string `json:"max_duration"`
+}
+
+// FaultAbort 中止注入
+type FaultAbort struct {
+	// 注入的请求百分比[0, 100], 为空时对全部请求注入, 0 表示不注入
+	Percentage *float64 `json:"percentage,omitempty"`
+	// 返回的状态码, 如 503
+	Status int `json:"status" validate:"required,min=200,max=599"`
+	// 响应内容, 为空时返回默认错误信息
+	Body string `json:"body"`
+}
+
+// FaultCondition 故障注入条件
+type FaultCondition struct {
+	// 取值位置: $header.X-Chaos / $claim.role
+	Source string `json:"source" validate:"required"`
+	// 取值等于 value 时满足, 为空时只要求存在
+	Value string `json:"value"`
+}
+
+// ServiceAccessLog 服务级访问日志配置
+type ServiceAccessLog struct {
+	// 不记录该服务的访问日志
+	Disabled bool `json:"disabled"`
+	// 采样率, 为空时使用网关配置
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// CORSPolicy 跨域资源共享策略
+type CORSPolicy struct {
+	// 允许的来源: * 表示全部, 支持 https://*.example.com 形式的通配符, 以 ~ 开头时按正则表达式匹配
+	AllowOrigins []string `json:"allow_origins"`
+	// 允许的方法, 默认 GET, POST, PUT, PATCH, DELETE, HEAD
+	AllowMethods []string `json:"allow_methods"`
+	// 允许的请求头, 为空时允许预检请求中声明的全部请求头
+	AllowHeaders []string `json:"allow_headers"`
+	// 允许浏览器读取的响应头
+	ExposeHeaders []string `json:"expose_headers"`
+	// 是否允许携带凭证
+	AllowCredentials bool `json:"allow_credentials"`
+	// 预检结果缓存时间(秒)
+	MaxAge int `json:"max_age"`
+
+	// 编译后的来源匹配规则, 与 AllowOrigins 一一对应, 精确匹配与 * 对应 nil
+	origins []*regexp.Regexp
+}
+
+// Compile 编译允许来源中的正则与通配符, 加载配置时调用, 表达式无效时返回错误
+func (p *CORSPolicy) Compile() error {
+	origins, err := p.compileOrigins()
+	if err != nil {
+		return err
+	}
+	p.origins = origins
+	return nil
+}
+
+// OriginPatterns 返回编译后的来源匹配规则, 未经加载器编译的策略在调用时编译
+func (p *CORSPolicy) OriginPatterns() ([]*regexp.Regexp, error) {
+	if p.origins != nil {
+		return p.origins, nil
+	}
+	return p.compileOrigins()
+}
+
+func (p *CORSPolicy) compileOrigins() ([]*regexp.Regexp, error) {
+	origins := make([]*regexp.Regexp, len(p.AllowOrigins))
+	for i, pattern := range p.AllowOrigins {
+		var expr string
+		switch {
+		case pattern == "*":
+			continue
+		case strings.HasPrefix(pattern, "~"):
+			expr = strings.TrimPrefix(pattern, "~")
+		case strings.Contains(pattern, "*"):
+			// 通配符只匹配单级域名, 例如 https://*.example.com 不匹配 https://example.com
+			expr = "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `[^./:]+`) + "$"
+		default:
+			continue
+		}
+
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("compile cors origin %s error: %w", pattern, err)
+		}
+		origins[i] = regex
+	}
+	return origins, nil
+}
+
+// IPACL 客户端IP访问控制, 支持单个IP或CIDR
+// 命中 deny 的请求直接拒绝; allow 不为空时只允许命中 allow 的请求
+type IPACL struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+
+	// 加载配置时解析的 IP 与 CIDR
+	allow, deny []netip.Prefix
+	compiled    bool
+}
+
+// Compile 解析 IP 与 CIDR 列表, 加载配置时调用, 格式无效时返回错误
+func (acl *IPACL) Compile() error {
+	allow, deny, err := acl.parse()
+	if err != nil {
+		return err
+	}
+	acl.allow, acl.deny, acl.compiled = allow, deny, true
+	return nil
+}
+
+// Prefixes 返回解析后的 allow 与 deny 列表, 未经加载器解析的规则在调用时解析
+func (acl *IPACL) Prefixes() (allow, deny []netip.Prefix, err error) {
+	if acl.compiled {
+		return acl.allow, acl.deny, nil
+	}
+	return acl.parse()
+}
+
+func (acl *IPACL) parse() (allow, deny []netip.Prefix, err error) {
+	if allow, err = utils.ParsePrefixes(acl.Allow); err != nil {
+		return nil, nil, fmt.Errorf("parse ip allow list error: %w", err)
+	}
+	if deny, err = utils.ParsePrefixes(acl.Deny); err != nil {
+		return nil, nil, fmt.Errorf("parse ip deny list error: %w", err)
+	}
+	return allow, deny, nil
+}
+
+// Quota 按自然日或自然月计数的请求配额, 计数持久化到本地文件
+type Quota struct {
+	// 统计周期: daily / monthly
+	Period string `json:"period" validate:"required,oneof=daily monthly"`
+	// 周期内允许的请求数
+	Limit int64 `json:"limit" validate:"required,min=1"`
+	// 配额维度, 如 $header.X-Api-Key / $claim.sub, 取不到值时按客户端IP计数
+	Key string `json:"key"`
+	// 超出配额时的响应（选填）, 默认返回 429
+	Response *QuotaResponse `json:"response,omitempty"`
+}
+
+// QuotaResponse 超出配额时的响应
+type QuotaResponse struct {
+	// 状态码, 默认 429
+	Status int `json:"status"`
+	// 响应类型, 默认 application/json; charset=utf-8
+	ContentType string `json:"content_type"`
+	// 响应内容, 为空时返回默认错误信息
+	Body string `json:"body"`
+}
+
+// ConcurrencyLimit 并发限制配置, 超出上限且排队失败时返回 503
+type ConcurrencyLimit struct {
+	// 最大并发请求数, 自适应模式下为初始值
+	MaxInflight int `json:"max_inflight" validate:"required,min=1"`
+	// 等待队列长度, 0 表示不排队直接拒绝
+	MaxQueue int `json:"max_queue"`
+	// 排队超时时间, 默认 1s
+	QueueTimeout string `json:"queue_timeout"`
+	// 自适应并发上限（选填）
+	Adaptive *AdaptiveLimit `json:"adaptive,omitempty"`
+}
+
+// AdaptiveLimit 根据上游延迟自动调整并发上限
+type AdaptiveLimit struct {
+	// 调整算法: aimd(默认) / gradient
+	// aimd: 延迟超过 latency_threshold 或上游出错时按 backoff_ratio 乘性减小, 否则加性增大
+	// gradient: 根据长期平均延迟与当前延迟的比值调整
+	Algorithm string `json:"algorithm" validate:"omitempty,oneof=aimd gradient"`
+	// 并发上限的最小值, 默认 1
+	MinLimit int `json:"min_limit"`
+	// 并发上限的最大值, 默认 max_inflight 的 10 倍
+	MaxLimit int `json:"max_limit"`
+	// aimd 延迟阈值, 默认 1s
+	LatencyThreshold string `json:"latency_threshold"`
+	// aimd 减小比例, 默认 0.9
+	BackoffRatio float64 `json:"backoff_ratio"`
+}
+
+// RateLimit 限流配置
+type RateLimit struct {
+	// 限流算法: token_bucket(默认) / sliding_window
+	Algorithm string `json:"algorithm" validate:"omitempty,oneof=token_bucket sliding_window"`
+	// 时间窗口内允许的请求数
+	Limit int `json:"limit" validate:"required,min=1"`
+	// 时间窗口, 如 1s / 1m
+	Window string `json:"window" validate:"required"`
+	// 令牌桶容量, 默认等于 limit
+	Burst int `json:"burst" validate:"min=0"`
+	// 限流维度, 取不到值时退化为按客户端IP限流
+	// $ip: 客户端IP (默认)
+	// $route: 所有请求共享计数
+	// $header.X-Api-Key / $query.api_key: 请求头或查询参数, 如 API Key
+	// $claim.user_id: JWT声明, 支持嵌套选择器
+	Key string `json:"key"`
 }
 
 type ProxyConfig []string
@@ -130,7 +850,8 @@ func init() {
 	rand.New(rand.NewSource(time.Now().UnixNano()))
 }
 
-func (p ProxyConfig) pickAddress() string {
+// PickAddress 随机选择一个代理地址
+func (p ProxyConfig) PickAddress() string {
 	if len(p) == 0 {
 		return ""
 	}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+)
+
+func TestMatchRequest_PathParams(t *testing.T) {
+	rc := &RouteConfig{
+		Proxy:   ProxyConfig{"http://127.0.0.1:8080"},
+		Timeout: "1s",
+		Authorize: []AuthorizeRule{
+			{Claim: "roles", Op: "contains", Values: []string{"user"}},
+		},
+		Routes: []Route{
+			{
+				Match: `^/users/{id}/orders/(?P<orderId>\d+)$`,
+				Authorize: []AuthorizeRule{
+					{Claim: "sub", Op: "eq", Values: []string{"$path.id"}},
+				},
+			},
+			{Match: `^/a{2}$`},
+		},
+	}
+
+	req := httptest.NewRequest("GET", "http://proxy.example.com/users/42/orders/7", nil)
+	upstream := rc.MatchRequest(context.Background(), req)
+	if upstream == nil {
+		t.Fatalf("expected route matched")
+	}
+	assert.Equal(t, map[string]string{"id": "42", "orderId": "7"}, upstream.PathParams)
+	assert.Equal(t, "sub", upstream.Authorize[0].Claim)
+
+	// 普通正则量词不应被当作路径捕获
+	req = httptest.NewRequest("GET", "http://proxy.example.com/aa", nil)
+	upstream = rc.MatchRequest(context.Background(), req)
+	if upstream == nil {
+		t.Fatalf("expected route matched")
+	}
+	assert.Equal(t, 0, len(upstream.PathParams))
+	assert.Equal(t, "roles", upstream.Authorize[0].Claim)
+}
+
+func TestMatchRequest_ServiceProxy(t *testing.T) {
+	rc := &RouteConfig{
+		Proxy:   ProxyConfig{"http://127.0.0.1:8080"},
+		Timeout: "1s",
+		Routes: []Route{
+			{Match: "^/a/.*"},
+			{Match: "^/b/.*", Proxy: ProxyConfig{"http://127.0.0.1:9090"}},
+		},
+	}
+	if err := rc.Validate(); err != nil {
+		t.Fatalf("validate error: %v", err)
+	}
+
+	// 路由未配置 proxy 时使用服务级 proxy
+	upstream := rc.MatchRequest(context.Background(), httptest.NewRequest("GET", "http://proxy.example.com/a/1", nil))
+	if upstream == nil {
+		t.Fatalf("expected route matched")
+	}
+	assert.Equal(t, "http://127.0.0.1:8080", upstream.UpstreamURL)
+	assert.Equal(t, rc.Proxy, upstream.Proxy)
+
+	upstream = rc.MatchRequest(context.Background(), httptest.NewRequest("GET", "http://proxy.example.com/b/1", nil))
+	if upstream == nil {
+		t.Fatalf("expected route matched")
+	}
+	assert.Equal(t, "http://127.0.0.1:9090", upstream.UpstreamURL)
+}
+
+func TestMatchRequest_StripUpstreamTokenTarget(t *testing.T) {
+	rc := &RouteConfig{
+		Proxy:   ProxyConfig{"http://127.0.0.1:8080"},
+		Timeout: "1s",
+		Auth: &Auth{
+			Type:          "jwt",
+			Source:        "$query.access_token",
+			Claims:        map[string]string{"$header.X-User": "sub"},
+			UpstreamToken: &UpstreamToken{},
+		},
+		Routes: []Route{
+			{Match: "^/public/.*", DisableAuth: true},
+			{
+				Match: "^/api/.*",
+				Auth: &Auth{
+					Type:          "jwt",
+					Source:        "$query.access_token",
+					Claims:        map[string]string{"$header.X-User": "sub"},
+					UpstreamToken: &UpstreamToken{Target: "$header.X-Internal-Token"},
+				},
+			},
+		},
+	}
+
+	// 默认注入位置与自定义注入位置都需要清除, 禁用身份验证的路由同样生效
+	req := httptest.NewRequest("GET", "http://proxy.example.com/public/hello", nil)
+	upstream := rc.MatchRequest(context.Background(), req)
+	if upstream == nil {
+		t.Fatalf("expected route matched")
+	}
+	assert.Equal(t, true, slices.Contains(upstream.StripClaims, DefaultUpstreamTokenTarget))
+	assert.Equal(t, true, slices.Contains(upstream.StripClaims, "$header.X-Internal-Token"))
+}
+
+func TestValidate_AuthorizeRequiresAuth(t *testing.T) {
+	jwtAuth := &Auth{Type: "jwt", Source: "$header.Authorization", Secret: "secret", Claims: map[string]string{"$header.X-User": "sub"}}
+	proxy := ProxyConfig{"http://127.0.0.1:8080"}
+	rule := []AuthorizeRule{{Claim: "sub", Op: "eq", Values: []string{"42"}}}
+
+	testCases := []struct {
+		name string
+		rc   *RouteConfig
+		ok   bool
+	}{
+		{"service auth", &RouteConfig{Proxy: proxy, Auth: jwtAuth, Authorize: rule, Routes: []Route{{Match: "/a"}}}, true},
+		{"route auth", &RouteConfig{Proxy: proxy, Routes: []Route{{Match: "/a", Auth: jwtAuth, Authorize: rule}}}, true},
+		{"no auth", &RouteConfig{Proxy: proxy, Authorize: rule, Routes: []Route{{Match: "/a"}}}, false},
+		{"route without auth", &RouteConfig{Proxy: proxy, Routes: []Route{{Match: "/a", Authorize: rule}}}, false},
+		{"disable auth", &RouteConfig{Proxy: proxy, Auth: jwtAuth, Routes: []Route{{Match: "/a", DisableAuth: true, Authorize: rule}}}, false},
+		// 禁用身份验证的路由不继承服务级授权规则
+		{"disable auth inherits", &RouteConfig{Proxy: proxy, Auth: jwtAuth, Authorize: rule, Routes: []Route{{Match: "/a", DisableAuth: true}}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rc.Validate()
+			assert.Equal(t, tc.ok, err == nil)
+		})
+	}
+}
+
+func TestValidate_Fault(t *testing.T) {
+	proxy := ProxyConfig{"http://127.0.0.1:8080"}
+	zero, hundred, negative, over := 0.0, 100.0, -1.0, 101.0
+	testCases := []struct {
+		name  string
+		fault *Fault
+		ok    bool
+	}{
+		{"abort", &Fault{Abort: &FaultAbort{Status: 503}}, true},
+		{"abort without status", &Fault{Abort: &FaultAbort{}}, false},
+		{"abort informational status", &Fault{Abort: &FaultAbort{Status: 100}}, false},
+		{"abort invalid status", &Fault{Abort: &FaultAbort{Status: 1000}}, false},
+		{"delay", &Fault{Delay: &FaultDelay{Duration: "10ms", MaxDuration: "20ms"}}, true},
+		{"delay invalid duration", &Fault{Delay: &FaultDelay{Duration: "10"}}, false},
+		{"abort zero percentage", &Fault{Abort: &FaultAbort{Status: 503, Percentage: &zero}}, true},
+		{"abort full percentage", &Fault{Abort: &FaultAbort{Status: 503, Percentage: &hundred}}, true},
+		{"abort negative percentage", &Fault{Abort: &FaultAbort{Status: 503, Percentage: &negative}}, false},
+		{"delay percentage over 100", &Fault{Delay: &FaultDelay{Duration: "10ms", Percentage: &over}}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rc := &RouteConfig{Proxy: proxy, Routes: []Route{{Match: "/a", Fault: tc.fault}}}
+			assert.Equal(t, tc.ok, rc.Validate() == nil)
+		})
+	}
+}
+
+func TestValidate_CORS(t *testing.T) {
+	rc := &RouteConfig{
+		Proxy:  ProxyConfig{"http://127.0.0.1:8080"},
+		CORS:   &CORSPolicy{AllowOrigins: []string{"https://*.example.com", `~^https://[a-z]+\.example\.net$`}},
+		Routes: []Route{{Match: "/a"}},
+	}
+	if err := rc.Validate(); err != nil {
+		t.Fatalf("validate error: %v", err)
+	}
+	patterns, err := rc.CORS.OriginPatterns()
+	if err != nil {
+		t.Fatalf("origin patterns error: %v", err)
+	}
+	assert.Equal(t, true, patterns[0].MatchString("https://app.example.com"))
+
+	rc.Routes[0].CORS = &CORSPolicy{AllowOrigins: []string{"~^https://(.example.com"}}
+	if rc.Validate() == nil {
+		t.Fatalf("expected invalid origin regex rejected")
+	}
+}
+
+func TestValidate_IPACL(t *testing.T) {
+	rc := &RouteConfig{
+		Proxy:  ProxyConfig{"http://127.0.0.1:8080"},
+		IPACL:  &IPACL{Allow: []string{"10.0.0.0/8", "192.168.1.1"}},
+		Routes: []Route{{Match: "/a"}},
+	}
+	if err := rc.Validate(); err != nil {
+		t.Fatalf("validate error: %v", err)
+	}
+	allow, _, err := rc.IPACL.Prefixes()
+	if err != nil {
+		t.Fatalf("prefixes error: %v", err)
+	}
+	assert.Equal(t, 2, len(allow))
+
+	rc.Routes[0].IPACL = &IPACL{Deny: []string{"10.0.0.0/33"}}
+	if rc.Validate() == nil {
+		t.Fatalf("expected invalid CIDR rejected")
+	}
+}
+
+func TestValidate_Action(t *testing.T) {
+	rc := &RouteConfig{
+		Routes: []Route{
+			{Match: "/a", Redirect: &Redirect{Status: 308, Location: "/b/{{.Params.id}}"}},
+			{Match: "/c", DirectResponse: &DirectResponse{Body: "ok {{.Method}}"}},
+		},
+	}
+	if err := rc.Validate(); err != nil {
+		t.Fatalf("validate error: %v", err)
+	}
+	body, err := rc.Routes[1].DirectResponse.templates.Body.Render(struct{ Method string }{"GET"})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	assert.Equal(t, "ok GET", string(body))
+
+	rc.Routes[0].Redirect = &Redirect{Status: 200, Location: "/b"}
+	if rc.Validate() == nil {
+		t.Fatalf("expected invalid redirect status rejected")
+	}
+
+	rc.Routes[0].Redirect = &Redirect{Location: "/b/{{.Params.id"}
+	if rc.Validate() == nil {
+		t.Fatalf("expected invalid template rejected")
+	}
+}
+
+func TestValidate_RateLimit(t *testing.T) {
+	rc := &RouteConfig{
+		Proxy:     ProxyConfig{"http://127.0.0.1:8080"},
+		RateLimit: &RateLimit{Limit: 10, Window: "1s"},
+		Routes:    []Route{{Match: "/a", RateLimit: &RateLimit{Limit: 1, Window: "1m", Algorithm: "sliding_window"}}},
+	}
+	if err := rc.Validate(); err != nil {
+		t.Fatalf("validate error: %v", err)
+	}
+
+	for _, rl := range []*RateLimit{
+		{Limit: 1, Window: "1 minute"},
+		{Limit: 1, Window: "0s"},
+		{Limit: 0, Window: "1s"},
+		{Limit: 1, Window: "1s", Algorithm: "leaky_bucket"},
+	} {
+		rc.Routes[0].RateLimit = rl
+		if rc.Validate() == nil {
+			t.Fatalf("expected invalid rate limit rejected: %+v", rl)
+		}
+	}
+}
+
+func TestValidate_Fields(t *testing.T) {
+	proxy := ProxyConfig{"http://127.0.0.1:8080"}
+	jwtAuth := &Auth{Type: "jwt", Source: "$header.Authorization", Secret: "secret"}
+	split := func(overrides ...SplitOverride) *TrafficSplit {
+		return &TrafficSplit{
+			Groups: []UpstreamGroup{
+				{Name: "stable", Weight: 100, Proxy: proxy},
+				{Name: "canary", Proxy: proxy},
+			},
+			Overrides: overrides,
+		}
+	}
+
+	testCases := []struct {
+		name string
+		rc   *RouteConfig
+		ok   bool
+	}{
+		{"valid", &RouteConfig{Proxy: proxy, Auth: jwtAuth, Routes: []Route{{Match: "/a"}}}, true},
+		{"no routes", &RouteConfig{Proxy: proxy}, false},
+		{"no match", &RouteConfig{Proxy: proxy, Routes: []Route{{}}}, false},
+		{"no proxy", &RouteConfig{Routes: []Route{{Match: "/a"}}}, false},
+		{"route proxy", &RouteConfig{Routes: []Route{{Match: "/a", Proxy: proxy}}}, true},
+		{"action without proxy", &RouteConfig{Routes: []Route{{Match: "/a", DirectResponse: &DirectResponse{Body: "ok"}}}}, true},
+		{"auth type", &RouteConfig{Proxy: proxy, Auth: &Auth{Type: "basic", Source: "$header.Authorization", Secret: "secret"}, Routes: []Route{{Match: "/a"}}}, false},
+		{"auth secret", &RouteConfig{Proxy: proxy, Routes: []Route{{Match: "/a", Auth: &Auth{Type: "jwt", Source: "$header.Authorization"}}}}, false},
+		{"auth array format", &RouteConfig{Proxy: proxy, Auth: &Auth{Type: "jwt", Source: "$query.token", Secret: "secret", ArrayFormat: "csv"}, Routes: []Route{{Match: "/a"}}}, false},
+		{"authorize op", &RouteConfig{Proxy: proxy, Auth: jwtAuth, Authorize: []AuthorizeRule{{Claim: "sub", Op: "ne", Values: []string{"1"}}}, Routes: []Route{{Match: "/a"}}}, false},
+		{"authorize values", &RouteConfig{Proxy: proxy, Auth: jwtAuth, Routes: []Route{{Match: "/a", Authorize: []AuthorizeRule{{Claim: "sub", Op: "eq"}}}}}, false},
+		{"quota period", &RouteConfig{Proxy: proxy, Quota: &Quota{Period: "weekly", Limit: 1}, Routes: []Route{{Match: "/a"}}}, false},
+		{"quota limit", &RouteConfig{Proxy: proxy, Routes: []Route{{Match: "/a", Quota: &Quota{Period: "daily"}}}}, false},
+		{"concurrency", &RouteConfig{Proxy: proxy, Concurrency: &ConcurrencyLimit{}, Routes: []Route{{Match: "/a"}}}, false},
+		{"adaptive algorithm", &RouteConfig{Proxy: proxy, Routes: []Route{{Match: "/a", Concurrency: &ConcurrencyLimit{MaxInflight: 1, Adaptive: &AdaptiveLimit{Algorithm: "vegas"}}}}}, false},
+		{"affinity type", &RouteConfig{Proxy: proxy, Affinity: &Affinity{Type: "header"}, Routes: []Route{{Match: "/a"}}}, false},
+		{"security preset", &RouteConfig{Proxy: proxy, SecurityHeaders: &SecurityHeaders{Preset: "paranoid"}, Routes: []Route{{Match: "/a"}}}, false},
+		{"fault condition", &RouteConfig{Proxy: proxy, Fault: &Fault{Abort: &FaultAbort{Status: 503}, When: &FaultCondition{}}, Routes: []Route{{Match: "/a"}}}, false},
+		{"split", &RouteConfig{Routes: []Route{{Match: "/a", Split: split(SplitOverride{Source: "$header.X-Canary", Value: "true", Group: "canary"})}}}, true},
+		{"split no groups", &RouteConfig{Routes: []Route{{Match: "/a", Split: &TrafficSplit{}}}}, false},
+		{"split unknown group", &RouteConfig{Routes: []Route{{Match: "/a", Split: split(SplitOverride{Source: "$header.X-Canary", Value: "true", Group: "beta"})}}}, false},
+		{"split override source", &RouteConfig{Routes: []Route{{Match: "/a", Split: split(SplitOverride{Groups: []string{"canary"}})}}}, false},
+		{"split duplicate group", &RouteConfig{Routes: []Route{{Match: "/a", Split: &TrafficSplit{Groups: []UpstreamGroup{{Name: "a", Proxy: proxy}, {Name: "a", Proxy: proxy}}}}}}, false},
+		{"split group proxy", &RouteConfig{Routes: []Route{{Match: "/a", Split: &TrafficSplit{Groups: []UpstreamGroup{{Name: "a"}}}}}}, false},
+		{"redirect status", &RouteConfig{Routes: []Route{{Match: "/a", Redirect: &Redirect{Status: 303, Location: "/b"}}}}, false},
+		{"mirror upstream", &RouteConfig{Proxy: proxy, Mirror: &Mirror{}, Routes: []Route{{Match: "/a"}}}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rc.Validate()
+			assert.Equal(t, tc.ok, err == nil)
+		})
+	}
+}
+
+func TestValidate_TagErrors(t *testing.T) {
+	rc := &RouteConfig{
+		Proxy:  ProxyConfig{"http://127.0.0.1:8080"},
+		Routes: []Route{{Match: "/a", Fault: &Fault{Abort: &FaultAbort{Status: 700}}}},
+	}
+	var errs validator.ValidationErrors
+	if err := rc.Validate(); !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+	assert.Equal(t, "RouteConfig.routes[0].fault.abort.status", errs[0].Namespace())
+	assert.Equal(t, "max", errs[0].Tag())
+}
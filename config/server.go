@@ -24,11 +24,204 @@ type TransportConfig struct {
 	ExpectContinueTimeout time.Duration `json:"expect_continue_timeout"` // 期望继续超时时间
 }
 
+// TokenIssuerConfig 网关签发上游内部身份令牌的配置
+type TokenIssuerConfig struct {
+	Issuer  string `json:"issuer"`   // 签发者, 默认 litegate
+	KeyFile string `json:"key_file"` // PEM 格式的 RSA/ECDSA 私钥文件, 为空时启动时生成临时 ECDSA P-256 密钥
+	KeyID   string `json:"key_id"`   // JWKS 中的 kid, 为空时根据公钥计算
+}
+
+// RevocationConfig 令牌吊销列表配置
+type RevocationConfig struct {
+	File string `json:"file"` // 吊销列表文件, 支持热加载, 为空时仅保存在内存中
+}
+
+// RedisConfig Redis 协议兼容存储配置
+type RedisConfig struct {
+	Addr         string        `json:"addr"`          // 地址, 如 127.0.0.1:6379
+	Password     string        `json:"password"`      // 密码
+	DB           int           `json:"db"`            // 数据库编号
+	KeyPrefix    string        `json:"key_prefix"`    // key 前缀
+	PoolSize     int           `json:"pool_size"`     // 最大空闲连接数
+	DialTimeout  time.Duration `json:"dial_timeout"`  // 连接超时时间
+	IOTimeout    time.Duration `json:"io_timeout"`    // 读写超时时间
+	SyncInterval time.Duration `json:"sync_interval"` // 本地预聚合计数的最长同步间隔, 0 表示每次请求都同步
+	BatchSize    int           `json:"batch_size"`    // 本地累计多少次请求后立即同步
+}
+
+// RateLimitConfig 限流计数存储配置
+type RateLimitConfig struct {
+	MaxKeys    int          `json:"max_keys"`    // 内存中最多保存的限流计数数量, 超出后按LRU淘汰
+	Store      string       `json:"store"`       // 计数存储: memory(默认) / redis
+	Redis      *RedisConfig `json:"redis"`       // redis 存储配置
+	FailPolicy string       `json:"fail_policy"` // 存储不可用时的处理策略: open(放行, 默认) / closed(拒绝)
+}
+
+// RequestLimitConfig 请求大小限制, 超出时分别返回 413 / 431 / 414
+type RequestLimitConfig struct {
+	MaxBodyBytes   int64 `json:"max_body_bytes"`   // 默认请求体上限, 可被服务及路由配置覆盖, 负数表示不限制
+	MaxHeaderCount int   `json:"max_header_count"` // 请求头数量上限
+	MaxHeaderBytes int   `json:"max_header_bytes"` // 请求头总大小上限(名称与值的字节数之和)
+	MaxURLLength   int   `json:"max_url_length"`   // 请求 URL(路径与查询参数) 长度上限
+}
+
+// ForwardedConfig 转发给上游的 X-Forwarded-* 与 RFC 7239 Forwarded 请求头配置
+// 直连对端为受信任代理时在已有请求头基础上追加, 否则覆盖客户端传入的请求头
+type ForwardedConfig struct {
+	// 生成的请求头: X-Forwarded-For / X-Forwarded-Proto / X-Forwarded-Host / X-Forwarded-Prefix / Forwarded
+	// 默认生成除 Forwarded 外的全部请求头, 未列出的请求头会被移除
+	Headers []string `json:"headers"`
+}
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterLog    = "log"
+	TracingExporterMemory = "memory"
+)
+
+// TracingConfig 链路追踪配置
+type TracingConfig struct {
+	Exporter      string            `json:"exporter"`       // 导出器: none(默认, 不启用) / otlp / log / memory
+	Endpoint      string            `json:"endpoint"`       // OTLP/HTTP 上报地址, 默认 http://localhost:4318/v1/traces
+	Headers       map[string]string `json:"headers"`        // 上报时附加的请求头, 如鉴权信息
+	ServiceName   string            `json:"service_name"`   // 上报的 service.name, 默认 litegate
+	SampleRatio   *float64          `json:"sample_ratio"`   // 客户端未携带采样标记时的采样率 [0, 1], 为空时为 1, 0 表示只沿用客户端的采样决定
+	Propagators   []string          `json:"propagators"`    // 传播格式: tracecontext / b3 / b3multi, 默认 tracecontext, b3
+	BatchSize     int               `json:"batch_size"`     // 单次上报的最大跨度数量
+	FlushInterval time.Duration     `json:"flush_interval"` // 上报间隔
+	ExportTimeout time.Duration     `json:"export_timeout"` // 单次上报超时时间
+}
+
+const (
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatTemplate = "template"
+	AccessLogOutputStdout   = "stdout"
+	AccessLogOutputFile     = "file"
+)
+
+// AccessLogConfig 访问日志配置, 每个请求输出一行
+type AccessLogConfig struct {
+	Enabled       bool     `json:"enabled"`        // 是否启用
+	Format        string   `json:"format"`         // 格式: json(默认) / template
+	Template      string   `json:"template"`       // format 为 template 时使用的 text/template 模板
+	Output        string   `json:"output"`         // 输出: stdout(默认) / file
+	File          string   `json:"file"`           // 日志文件路径
+	MaxSizeMB     int      `json:"max_size_mb"`    // 单个日志文件大小上限, 超出后轮转
+	MaxBackups    int      `json:"max_backups"`    // 保留的轮转文件数量
+	SampleRatio   float64  `json:"sample_ratio"`   // 采样率, 默认 1, 5xx 响应始终记录
+	Headers       []string `json:"headers"`        // 额外记录的请求头
+	RedactHeaders []string `json:"redact_headers"` // 记录时脱敏的请求头
+	RedactQuery   []string `json:"redact_query"`   // 记录时脱敏的查询参数
+}
+
+const (
+	RequestIDTrustAlways         = "always"
+	RequestIDTrustTrustedProxies = "trusted_proxies"
+	RequestIDTrustNever          = "never"
+
+	RequestIDGeneratorUUIDv7 = "uuidv7"
+	RequestIDGeneratorULID   = "ulid"
+)
+
+// RequestIDConfig 请求ID配置, 请求ID转发给上游并在响应头中返回
+type RequestIDConfig struct {
+	Header    string `json:"header"`    // 请求ID请求头, 默认 X-Request-ID
+	Trust     string `json:"trust"`     // 沿用客户端传入的请求ID: always(默认) / trusted_proxies(仅受信任代理) / never
+	Generator string `json:"generator"` // 生成算法: uuidv7(默认) / ulid
+}
+
+// RecorderConfig 流量录制配置, 按服务将采样的请求与响应写入 {dir}/{service}.jsonl
+type RecorderConfig struct {
+	Enabled       bool     `json:"enabled"`        // 是否启用
+	Dir           string   `json:"dir"`            // 录制文件目录
+	Services      []string `json:"services"`       // 录制的服务, 为空时录制全部服务
+	SampleRatio   float64  `json:"sample_ratio"`   // 采样率
+	MaxBodyBytes  int64    `json:"max_body_bytes"` // 请求体与响应体各自录制的最大字节数, 超出部分截断
+	MaxSizeMB     int      `json:"max_size_mb"`    // 单个录制文件大小上限, 超出后轮转
+	MaxBackups    int      `json:"max_backups"`    // 保留的轮转文件数量
+	RedactHeaders []string `json:"redact_headers"` // 录制时脱敏的请求头与响应头
+	RedactQuery   []string `json:"redact_query"`   // 录制时脱敏的查询参数
+}
+
+// AffinityConfig 会话保持配置
+type AffinityConfig struct {
+	Secret string `json:"secret"` // 签名会话保持 Cookie 的密钥, 为空时启动时随机生成, 多实例部署时需配置相同的密钥
+}
+
+// ServerConfig HTTP 服务超时配置
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout"` // 读取请求头的超时时间
+	ReadTimeout       time.Duration `json:"read_timeout"`        // 读取整个请求的超时时间, 0 表示不限制
+	WriteTimeout      time.Duration `json:"write_timeout"`       // 写入响应的超时时间, 0 表示不限制
+	IdleTimeout       time.Duration `json:"idle_timeout"`        // 空闲连接的保持时间
+	ShutdownTimeout   time.Duration `json:"shutdown_timeout"`    // 退出时等待在途请求结束的时间
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	Token        string `json:"token"`         // 访问 /admin 与 /debug 接口的 Bearer 令牌, 为空时拒绝所有管理请求
+	MetricsToken string `json:"metrics_token"` // 只能访问 /metrics 的 Bearer 令牌, 供监控系统抓取指标, 管理令牌同样可以访问
+}
+
+// QuotaConfig 配额计数存储配置
+type QuotaConfig struct {
+	File          string        `json:"file"`           // 配额计数文件, 为空时仅保存在内存中
+	FlushInterval time.Duration `json:"flush_interval"` // 计数写入文件的间隔
+	MaxConsumers  int           `json:"max_consumers"`  // 内存中最多保存的消费者计数数量, 超出后按LRU淘汰
+}
+
+// AdmissionConfig 网关全局准入控制, 在途请求超过阈值时按优先级类别拒绝或排队
+type AdmissionConfig struct {
+	MaxInflight  int             `json:"max_inflight"`  // 网关全局在途请求阈值, 0 表示不启用
+	QueueTimeout time.Duration   `json:"queue_timeout"` // 排队超时时间
+	DefaultClass string          `json:"default_class"` // 未指定优先级的请求所属类别
+	Classes      []PriorityClass `json:"classes"`       // 优先级类别
+}
+
+// PriorityClass 优先级类别
+type PriorityClass struct {
+	Name     string          `json:"name"`      // 类别名称, 在路由的 priority 中引用
+	Priority int             `json:"priority"`  // 优先级, 数值越大越优先被放行
+	MaxQueue int             `json:"max_queue"` // 超过阈值时的排队长度, 0 表示直接拒绝
+	Reserved int             `json:"reserved"`  // 为该类别及更高优先级类别预留的在途请求数, 低优先级类别不能占用
+	Claims   []AuthorizeRule `json:"claims"`    // 满足全部声明条件的请求归入该类别, 如 tier=premium
+}
+
 type GatewayConfig struct {
 	// Services list which allowed to be accessed
-	Services  []string         `json:"services"`
-	Timeout   time.Duration    `json:"timeout"`
-	Transport *TransportConfig `json:"transport"`
+	Services    []string           `json:"services"`
+	Timeout     time.Duration      `json:"timeout"`
+	Transport   *TransportConfig   `json:"transport"`
+	TokenIssuer *TokenIssuerConfig `json:"token_issuer"`
+	Revocation  *RevocationConfig  `json:"revocation"`
+	RateLimit   *RateLimitConfig   `json:"rate_limit"`
+	Admission   *AdmissionConfig   `json:"admission"`
+	Quota       *QuotaConfig       `json:"quota"`
+	// 请求大小限制
+	RequestLimits *RequestLimitConfig `json:"request_limits"`
+	// 受信任的代理 IP/CIDR, 仅从这些对端转发的请求读取客户端IP请求头
+	TrustedProxies []string `json:"trusted_proxies"`
+	// 读取客户端IP的请求头, 默认 X-Forwarded-For, X-Real-IP
+	ClientIPHeaders []string `json:"client_ip_headers"`
+	// 监听端口启用 PROXY protocol(v1/v2), 仅解析受信任代理发送的协议头
+	ProxyProtocol bool `json:"proxy_protocol"`
+	// 转发请求头
+	Forwarded *ForwardedConfig `json:"forwarded"`
+	// 链路追踪
+	Tracing *TracingConfig `json:"tracing"`
+	// 访问日志
+	AccessLog *AccessLogConfig `json:"access_log"`
+	// 请求ID
+	RequestID *RequestIDConfig `json:"request_id"`
+	// 流量录制
+	Recorder *RecorderConfig `json:"recorder"`
+	// 会话保持
+	Affinity *AffinityConfig `json:"affinity"`
+	// 管理接口
+	Admin *AdminConfig `json:"admin"`
+	// 启用 PROXY protocol 时标准库 HTTP 服务的超时配置
+	Server *ServerConfig `json:"server"`
 }
 
 func (c *GatewayConfig) SetDefault() {
@@ -51,4 +244,211 @@ func (c *GatewayConfig) SetDefault() {
 	if c.Timeout == 0 {
 		c.Timeout = 15 * time.Second
 	}
+
+	if c.TokenIssuer == nil {
+		c.TokenIssuer = &TokenIssuerConfig{}
+	}
+	if c.TokenIssuer.Issuer == "" {
+		c.TokenIssuer.Issuer = "litegate"
+	}
+
+	if c.RateLimit == nil {
+		c.RateLimit = &RateLimitConfig{}
+	}
+	if c.RateLimit.MaxKeys == 0 {
+		c.RateLimit.MaxKeys = 100000
+	}
+	if c.RateLimit.Store == "" {
+		c.RateLimit.Store = "memory"
+	}
+	if c.RateLimit.FailPolicy == "" {
+		c.RateLimit.FailPolicy = "open"
+	}
+	if c.RateLimit.Redis != nil {
+		c.RateLimit.Redis.SetDefault()
+	}
+
+	if c.Admission == nil {
+		c.Admission = &AdmissionConfig{}
+	}
+	if c.Admission.QueueTimeout == 0 {
+		c.Admission.QueueTimeout = time.Second
+	}
+
+	if c.Quota == nil {
+		c.Quota = &QuotaConfig{}
+	}
+	if c.Quota.FlushInterval == 0 {
+		c.Quota.FlushInterval = 5 * time.Second
+	}
+
+	if c.RequestLimits == nil {
+		c.RequestLimits = &RequestLimitConfig{}
+	}
+	if c.RequestLimits.MaxBodyBytes == 0 {
+		c.RequestLimits.MaxBodyBytes = 10 << 20
+	}
+	if c.RequestLimits.MaxHeaderCount == 0 {
+		c.RequestLimits.MaxHeaderCount = 100
+	}
+	if c.RequestLimits.MaxHeaderBytes == 0 {
+		c.RequestLimits.MaxHeaderBytes = 64 << 10
+	}
+	if c.RequestLimits.MaxURLLength == 0 {
+		c.RequestLimits.MaxURLLength = 8 << 10
+	}
+
+	if len(c.ClientIPHeaders) == 0 {
+		c.ClientIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+	}
+
+	if c.Forwarded == nil {
+		c.Forwarded = &ForwardedConfig{}
+	}
+	if len(c.Forwarded.Headers) == 0 {
+		c.Forwarded.Headers = []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Forwarded-Prefix"}
+	}
+
+	if c.Tracing == nil {
+		c.Tracing = &TracingConfig{}
+	}
+	c.Tracing.SetDefault()
+
+	if c.AccessLog == nil {
+		c.AccessLog = &AccessLogConfig{}
+	}
+	c.AccessLog.SetDefault()
+
+	if c.RequestID == nil {
+		c.RequestID = &RequestIDConfig{}
+	}
+	if c.RequestID.Header == "" {
+		c.RequestID.Header = "X-Request-ID"
+	}
+	if c.RequestID.Trust == "" {
+		c.RequestID.Trust = RequestIDTrustAlways
+	}
+	if c.RequestID.Generator == "" {
+		c.RequestID.Generator = RequestIDGeneratorUUIDv7
+	}
+
+	if c.Recorder == nil {
+		c.Recorder = &RecorderConfig{}
+	}
+	c.Recorder.SetDefault()
+
+	if c.Affinity == nil {
+		c.Affinity = &AffinityConfig{}
+	}
+
+	if c.Admin == nil {
+		c.Admin = &AdminConfig{}
+	}
+
+	if c.Server == nil {
+		c.Server = &ServerConfig{}
+	}
+	if c.Server.ReadHeaderTimeout == 0 {
+		c.Server.ReadHeaderTimeout = 10 * time.Second
+	}
+	if c.Server.IdleTimeout == 0 {
+		c.Server.IdleTimeout = 120 * time.Second
+	}
+	if c.Server.ShutdownTimeout == 0 {
+		c.Server.ShutdownTimeout = 15 * time.Second
+	}
+}
+
+func (c *TracingConfig) SetDefault() {
+	if c.Exporter == "" {
+		c.Exporter = TracingExporterNone
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = "http://localhost:4318/v1/traces"
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = "litegate"
+	}
+	if c.SampleRatio == nil {
+		ratio := 1.0
+		c.SampleRatio = &ratio
+	}
+	if len(c.Propagators) == 0 {
+		c.Propagators = []string{"tracecontext", "b3"}
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 512
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = 5 * time.Second
+	}
+	if c.ExportTimeout == 0 {
+		c.ExportTimeout = 10 * time.Second
+	}
+}
+
+func (c *AccessLogConfig) SetDefault() {
+	if c.Format == "" {
+		c.Format = AccessLogFormatJSON
+	}
+	if c.Output == "" {
+		c.Output = AccessLogOutputStdout
+	}
+	if c.MaxSizeMB == 0 {
+		c.MaxSizeMB = 100
+	}
+	if c.MaxBackups == 0 {
+		c.MaxBackups = 5
+	}
+	if c.SampleRatio == 0 {
+		c.SampleRatio = 1
+	}
+	if len(c.RedactHeaders) == 0 {
+		c.RedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+	}
+	if len(c.RedactQuery) == 0 {
+		c.RedactQuery = []string{"token", "access_token", "api_key"}
+	}
+}
+
+func (c *RecorderConfig) SetDefault() {
+	if c.Dir == "" {
+		c.Dir = "./records"
+	}
+	if c.SampleRatio == 0 {
+		c.SampleRatio = 1
+	}
+	if c.MaxBodyBytes == 0 {
+		c.MaxBodyBytes = 64 << 10
+	}
+	if c.MaxSizeMB == 0 {
+		c.MaxSizeMB = 100
+	}
+	if c.MaxBackups == 0 {
+		c.MaxBackups = 5
+	}
+	if len(c.RedactHeaders) == 0 {
+		c.RedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	}
+	if len(c.RedactQuery) == 0 {
+		c.RedactQuery = []string{"token", "access_token", "api_key"}
+	}
+}
+
+func (c *RedisConfig) SetDefault() {
+	if c.KeyPrefix == "" {
+		c.KeyPrefix = "litegate:ratelimit"
+	}
+	if c.PoolSize == 0 {
+		c.PoolSize = 32
+	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = 500 * time.Millisecond
+	}
+	if c.IOTimeout == 0 {
+		c.IOTimeout = 200 * time.Millisecond
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 1
+	}
 }
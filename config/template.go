@@ -0,0 +1,184 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Template 网关直接响应使用的模板, 加载配置时解析, 不包含模板语法时原样输出
+type Template struct {
+	text string
+	tmpl *template.Template
+}
+
+func NewTemplate(text string) (*Template, error) {
+	t := &Template{text: text}
+	if !strings.Contains(text, "{{") {
+		return t, nil
+	}
+
+	tmpl, err := template.New("action").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	t.tmpl = tmpl
+	return t, nil
+}
+
+// Render 使用模板变量渲染模板
+func (t *Template) Render(data any) ([]byte, error) {
+	if t.tmpl == nil {
+		return []byte(t.text), nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// HeaderTemplates 响应头名称 -> 响应头取值模板
+type HeaderTemplates map[string]*Template
+
+func newHeaderTemplates(headers map[string]string) (HeaderTemplates, error) {
+	templates := make(HeaderTemplates, len(headers))
+	for name, value := range headers {
+		tmpl, err := NewTemplate(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse header %s template error: %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// DirectResponseTemplates 直接响应解析后的响应内容与响应头模板
+type DirectResponseTemplates struct {
+	Body    *Template
+	Headers HeaderTemplates
+}
+
+// Compile 读取响应内容文件并解析模板, 加载配置时调用, 模板无效时返回错误
+func (d *DirectResponse) Compile(configDir string) error {
+	templates, err := d.parse(configDir)
+	if err != nil {
+		return err
+	}
+	d.templates = templates
+	return nil
+}
+
+// Templates 返回解析后的模板, 未经加载器解析的配置在调用时解析
+func (d *DirectResponse) Templates(configDir string) (*DirectResponseTemplates, error) {
+	if d.templates != nil {
+		return d.templates, nil
+	}
+	return d.parse(configDir)
+}
+
+func (d *DirectResponse) parse(configDir string) (*DirectResponseTemplates, error) {
+	if d.Status != 0 && (d.Status < 100 || d.Status > 599) {
+		return nil, fmt.Errorf("invalid direct response status: %d", d.Status)
+	}
+
+	body := d.Body
+	if d.BodyFile != "" {
+		b, err := os.ReadFile(filepath.Join(configDir, d.BodyFile))
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+
+	templates := &DirectResponseTemplates{}
+	var err error
+	if templates.Body, err = NewTemplate(body); err != nil {
+		return nil, fmt.Errorf("parse direct response body template error: %w", err)
+	}
+	if templates.Headers, err = newHeaderTemplates(d.Headers); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// Compile 校验状态码并解析跳转地址模板, 加载配置时调用
+func (r *Redirect) Compile() error {
+	location, err := r.parse()
+	if err != nil {
+		return err
+	}
+	r.location = location
+	return nil
+}
+
+// LocationTemplate 返回解析后的跳转地址模板, 未经加载器解析的配置在调用时解析
+func (r *Redirect) LocationTemplate() (*Template, error) {
+	if r.location != nil {
+		return r.location, nil
+	}
+	return r.parse()
+}
+
+func (r *Redirect) parse() (*Template, error) {
+	switch r.Status {
+	case 0, 301, 302, 307, 308:
+	default:
+		return nil, fmt.Errorf("invalid redirect status: %d", r.Status)
+	}
+	if r.Location == "" {
+		return nil, fmt.Errorf("redirect location is required")
+	}
+
+	location, err := NewTemplate(r.Location)
+	if err != nil {
+		return nil, fmt.Errorf("parse redirect location template error: %w", err)
+	}
+	return location, nil
+}
+
+// MockTemplates 样例解析后的文件路径与响应头模板, 未配置文件路径时 File 为 nil
+type MockTemplates struct {
+	File    *Template
+	Headers HeaderTemplates
+}
+
+// Compile 解析样例文件路径与响应头模板, 加载配置时调用, 样例内容在请求时读取
+func (m *Mock) Compile() error {
+	templates, err := m.parse()
+	if err != nil {
+		return err
+	}
+	m.templates = templates
+	return nil
+}
+
+// Templates 返回解析后的模板, 未经加载器解析的配置在调用时解析
+func (m *Mock) Templates() (*MockTemplates, error) {
+	if m.templates != nil {
+		return m.templates, nil
+	}
+	return m.parse()
+}
+
+func (m *Mock) parse() (*MockTemplates, error) {
+	if m.Status != 0 && (m.Status < 100 || m.Status > 599) {
+		return nil, fmt.Errorf("invalid mock status: %d", m.Status)
+	}
+
+	templates := &MockTemplates{}
+	var err error
+	if m.File != "" {
+		if templates.File, err = NewTemplate(m.File); err != nil {
+			return nil, fmt.Errorf("parse mock file template error: %w", err)
+		}
+	}
+	if templates.Headers, err = newHeaderTemplates(m.Headers); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate 按结构体 validate 标签校验配置, 错误信息中的字段名使用 json 名称
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// Validate 校验路由配置, 加载器拒绝加载校验失败的配置文件
+// 先按 validate 标签校验字段取值, 再检查标签无法表达的时长格式与字段间约束, 并预编译请求时使用的规则
+func (rc *RouteConfig) Validate() error {
+	if err := validate.Struct(rc); err != nil {
+		return err
+	}
+	if err := rc.validateService(); err != nil {
+		return err
+	}
+	for _, route := range rc.Routes {
+		if err := rc.validateRoute(route); err != nil {
+			return fmt.Errorf("route %s: %w", route.Match, err)
+		}
+	}
+	return nil
+}
+
+// validateService 校验服务级配置
+func (rc *RouteConfig) validateService() error {
+	if err := validateRateLimit(rc.RateLimit); err != nil {
+		return err
+	}
+	if err := validateFault(rc.Fault); err != nil {
+		return err
+	}
+	if err := compileCORS(rc.CORS); err != nil {
+		return err
+	}
+	if err := compileIPACL(rc.IPACL); err != nil {
+		return err
+	}
+	return compileMirror(rc.Mirror)
+}
+
+// validateRoute 校验路由级配置
+func (rc *RouteConfig) validateRoute(route Route) error {
+	if err := rc.validateUpstream(route); err != nil {
+		return err
+	}
+	if err := rc.validateAuthorize(route); err != nil {
+		return err
+	}
+	if err := validateRateLimit(route.RateLimit); err != nil {
+		return err
+	}
+	if err := validateSplit(route.Split); err != nil {
+		return err
+	}
+	if err := validateFault(route.Fault); err != nil {
+		return err
+	}
+	if err := compileCORS(route.CORS); err != nil {
+		return err
+	}
+	if err := compileIPACL(route.IPACL); err != nil {
+		return err
+	}
+	if err := compileMirror(route.Mirror); err != nil {
+		return err
+	}
+	return rc.compileAction(route)
+}
+
+// validateUpstream 路由需要有可转发的上游, 未配置 proxy 时使用服务级 proxy, 由网关直接响应的路由除外
+func (rc *RouteConfig) validateUpstream(route Route) error {
+	if route.DirectResponse != nil || route.Redirect != nil || route.Mock != nil || route.Split != nil {
+		return nil
+	}
+	if len(route.Proxy) == 0 && len(rc.Proxy) == 0 {
+		return fmt.Errorf("proxy is required")
+	}
+	return nil
+}
+
+// validateAuthorize 授权规则依赖身份验证得到的声明, 未启用身份验证的路由不能配置授权规则
+func (rc *RouteConfig) validateAuthorize(route Route) error {
+	auth, authorize := rc.Auth, rc.Authorize
+	if route.DisableAuth {
+		auth, authorize = nil, nil
+	} else if route.Auth != nil {
+		auth = route.Auth
+	}
+	if len(route.Authorize) > 0 {
+		authorize = route.Authorize
+	}
+
+	if len(authorize) > 0 && auth == nil {
+		return fmt.Errorf("authorize requires auth")
+	}
+	return nil
+}
+
+// validateFault 注入百分比取值 [0, 100], 延迟时长需可解析
+func validateFault(fault *Fault) error {
+	if fault == nil {
+		return nil
+	}
+	if abort := fault.Abort; abort != nil && !validPercentage(abort.Percentage) {
+		return fmt.Errorf("invalid fault abort percentage: %v", *abort.Percentage)
+	}
+	if fault.Delay == nil {
+		return nil
+	}
+	if !validPercentage(fault.Delay.Percentage) {
+		return fmt.Errorf("invalid fault delay percentage: %v", *fault.Delay.Percentage)
+	}
+	if _, err := time.ParseDuration(fault.Delay.Duration); err != nil {
+		return fmt.Errorf("invalid fault delay duration: %w", err)
+	}
+	if fault.Delay.MaxDuration != "" {
+		if _, err := time.ParseDuration(fault.Delay.MaxDuration); err != nil {
+			return fmt.Errorf("invalid fault delay max duration: %w", err)
+		}
+	}
+	return nil
+}
+
+func validPercentage(percentage *float64) bool {
+	return percentage == nil || (*percentage >= 0 && *percentage <= 100)
+}
+
+// validateRateLimit 限流窗口需可解析且大于 0, 避免无效规则在请求时按存储故障处理
+func validateRateLimit(rl *RateLimit) error {
+	if rl == nil {
+		return nil
+	}
+	window, err := time.ParseDuration(rl.Window)
+	if err != nil {
+		return fmt.Errorf("invalid rate limit window: %w", err)
+	}
+	if window <= 0 {
+		return fmt.Errorf("invalid rate limit window: %s", rl.Window)
+	}
+	return nil
+}
+
+// validateSplit 分组名称唯一, 指定分组的规则需引用已存在的分组
+func validateSplit(split *TrafficSplit) error {
+	if split == nil {
+		return nil
+	}
+	names := make(map[string]bool, len(split.Groups))
+	for _, group := range split.Groups {
+		if names[group.Name] {
+			return fmt.Errorf("duplicate split group: %s", group.Name)
+		}
+		names[group.Name] = true
+	}
+	for _, override := range split.Overrides {
+		if override.Value != "" && !names[override.Group] {
+			return fmt.Errorf("split override group not found: %s", override.Group)
+		}
+		for _, name := range override.Groups {
+			if !names[name] {
+				return fmt.Errorf("split override group not found: %s", name)
+			}
+		}
+	}
+	return nil
+}
+
+func compileCORS(policy *CORSPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	return policy.Compile()
+}
+
+func compileIPACL(acl *IPACL) error {
+	if acl == nil {
+		return nil
+	}
+	return acl.Compile()
+}
+
+func compileMirror(mirror *Mirror) error {
+	if mirror == nil {
+		return nil
+	}
+	return mirror.Compile()
+}
+
+// compileAction 解析网关直接响应的模板, 响应内容文件相对于代理配置目录
+func (rc *RouteConfig) compileAction(route Route) error {
+	if route.DirectResponse != nil {
+		if err := route.DirectResponse.Compile(rc.Dir); err != nil {
+			return err
+		}
+	}
+	if route.Redirect != nil {
+		if err := route.Redirect.Compile(); err != nil {
+			return err
+		}
+	}
+	if route.Mock != nil {
+		return route.Mock.Compile()
+	}
+	return nil
+}
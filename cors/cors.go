@@ -0,0 +1,157 @@
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/config"
+)
+
+const (
+	HeaderOrigin              = "Origin"
+	HeaderVary                = "Vary"
+	HeaderRequestMethod       = "Access-Control-Request-Method"
+	HeaderRequestHeaders      = "Access-Control-Request-Headers"
+	HeaderAllowOrigin         = "Access-Control-Allow-Origin"
+	HeaderAllowMethods        = "Access-Control-Allow-Methods"
+	HeaderAllowHeaders        = "Access-Control-Allow-Headers"
+	HeaderAllowCredentials    = "Access-Control-Allow-Credentials"
+	HeaderExposeHeaders       = "Access-Control-Expose-Headers"
+	HeaderMaxAge              = "Access-Control-Max-Age"
+	HeaderAllowPrivateNetwork = "Access-Control-Allow-Private-Network"
+)
+
+var defaultAllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+
+// DefaultPolicy 网关自身公开接口使用的跨域策略, 允许任意来源但不允许携带凭证
+var DefaultPolicy = &config.CORSPolicy{AllowOrigins: []string{"*"}}
+
+// ResponseHeaders 网关负责生成的跨域响应头, 配置了跨域策略时上游返回的同名响应头会被移除
+var ResponseHeaders = []string{
+	HeaderAllowOrigin,
+	HeaderAllowMethods,
+	HeaderAllowHeaders,
+	HeaderAllowCredentials,
+	HeaderExposeHeaders,
+	HeaderMaxAge,
+	HeaderAllowPrivateNetwork,
+}
+
+// IsPreflight 判断是否为浏览器发送的预检请求
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get(HeaderOrigin) != "" && r.Header.Get(HeaderRequestMethod) != ""
+}
+
+// originAllowed 判断来源是否在允许列表中, 正则与通配符由加载器在加载配置时编译
+func originAllowed(policy *config.CORSPolicy, origin string) bool {
+	patterns, err := policy.OriginPatterns()
+	if err != nil {
+		logging.Errorf("compile cors origin error: %v", err)
+		return false
+	}
+	for i, pattern := range policy.AllowOrigins {
+		switch {
+		case pattern == "*":
+			return true
+		case patterns[i] != nil:
+			if patterns[i].MatchString(origin) {
+				return true
+			}
+		case strings.EqualFold(pattern, origin):
+			return true
+		}
+	}
+	return false
+}
+
+func allowMethods(policy *config.CORSPolicy) []string {
+	if len(policy.AllowMethods) == 0 {
+		return defaultAllowMethods
+	}
+	return policy.AllowMethods
+}
+
+func methodAllowed(policy *config.CORSPolicy, method string) bool {
+	return slices.ContainsFunc(allowMethods(policy), func(allowed string) bool {
+		return allowed == "*" || strings.EqualFold(allowed, method)
+	})
+}
+
+func headersAllowed(policy *config.CORSPolicy, requested string) bool {
+	if len(policy.AllowHeaders) == 0 || slices.Contains(policy.AllowHeaders, "*") {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(policy.AllowHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, header)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// setOrigin 写入允许的来源, 携带凭证时不能使用 *
+func setOrigin(header http.Header, policy *config.CORSPolicy, origin string) {
+	if slices.Contains(policy.AllowOrigins, "*") && !policy.AllowCredentials {
+		header.Set(HeaderAllowOrigin, "*")
+	} else {
+		header.Set(HeaderAllowOrigin, origin)
+	}
+	if policy.AllowCredentials {
+		header.Set(HeaderAllowCredentials, "true")
+	}
+}
+
+// Preflight 响应预检请求, 不转发上游也不进行身份验证
+// 来源、方法或请求头不被允许时返回 204 但不携带跨域响应头, 由浏览器拒绝实际请求
+func Preflight(w http.ResponseWriter, r *http.Request, policy *config.CORSPolicy) {
+	header := w.Header()
+	header.Add(HeaderVary, HeaderOrigin)
+	header.Add(HeaderVary, HeaderRequestMethod)
+	header.Add(HeaderVary, HeaderRequestHeaders)
+
+	origin := r.Header.Get(HeaderOrigin)
+	requestHeaders := r.Header.Get(HeaderRequestHeaders)
+	if !originAllowed(policy, origin) || !methodAllowed(policy, r.Header.Get(HeaderRequestMethod)) || !headersAllowed(policy, requestHeaders) {
+		logging.Debugc(r.Context(), "cors preflight rejected: origin=%s", origin)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	setOrigin(header, policy, origin)
+	header.Set(HeaderAllowMethods, strings.Join(allowMethods(policy), ", "))
+	if len(policy.AllowHeaders) > 0 && !slices.Contains(policy.AllowHeaders, "*") {
+		header.Set(HeaderAllowHeaders, strings.Join(policy.AllowHeaders, ", "))
+	} else if requestHeaders != "" {
+		header.Set(HeaderAllowHeaders, requestHeaders)
+	}
+	if policy.MaxAge > 0 {
+		header.Set(HeaderMaxAge, strconv.Itoa(policy.MaxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetResponseHeaders 为实际请求写入跨域响应头
+func SetResponseHeaders(w http.ResponseWriter, r *http.Request, policy *config.CORSPolicy) {
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return
+	}
+	w.Header().Add(HeaderVary, HeaderOrigin)
+	if !originAllowed(policy, origin) {
+		return
+	}
+
+	setOrigin(w.Header(), policy, origin)
+	if len(policy.ExposeHeaders) > 0 {
+		w.Header().Set(HeaderExposeHeaders, strings.Join(policy.ExposeHeaders, ", "))
+	}
+}
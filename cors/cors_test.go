@@ -0,0 +1,77 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	policy := &config.CORSPolicy{AllowOrigins: []string{
+		"https://app.example.com",
+		"https://*.example.org",
+		`~^https://[a-z]+\.example\.net$`,
+	}}
+
+	for origin, expected := range map[string]bool{
+		"https://app.example.com":  true,
+		"https://a.example.org":    true,
+		"https://example.org":      false,
+		"https://a.b.example.org":  false,
+		"https://shop.example.net": true,
+		"https://evil.com":         false,
+	} {
+		assert.Equal(t, expected, originAllowed(policy, origin))
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	policy := &config.CORSPolicy{
+		AllowOrigins:     []string{"https://app.example.com"},
+		AllowMethods:     []string{"GET", "POST"},
+		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+
+	newPreflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "/", nil)
+		r.Header.Set(HeaderOrigin, origin)
+		r.Header.Set(HeaderRequestMethod, method)
+		r.Header.Set(HeaderRequestHeaders, headers)
+		if !IsPreflight(r) {
+			t.Fatalf("expected preflight request")
+		}
+		w := httptest.NewRecorder()
+		Preflight(w, r, policy)
+		return w
+	}
+
+	w := newPreflight("https://app.example.com", "POST", "authorization")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get(HeaderAllowOrigin))
+	assert.Equal(t, "true", w.Header().Get(HeaderAllowCredentials))
+	assert.Equal(t, "GET, POST", w.Header().Get(HeaderAllowMethods))
+	assert.Equal(t, "600", w.Header().Get(HeaderMaxAge))
+
+	// 方法或请求头不被允许时不返回跨域响应头
+	w = newPreflight("https://app.example.com", "DELETE", "")
+	assert.Equal(t, "", w.Header().Get(HeaderAllowOrigin))
+	w = newPreflight("https://app.example.com", "GET", "X-Custom")
+	assert.Equal(t, "", w.Header().Get(HeaderAllowOrigin))
+}
+
+func TestSetResponseHeaders_WildcardWithoutCredentials(t *testing.T) {
+	policy := &config.CORSPolicy{AllowOrigins: []string{"*"}, ExposeHeaders: []string{"X-Request-Id"}}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderOrigin, "https://any.example.com")
+	w := httptest.NewRecorder()
+	SetResponseHeaders(w, r, policy)
+
+	assert.Equal(t, "*", w.Header().Get(HeaderAllowOrigin))
+	assert.Equal(t, "X-Request-Id", w.Header().Get(HeaderExposeHeaders))
+}
@@ -0,0 +1,109 @@
+package fault
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/metrics"
+	"github.com/superwhys/litegate/requestid"
+	"github.com/superwhys/litegate/utils"
+)
+
+const (
+	TypeDelay = "delay"
+	TypeAbort = "abort"
+)
+
+// Inject 按路由配置注入故障, 先延迟再判断是否中止
+// 请求被中止时写入响应并返回 false, 延迟期间客户端断开时同样返回 false
+func Inject(w http.ResponseWriter, r *http.Request, upstream *config.Upstream) bool {
+	cfg := upstream.Fault
+	if cfg == nil || !matches(r, cfg.When) {
+		return true
+	}
+
+	if delay := cfg.Delay; delay != nil && hit(delay.Percentage) {
+		duration, err := delayDuration(delay)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err.Error())
+			return false
+		}
+
+		metrics.FaultInjections.Inc(upstream.Service, upstream.Route, TypeDelay)
+		timer := time.NewTimer(duration)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return false
+		}
+	}
+
+	if abort := cfg.Abort; abort != nil && hit(abort.Percentage) {
+		metrics.FaultInjections.Inc(upstream.Service, upstream.Route, TypeAbort)
+		if abort.Body == "" {
+			writeError(w, r, abort.Status, "fault injected")
+			return false
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(abort.Status)
+		w.Write([]byte(abort.Body))
+		return false
+	}
+	return true
+}
+
+// matches 判断请求是否满足注入条件, 未配置条件时始终满足
+func matches(r *http.Request, cond *config.FaultCondition) bool {
+	if cond == nil {
+		return true
+	}
+
+	var value string
+	place, name := utils.ParsePlace(cond.Source)
+	switch place {
+	case auth.PlaceHeader:
+		value = r.Header.Get(name)
+	case auth.PlaceClaim:
+		value, _ = auth.ClaimsFromContext(r.Context()).String(name)
+	}
+	if value == "" {
+		return false
+	}
+	return cond.Value == "" || cond.Value == value
+}
+
+// hit 按百分比判断是否注入, 未配置时全部注入, 0 表示不注入
+func hit(percentage *float64) bool {
+	if percentage == nil || *percentage >= 100 {
+		return true
+	}
+	return *percentage > 0 && rand.Float64()*100 < *percentage
+}
+
+func delayDuration(delay *config.FaultDelay) (time.Duration, error) {
+	duration, err := time.ParseDuration(delay.Duration)
+	if err != nil || delay.MaxDuration == "" {
+		return duration, err
+	}
+
+	maxDuration, err := time.ParseDuration(delay.MaxDuration)
+	if err != nil {
+		return 0, err
+	}
+	if maxDuration <= duration {
+		return duration, nil
+	}
+	return duration + rand.N(maxDuration-duration), nil
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	b, _ := json.Marshal(requestid.ErrorRet(r.Context(), status, msg))
+	w.Write(b)
+}
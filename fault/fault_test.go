@@ -0,0 +1,101 @@
+package fault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestInject_Abort(t *testing.T) {
+	upstream := &config.Upstream{
+		Service: "test",
+		Route:   "/api",
+		Fault: &config.Fault{
+			Abort: &config.FaultAbort{Status: http.StatusServiceUnavailable, Body: `{"error":"chaos"}`},
+			When:  &config.FaultCondition{Source: "$header.X-Chaos", Value: "on"},
+		},
+	}
+
+	// 不满足条件时不注入
+	req := httptest.NewRequest(http.MethodGet, "/__test/api", nil)
+	rr := httptest.NewRecorder()
+	assert.Equal(t, true, Inject(rr, req, upstream))
+
+	req.Header.Set("X-Chaos", "off")
+	assert.Equal(t, true, Inject(rr, req, upstream))
+
+	req.Header.Set("X-Chaos", "on")
+	assert.Equal(t, false, Inject(rr, req, upstream))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, `{"error":"chaos"}`, rr.Body.String())
+}
+
+func TestInject_ClaimCondition(t *testing.T) {
+	upstream := &config.Upstream{
+		Fault: &config.Fault{
+			Abort: &config.FaultAbort{Status: http.StatusInternalServerError},
+			When:  &config.FaultCondition{Source: "$claim.tester"},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/__test/api", nil)
+	assert.Equal(t, true, Inject(httptest.NewRecorder(), req, upstream))
+
+	req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"tester": true}))
+	rr := httptest.NewRecorder()
+	assert.Equal(t, false, Inject(rr, req, upstream))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestInject_Percentage(t *testing.T) {
+	abortCount := func(percentage *float64) int {
+		upstream := &config.Upstream{
+			Fault: &config.Fault{
+				Abort: &config.FaultAbort{Percentage: percentage, Status: http.StatusBadGateway},
+			},
+		}
+		aborted := 0
+		for i := 0; i < 5000; i++ {
+			if !Inject(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/__test/api", nil), upstream) {
+				aborted++
+			}
+		}
+		return aborted
+	}
+
+	twenty, zero, hundred := 20.0, 0.0, 100.0
+	if aborted := abortCount(&twenty); aborted < 800 || aborted > 1200 {
+		t.Fatalf("unexpected abort count: %d", aborted)
+	}
+	// 0 表示不注入, 未配置与 100 对全部请求注入
+	assert.Equal(t, 0, abortCount(&zero))
+	assert.Equal(t, 5000, abortCount(nil))
+	assert.Equal(t, 5000, abortCount(&hundred))
+}
+
+func TestInject_Delay(t *testing.T) {
+	upstream := &config.Upstream{
+		Fault: &config.Fault{
+			Delay: &config.FaultDelay{Duration: "20ms", MaxDuration: "40ms"},
+		},
+	}
+
+	start := time.Now()
+	assert.Equal(t, true, Inject(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/__test/api", nil), upstream))
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("expected delay of at least 20ms, got %v", elapsed)
+	}
+
+	// 延迟期间客户端断开
+	upstream.Fault.Delay = &config.FaultDelay{Duration: "1h"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/__test/api", nil).WithContext(ctx)
+	assert.Equal(t, false, Inject(httptest.NewRecorder(), req, upstream))
+}
@@ -9,12 +9,22 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/miebyte/goutils/cores"
 	"github.com/miebyte/goutils/flags"
 	"github.com/miebyte/goutils/logging"
 	"github.com/superwhys/litegate/api"
+	"github.com/superwhys/litegate/clientip"
 	"github.com/superwhys/litegate/config"
 	"github.com/superwhys/litegate/config/loader"
+	"github.com/superwhys/litegate/recorder"
 )
 
 var (
@@ -23,6 +33,11 @@ var (
 )
 
 func main() {
+	// litegate replay -file records/test.jsonl -target http://127.0.0.1:8080
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(recorder.RunReplay(os.Args[2:], os.Stdout))
+	}
+
 	flags.Parse()
 
 	gatewayConfig := new(config.GatewayConfig)
@@ -32,7 +47,32 @@ func main() {
 	proxyConfigLoader := loader.NewLocalConfigLoader("./content/proxy")
 	logging.PanicError(proxyConfigLoader.Watch())
 
-	gatewayApp := api.SetupGatewayApp(gatewayConfig, proxyConfigLoader)
+	gatewayApp, err := api.SetupGatewayApp(gatewayConfig, proxyConfigLoader)
+	logging.PanicError(err)
+	// 服务退出后释放网关资源
+	defer func() {
+		if err := gatewayApp.Close(); err != nil {
+			logging.Errorf("close gateway error: %v", err)
+		}
+	}()
+
+	if gatewayConfig.ProxyProtocol {
+		// cores 不支持自定义监听器, 启用 PROXY protocol 时使用标准库启动服务
+		resolver, err := clientip.NewResolver(gatewayConfig)
+		logging.PanicError(err)
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port()))
+		logging.PanicError(err)
+		logging.Infof("listen with proxy protocol on :%d", port())
+		logging.PanicError(serve(clientip.NewProxyProtocolListener(listener, resolver), gatewayApp, gatewayConfig.Server))
+		return
+	}
+
+	// 任一服务配置了跨域策略时由各服务自行处理跨域, 不再启用全局跨域中间件
+	if serviceCORSEnabled(proxyConfigLoader) {
+		srv := cores.NewCores(cores.WithHttpHandler("/", gatewayApp))
+		logging.PanicError(cores.Start(srv, port()))
+		return
+	}
 
 	srv := cores.NewCores(
 		cores.WithHttpCORS(),
@@ -40,3 +80,47 @@ func main() {
 	)
 	logging.PanicError(cores.Start(srv, port()))
 }
+
+func serviceCORSEnabled(configLoader config.ProxyConfigLoader) bool {
+	routes, err := configLoader.GetAll()
+	if err != nil {
+		logging.Errorf("load proxy configs error: %v", err)
+		return false
+	}
+	for _, route := range routes {
+		if route.HasCORSPolicy() {
+			return true
+		}
+	}
+	return false
+}
+
+// serve 使用标准库在指定监听器上启动服务, 收到退出信号后等待在途请求结束
+func serve(listener net.Listener, handler http.Handler, serverConf *config.ServerConfig) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: serverConf.ReadHeaderTimeout,
+		ReadTimeout:       serverConf.ReadTimeout,
+		WriteTimeout:      serverConf.WriteTimeout,
+		IdleTimeout:       serverConf.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	logging.Infof("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverConf.ShutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
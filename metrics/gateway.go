@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	sizeBuckets    = []float64{100, 1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20}
+
+	requestLabels = []string{"service", "route", "upstream", "method", "status_class"}
+
+	RequestsTotal = NewCounterVec(
+		"litegate_requests_total",
+		"Total number of proxied requests.",
+		requestLabels...,
+	)
+	RequestDuration = NewHistogramVec(
+		"litegate_request_duration_seconds",
+		"Request latency observed by the gateway in seconds.",
+		latencyBuckets,
+		requestLabels...,
+	)
+	ResponseSize = NewHistogramVec(
+		"litegate_response_size_bytes",
+		"Response body size in bytes.",
+		sizeBuckets,
+		requestLabels...,
+	)
+	InflightRequests = NewGaugeVec(
+		"litegate_inflight_requests",
+		"Number of requests currently being handled.",
+		"service",
+	)
+
+	UpstreamOpenConnections = NewGaugeVec(
+		"litegate_upstream_open_connections",
+		"Number of open connections to the upstream in the shared transport pool.",
+		"upstream",
+	)
+	UpstreamConnectionsAcquired = NewCounterVec(
+		"litegate_upstream_connections_acquired_total",
+		"Number of connections acquired from the shared transport pool, by whether the connection was reused.",
+		"upstream", "reused",
+	)
+
+	ConfigReloads = NewCounterVec(
+		"litegate_config_reloads_total",
+		"Number of proxy config reloads by result.",
+		"result",
+	)
+	MirrorRequests = NewCounterVec(
+		"litegate_mirror_requests_total",
+		"Number of mirrored requests by outcome: the response status class, error, timeout, dropped or body_too_large.",
+		"service", "route", "result",
+	)
+	MirrorDuration = NewHistogramVec(
+		"litegate_mirror_request_duration_seconds",
+		"Latency of mirrored requests in seconds.",
+		latencyBuckets,
+		"service", "route",
+	)
+	SplitRequests = NewCounterVec(
+		"litegate_split_requests_total",
+		"Number of requests assigned to each upstream group by traffic splitting.",
+		"service", "route", "group",
+	)
+	FaultInjections = NewCounterVec(
+		"litegate_fault_injections_total",
+		"Number of injected faults by type: delay or abort.",
+		"service", "route", "type",
+	)
+
+	AuthRejections = NewCounterVec(
+		"litegate_auth_rejections_total",
+		"Number of requests rejected by authentication or authorization, by reason.",
+		"service", "reason",
+	)
+)
+
+// StatusClass 返回状态码分类, 如 2xx
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// UpstreamAddr 返回上游地址的 host:port 形式, 与连接池拨号地址一致
+func UpstreamAddr(upstreamURL string) string {
+	target, err := url.Parse(upstreamURL)
+	if err != nil || target.Host == "" {
+		return upstreamURL
+	}
+	if target.Port() != "" {
+		return target.Host
+	}
+	port := "80"
+	if target.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(target.Hostname(), port)
+}
+
+// MethodLabel 返回请求方法的标签值, 非标准方法统一为 OTHER, 避免客户端构造任意方法导致标签数量无限增长
+func MethodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// ObserveRequest 记录一次请求的数量、耗时与响应大小
+func ObserveRequest(service, route, upstream, method string, status, size int, duration time.Duration) {
+	labels := []string{service, route, upstream, MethodLabel(method), StatusClass(status)}
+	RequestsTotal.Inc(labels...)
+	RequestDuration.Observe(duration.Seconds(), labels...)
+	ResponseSize.Observe(float64(max(size, 0)), labels...)
+}
+
+// ConfigReloaded 记录一次配置加载结果
+func ConfigReloaded(err error) {
+	if err != nil {
+		ConfigReloads.Inc("failure")
+		return
+	}
+	ConfigReloads.Inc("success")
+}
@@ -0,0 +1,274 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// collector 以 Prometheus 文本格式输出的指标
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry 指标注册表
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+var defaultRegistry = &Registry{}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText 按 Prometheus 文本格式(0.0.4)输出全部指标
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// Handler 返回输出默认注册表指标的 HTTP 处理器
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		defaultRegistry.WriteText(w)
+	})
+}
+
+type metricVec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (m *metricVec) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+}
+
+// labelKey 将标签值拼接为 map 的键
+func (m *metricVec) labelKey(values []string) string {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels 输出 {a="x",b="y"} 形式的标签, extra 为额外追加的标签(如 le)
+func (m *metricVec) formatLabels(key string, extra ...string) string {
+	var pairs []string
+	if len(m.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, m.labels[i]+`="`+escapeLabel(value)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec 只增不减的计数器
+type CounterVec struct {
+	metricVec
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		metricVec: metricVec{name: name, help: help, typ: "counter", labels: labels},
+		values:    make(map[string]float64),
+	}
+	defaultRegistry.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) Add(delta float64, values ...string) {
+	key := c.labelKey(values)
+	c.mu.Lock()
+	c.values[key] += delta
+	c.mu.Unlock()
+}
+
+// Value 返回指定标签的当前值
+func (c *CounterVec) Value(values ...string) float64 {
+	key := c.labelKey(values)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[key]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.formatLabels(key), formatFloat(c.values[key]))
+	}
+}
+
+// GaugeVec 可增可减的仪表盘
+type GaugeVec struct {
+	metricVec
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{
+		metricVec: metricVec{name: name, help: help, typ: "gauge", labels: labels},
+		values:    make(map[string]float64),
+	}
+	defaultRegistry.register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(value float64, values ...string) {
+	key := g.labelKey(values)
+	g.mu.Lock()
+	g.values[key] = value
+	g.mu.Unlock()
+}
+
+func (g *GaugeVec) Add(delta float64, values ...string) {
+	key := g.labelKey(values)
+	g.mu.Lock()
+	g.values[key] += delta
+	g.mu.Unlock()
+}
+
+func (g *GaugeVec) Inc(values ...string) {
+	g.Add(1, values...)
+}
+
+func (g *GaugeVec) Dec(values ...string) {
+	g.Add(-1, values...)
+}
+
+// Value 返回指定标签的当前值
+func (g *GaugeVec) Value(values ...string) float64 {
+	key := g.labelKey(values)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.values[key]
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.writeHeader(w)
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.formatLabels(key), formatFloat(g.values[key]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec 按桶统计分布的直方图
+type HistogramVec struct {
+	metricVec
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		metricVec: metricVec{name: name, help: help, typ: "histogram", labels: labels},
+		buckets:   buckets,
+		values:    make(map[string]*histogram),
+	}
+	defaultRegistry.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	key := h.labelKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.values[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.sum += value
+	hist.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.values) {
+		hist := h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(key, "le", formatFloat(bound)), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(key, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.formatLabels(key), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.formatLabels(key), hist.count)
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	registry := &Registry{}
+	counter := &CounterVec{
+		metricVec: metricVec{name: "test_total", help: "Test counter.", typ: "counter", labels: []string{"path"}},
+		values:    make(map[string]float64),
+	}
+	histogram := &HistogramVec{
+		metricVec: metricVec{name: "test_seconds", help: "Test histogram.", typ: "histogram"},
+		buckets:   []float64{0.1, 1},
+		values:    make(map[string]*histogram),
+	}
+	registry.register(counter)
+	registry.register(histogram)
+
+	counter.Inc(`/a"b`)
+	counter.Add(2, `/a"b`)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	var buf bytes.Buffer
+	registry.WriteText(&buf)
+	expected := strings.Join([]string{
+		"# HELP test_total Test counter.",
+		"# TYPE test_total counter",
+		`test_total{path="/a\"b"} 3`,
+		"# HELP test_seconds Test histogram.",
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{le="0.1"} 1`,
+		`test_seconds_bucket{le="1"} 2`,
+		`test_seconds_bucket{le="+Inf"} 3`,
+		"test_seconds_sum 5.55",
+		"test_seconds_count 3",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestObserveRequest(t *testing.T) {
+	ObserveRequest("svc", "/users/{id}", "127.0.0.1:80", "GET", 404, 12, 30*time.Millisecond)
+	assert.Equal(t, 1.0, RequestsTotal.Value("svc", "/users/{id}", "127.0.0.1:80", "GET", "4xx"))
+
+	// 非标准方法统一计入 OTHER
+	ObserveRequest("svc", "/users/{id}", "127.0.0.1:80", "PROPFIND", 404, 12, 30*time.Millisecond)
+	ObserveRequest("svc", "/users/{id}", "127.0.0.1:80", "get", 404, 12, 30*time.Millisecond)
+	assert.Equal(t, 2.0, RequestsTotal.Value("svc", "/users/{id}", "127.0.0.1:80", "OTHER", "4xx"))
+
+	assert.Equal(t, "example.com:443", UpstreamAddr("https://example.com/api"))
+	assert.Equal(t, "example.com:8080", UpstreamAddr("http://example.com:8080"))
+}
@@ -0,0 +1,267 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/metrics"
+)
+
+const (
+	defaultTimeout        = time.Second
+	defaultMaxConcurrency = 16
+	defaultMaxBodyBytes   = 1 << 20
+
+	// HeaderShadow 标记镜像请求, 便于镜像上游区分真实流量
+	HeaderShadow = "X-Shadow-Request"
+
+	ResultError        = "error"
+	ResultTimeout      = "timeout"
+	ResultDropped      = "dropped"
+	ResultBodyTooLarge = "body_too_large"
+)
+
+// Manager 发送镜像请求, 使用独立的连接池, 每个路由的镜像请求独立限制并发
+type Manager struct {
+	client   *http.Client
+	inflight sync.Map // service|route -> *atomic.Int64
+	wg       sync.WaitGroup
+}
+
+func NewManager() *Manager {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = defaultMaxConcurrency
+	return &Manager{client: &http.Client{Transport: transport}}
+}
+
+// Mirror 按百分比复制请求并异步发送到镜像上游
+// 请求体在转发主请求的同时被复制, 主请求读取完请求体后才发出镜像请求, 超出上限时不镜像
+func (m *Manager) Mirror(req *http.Request, upstream *config.Upstream) {
+	cfg := upstream.Mirror
+	if m == nil || cfg == nil || cfg.Upstream == "" || !sampled(cfg.Percentage) {
+		return
+	}
+
+	service, route := upstream.Service, upstream.Route
+	target, timeout, err := cfg.Endpoint()
+	if err != nil {
+		logging.Errorc(req.Context(), "create mirror request error: %v", err)
+		metrics.MirrorRequests.Inc(service, route, ResultError)
+		return
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	shadow := newShadowRequest(req, upstream, target)
+	dispatch := func(body []byte) {
+		m.dispatch(shadow, body, upstream, timeout)
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		dispatch(nil)
+		return
+	}
+
+	limit := cfg.MaxBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	if req.ContentLength > limit {
+		metrics.MirrorRequests.Inc(service, route, ResultBodyTooLarge)
+		return
+	}
+	req.Body = &teeBody{
+		ReadCloser:    req.Body,
+		contentLength: req.ContentLength,
+		limit:         limit,
+		done: func(body []byte, result string) {
+			if result != "" {
+				metrics.MirrorRequests.Inc(service, route, result)
+				return
+			}
+			dispatch(body)
+		},
+	}
+}
+
+// dispatch 在并发上限内异步发送镜像请求, 超时时间从发送时开始计算
+func (m *Manager) dispatch(shadow *http.Request, body []byte, upstream *config.Upstream, timeout time.Duration) {
+	service, route := upstream.Service, upstream.Route
+	counter := m.counter(service + "|" + route)
+	maxConcurrency := upstream.Mirror.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+	if counter.Add(1) > int64(maxConcurrency) {
+		counter.Add(-1)
+		metrics.MirrorRequests.Inc(service, route, ResultDropped)
+		return
+	}
+
+	shadow.ContentLength = int64(len(body))
+	shadow.Body = http.NoBody
+	if len(body) > 0 {
+		shadow.Body = io.NopCloser(bytes.NewReader(body))
+		shadow.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer counter.Add(-1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		start := time.Now()
+		result := m.send(shadow.WithContext(ctx))
+		metrics.MirrorRequests.Inc(service, route, result)
+		metrics.MirrorDuration.Observe(time.Since(start).Seconds(), service, route)
+	}()
+}
+
+// sampled 未配置百分比时镜像全部请求, 配置为 0 时不镜像
+func sampled(percentage *float64) bool {
+	if percentage == nil || *percentage >= 100 {
+		return true
+	}
+	return *percentage > 0 && rand.Float64()*100 < *percentage
+}
+
+// Wait 等待进行中的镜像请求结束
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Close 退出时等待进行中的镜像请求结束, 镜像请求均有超时时间, 不会无限等待
+func (m *Manager) Close() error {
+	m.Wait()
+	return nil
+}
+
+func (m *Manager) counter(key string) *atomic.Int64 {
+	counter, _ := m.inflight.LoadOrStore(key, new(atomic.Int64))
+	return counter.(*atomic.Int64)
+}
+
+func (m *Manager) send(req *http.Request) string {
+	resp, err := m.client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+			return ResultTimeout
+		}
+		return ResultError
+	}
+	defer resp.Body.Close()
+
+	// 读完响应体以便复用连接, 内容被忽略
+	io.Copy(io.Discard, io.LimitReader(resp.Body, defaultMaxBodyBytes))
+	return metrics.StatusClass(resp.StatusCode)
+}
+
+// teeBody 在主请求读取请求体的同时复制内容, 读取完毕或关闭时回调一次
+// 回调的 result 为空表示请求体完整, 否则为镜像结果
+type teeBody struct {
+	io.ReadCloser
+	contentLength int64
+	limit         int64
+	done          func(body []byte, result string)
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	overflow bool
+	finished bool
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.finished && !b.overflow {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.finish("")
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	err := b.ReadCloser.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// 已读取 Content-Length 声明的全部内容时视为完整, 否则主请求未发送完整的请求体
+	if b.contentLength >= 0 && int64(b.buf.Len()) == b.contentLength {
+		b.finish("")
+	} else {
+		b.finish(ResultError)
+	}
+	return err
+}
+
+func (b *teeBody) finish(result string) {
+	if b.finished {
+		return
+	}
+	b.finished = true
+	if b.overflow {
+		result = ResultBodyTooLarge
+	}
+	b.done(b.buf.Bytes(), result)
+}
+
+// newShadowRequest 复制请求发往镜像上游, 请求体在主请求读取完毕后设置
+// 不继承原请求的上下文, 主请求结束后镜像请求仍可完成, 也不会触发主请求的连接追踪
+func newShadowRequest(req *http.Request, upstream *config.Upstream, target *url.URL) *http.Request {
+	shadow := req.Clone(context.Background())
+	shadow.RequestURI = ""
+	shadow.URL.Scheme = target.Scheme
+	shadow.URL.Host = target.Host
+	shadow.Host = target.Host
+	shadow.Header.Set(HeaderShadow, "1")
+	if !upstream.Mirror.ForwardCredentials {
+		auth.StripClaimsFromRequest(shadow, credentialPlaces(upstream.Auth))
+	}
+	return shadow
+}
+
+// credentialPlaces 镜像请求默认移除的位置: 常见的凭证请求头、客户端令牌以及网关签发的内部令牌
+func credentialPlaces(authConf *config.Auth) []string {
+	places := []string{
+		auth.PlaceHeader + ".Authorization",
+		auth.PlaceHeader + ".Proxy-Authorization",
+		auth.PlaceHeader + ".Cookie",
+	}
+	if authConf == nil {
+		return places
+	}
+	if authConf.Source != "" {
+		places = append(places, authConf.Source)
+	}
+	if authConf.UpstreamToken != nil {
+		places = append(places, authConf.UpstreamToken.TargetPlace())
+	}
+	return places
+}
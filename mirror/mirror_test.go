@@ -0,0 +1,177 @@
+package mirror
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/metrics"
+)
+
+// counterDelta 返回执行 fn 前后镜像结果计数的差值, 指标为全局变量, 不同用例之间会累计
+func counterDelta(service, result string, fn func()) float64 {
+	before := metrics.MirrorRequests.Value(service, "/api", result)
+	fn()
+	return metrics.MirrorRequests.Value(service, "/api", result) - before
+}
+
+func TestManager_Mirror(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	upstream := &config.Upstream{
+		Service: "mirror-test",
+		Route:   "/api",
+		Mirror:  &config.Mirror{Upstream: shadow.URL},
+	}
+	req := httptest.NewRequest(http.MethodPost, "http://primary/api/users?id=1", strings.NewReader(`{"name":"litegate"}`))
+	req.Header.Set("X-Custom", "value")
+
+	req.Header.Set("Authorization", "Bearer client-token")
+	req.Header.Set("Cookie", "session=1")
+
+	m := NewManager()
+	delta := counterDelta("mirror-test", "5xx", func() {
+		m.Mirror(req, upstream)
+
+		// 原请求的请求体仍可完整读取, 读取完毕后才发出镜像请求
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatalf("read primary body error: %v", err)
+		}
+		assert.Equal(t, `{"name":"litegate"}`, string(body))
+		req.Body.Close()
+		m.Wait()
+	})
+
+	r := <-received
+	assert.Equal(t, "/api/users", r.URL.Path)
+	assert.Equal(t, "id=1", r.URL.RawQuery)
+	assert.Equal(t, "value", r.Header.Get("X-Custom"))
+	assert.Equal(t, "1", r.Header.Get(HeaderShadow))
+	// 默认移除客户端凭证
+	assert.Equal(t, "", r.Header.Get("Authorization"))
+	assert.Equal(t, "", r.Header.Get("Cookie"))
+	assert.Equal(t, `{"name":"litegate"}`, <-bodies)
+	assert.Equal(t, float64(1), delta)
+}
+
+func TestManager_MirrorLimits(t *testing.T) {
+	release := make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer shadow.Close()
+
+	upstream := &config.Upstream{
+		Service: "mirror-limit",
+		Route:   "/api",
+		Mirror: &config.Mirror{
+			Upstream:       shadow.URL,
+			Timeout:        "5s",
+			MaxConcurrency: 1,
+			MaxBodyBytes:   4,
+		},
+	}
+
+	m := NewManager()
+	succeeded := counterDelta("mirror-limit", "2xx", func() {
+		m.Mirror(httptest.NewRequest(http.MethodGet, "http://primary/api", nil), upstream)
+		dropped := counterDelta("mirror-limit", ResultDropped, func() {
+			m.Mirror(httptest.NewRequest(http.MethodGet, "http://primary/api", nil), upstream)
+		})
+		assert.Equal(t, float64(1), dropped)
+
+		req := httptest.NewRequest(http.MethodPost, "http://primary/api", strings.NewReader("too large"))
+		tooLarge := counterDelta("mirror-limit", ResultBodyTooLarge, func() {
+			m.Mirror(req, upstream)
+		})
+		assert.Equal(t, float64(1), tooLarge)
+
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, "too large", string(body))
+
+		close(release)
+		m.Wait()
+	})
+	assert.Equal(t, float64(1), succeeded)
+}
+
+func TestManager_MirrorTimeout(t *testing.T) {
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer shadow.Close()
+
+	upstream := &config.Upstream{
+		Service: "mirror-timeout",
+		Route:   "/api",
+		Mirror:  &config.Mirror{Upstream: shadow.URL, Timeout: "20ms"},
+	}
+
+	m := NewManager()
+	timeouts := counterDelta("mirror-timeout", ResultTimeout, func() {
+		start := time.Now()
+		m.Mirror(httptest.NewRequest(http.MethodGet, "http://primary/api", nil), upstream)
+		if time.Since(start) > 100*time.Millisecond {
+			t.Fatalf("mirror should not block the primary request")
+		}
+		m.Wait()
+	})
+	assert.Equal(t, float64(1), timeouts)
+}
+
+func TestManager_MirrorPercentageAndCredentials(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer shadow.Close()
+
+	// 百分比为 0 时不镜像
+	zero := 0.0
+	upstream := &config.Upstream{
+		Service: "mirror-percentage",
+		Route:   "/api",
+		Mirror:  &config.Mirror{Upstream: shadow.URL, Percentage: &zero},
+	}
+	m := NewManager()
+	m.Mirror(httptest.NewRequest(http.MethodGet, "http://primary/api", nil), upstream)
+	m.Wait()
+	select {
+	case <-received:
+		t.Fatalf("percentage 0 should disable mirroring")
+	default:
+	}
+
+	// 显式保留凭证时原样转发
+	upstream.Mirror = &config.Mirror{Upstream: shadow.URL, ForwardCredentials: true}
+	req := httptest.NewRequest(http.MethodGet, "http://primary/api", nil)
+	req.Header.Set("Authorization", "Bearer client-token")
+	m.Mirror(req, upstream)
+	m.Wait()
+	assert.Equal(t, "Bearer client-token", (<-received).Header.Get("Authorization"))
+
+	// 内部令牌注入到自定义请求头时同样默认移除
+	upstream.Mirror = &config.Mirror{Upstream: shadow.URL}
+	upstream.Auth = &config.Auth{Source: "$query.access_token", UpstreamToken: &config.UpstreamToken{Target: "$header.X-Internal-Token"}}
+	req = httptest.NewRequest(http.MethodGet, "http://primary/api?access_token=abc&id=1", nil)
+	req.Header.Set("X-Internal-Token", "minted")
+	m.Mirror(req, upstream)
+	m.Wait()
+	r := <-received
+	assert.Equal(t, "", r.Header.Get("X-Internal-Token"))
+	assert.Equal(t, "id=1", r.URL.RawQuery)
+}
@@ -0,0 +1,199 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxKeys = 100000
+	shardCount     = 32
+)
+
+// memoryStore 进程内限流计数存储, 按key分片加锁, 每个分片独立按LRU淘汰
+type memoryStore struct {
+	shards [shardCount]*lruShard
+}
+
+type lruShard struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+type entry struct {
+	key string
+	// mu 仅供 redis 存储在同步远端计数期间使用, 避免网络往返时持有分片锁
+	mu sync.Mutex
+	// token_bucket
+	tokens float64
+	last   time.Time
+	// sliding_window
+	windowStart time.Time
+	current     int
+	previous    int
+	// redis 本地预聚合: 尚未同步的计数与上次同步时间
+	pending  int
+	lastSync time.Time
+}
+
+func NewMemoryStore(maxKeys int) *memoryStore {
+	if maxKeys <= 0 {
+		maxKeys = defaultMaxKeys
+	}
+	capacity := max(maxKeys/shardCount, 1)
+
+	ms := &memoryStore{}
+	for i := range ms.shards {
+		ms.shards[i] = &lruShard{
+			capacity: capacity,
+			items:    make(map[string]*list.Element),
+			order:    list.New(),
+		}
+	}
+	return ms
+}
+
+func (ms *memoryStore) shard(key string) *lruShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return ms.shards[h.Sum32()%shardCount]
+}
+
+// entry 获取 key 对应的计数
+func (ms *memoryStore) entry(key string) *entry {
+	shard := ms.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	return shard.get(key)
+}
+
+func (ms *memoryStore) Take(ctx context.Context, key string, rule *Rule, now time.Time) (*Result, error) {
+	shard := ms.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e := shard.get(key)
+	if rule.Algorithm == AlgorithmSlidingWindow {
+		return takeSlidingWindow(e, rule, now), nil
+	}
+	return takeTokenBucket(e, rule, now), nil
+}
+
+// Refund 归还一次放行消耗的计数, 计数已进入新窗口时无需归还
+func (ms *memoryStore) Refund(ctx context.Context, key string, rule *Rule, now time.Time) error {
+	shard := ms.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	e := shard.get(key)
+	if rule.Algorithm == AlgorithmSlidingWindow {
+		if e.windowStart.Equal(now.Truncate(rule.Window)) && e.current > 0 {
+			e.current--
+		}
+		return nil
+	}
+	if e.tokens >= 0 {
+		e.tokens = math.Min(float64(rule.Burst), e.tokens+1)
+	}
+	return nil
+}
+
+// get 获取计数, 不存在时创建并在超出容量时淘汰最久未使用的计数
+func (s *lruShard) get(key string) *entry {
+	if elem, ok := s.items[key]; ok {
+		s.order.MoveToFront(elem)
+		return elem.Value.(*entry)
+	}
+
+	if s.order.Len() >= s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*entry).key)
+	}
+
+	e := &entry{key: key, tokens: -1}
+	s.items[key] = s.order.PushFront(e)
+	return e
+}
+
+func (s *lruShard) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func takeTokenBucket(e *entry, rule *Rule, now time.Time) *Result {
+	capacity := float64(rule.Burst)
+	rate := float64(rule.Limit) / rule.Window.Seconds()
+
+	if e.tokens < 0 {
+		e.tokens = capacity
+	} else {
+		e.tokens = math.Min(capacity, e.tokens+now.Sub(e.last).Seconds()*rate)
+	}
+	e.last = now
+
+	result := &Result{Limit: rule.Burst}
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - e.tokens) / rate)
+	}
+	result.Remaining = int(e.tokens)
+	result.Reset = secondsToDuration((capacity - e.tokens) / rate)
+	return result
+}
+
+// takeSlidingWindow 滑动窗口计数, 按上一个窗口在当前滑动窗口中的占比估算请求数
+func takeSlidingWindow(e *entry, rule *Rule, now time.Time) *Result {
+	windowStart := now.Truncate(rule.Window)
+	switch {
+	case e.windowStart.Equal(windowStart):
+	case e.windowStart.Add(rule.Window).Equal(windowStart):
+		e.previous, e.current = e.current, 0
+		e.windowStart = windowStart
+	default:
+		e.previous, e.current = 0, 0
+		e.windowStart = windowStart
+	}
+
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+	count := float64(e.previous)*weight + float64(e.current)
+
+	result := &Result{
+		Limit: rule.Limit,
+		Reset: rule.Window - elapsed,
+	}
+	if count+1 <= float64(rule.Limit) {
+		e.current++
+		result.Allowed = true
+		count++
+	} else {
+		result.RetryAfter = slidingRetryAfter(e, rule, elapsed)
+	}
+	result.Remaining = rule.Limit - int(math.Ceil(count))
+	return result
+}
+
+// slidingRetryAfter 计算上一个窗口的占比衰减到可以放行一个请求所需的时间
+func slidingRetryAfter(e *entry, rule *Rule, elapsed time.Duration) time.Duration {
+	free := float64(rule.Limit - e.current - 1)
+	if free < 0 || e.previous == 0 {
+		return rule.Window - elapsed
+	}
+	// previous * (1 - t/window) <= free
+	t := time.Duration((1 - free/float64(e.previous)) * float64(rule.Window))
+	return max(t-elapsed, 0)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
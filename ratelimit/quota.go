@@ -0,0 +1,395 @@
+package ratelimit
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/config"
+)
+
+const (
+	PeriodDaily   = "daily"
+	PeriodMonthly = "monthly"
+
+	defaultMaxConsumers = 100000
+)
+
+// QuotaUsage 单个消费者在当前周期内的配额使用情况
+type QuotaUsage struct {
+	Service  string `json:"service"`
+	Scope    string `json:"scope"`
+	Consumer string `json:"consumer"`
+	// 周期标识, 如 2026-10-19 / 2026-10
+	Period  string    `json:"period"`
+	Limit   int64     `json:"limit"`
+	Used    int64     `json:"used"`
+	ResetAt time.Time `json:"reset_at"`
+}
+
+func (u *QuotaUsage) remaining() int64 {
+	return max(u.Limit-u.Used, 0)
+}
+
+// anonymous 按客户端IP计数的消费者只保存在内存中, 不写入文件
+func (u *QuotaUsage) anonymous() bool {
+	return strings.HasPrefix(u.Consumer, KeyIP+":")
+}
+
+type quotaEntry struct {
+	key   string
+	usage *QuotaUsage
+}
+
+// QuotaManager 配额计数, 保存在内存中并定期写入本地文件, 重启后从文件恢复
+// 内存中最多保存 maxConsumers 个计数, 超出后按LRU淘汰
+type QuotaManager struct {
+	file          string
+	flushInterval time.Duration
+	maxConsumers  int
+
+	mu       sync.Mutex
+	usage    map[string]*list.Element
+	lru      *list.List
+	dirty    bool
+	flushMu  sync.Mutex
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func NewQuotaManager(cfg *config.QuotaConfig) (*QuotaManager, error) {
+	if cfg == nil {
+		cfg = &config.QuotaConfig{}
+	}
+
+	q := &QuotaManager{
+		file:          cfg.File,
+		flushInterval: cfg.FlushInterval,
+		maxConsumers:  cfg.MaxConsumers,
+		usage:         make(map[string]*list.Element),
+		lru:           list.New(),
+		stopChan:      make(chan struct{}),
+	}
+	if q.flushInterval <= 0 {
+		q.flushInterval = 5 * time.Second
+	}
+	if q.maxConsumers <= 0 {
+		q.maxConsumers = defaultMaxConsumers
+	}
+	if q.file == "" {
+		return q, nil
+	}
+
+	if err := q.load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	go q.flushLoop()
+	return q, nil
+}
+
+func (q *QuotaManager) load() error {
+	data, err := os.ReadFile(q.file)
+	if err != nil {
+		return err
+	}
+	usage := make(map[string]*QuotaUsage)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &usage); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	for key, u := range usage {
+		if u.ResetAt.After(now) {
+			q.put(key, u)
+		}
+	}
+	logging.Infof("load quota usage: %d consumers", len(q.usage))
+	return nil
+}
+
+// get 返回计数并标记为最近使用
+func (q *QuotaManager) get(key string) *QuotaUsage {
+	elem, ok := q.usage[key]
+	if !ok {
+		return nil
+	}
+	q.lru.MoveToFront(elem)
+	return elem.Value.(*quotaEntry).usage
+}
+
+// put 保存计数, 超出数量上限时淘汰最久未使用的计数
+func (q *QuotaManager) put(key string, usage *QuotaUsage) {
+	if elem, ok := q.usage[key]; ok {
+		elem.Value.(*quotaEntry).usage = usage
+		q.lru.MoveToFront(elem)
+		return
+	}
+	q.usage[key] = q.lru.PushFront(&quotaEntry{key: key, usage: usage})
+	for q.lru.Len() > q.maxConsumers {
+		oldest := q.lru.Back()
+		q.remove(oldest)
+		if !oldest.Value.(*quotaEntry).usage.anonymous() {
+			q.dirty = true
+		}
+	}
+}
+
+func (q *QuotaManager) remove(elem *list.Element) {
+	q.lru.Remove(elem)
+	delete(q.usage, elem.Value.(*quotaEntry).key)
+}
+
+func (q *QuotaManager) pruneExpired(now time.Time) {
+	for _, elem := range q.usage {
+		if !elem.Value.(*quotaEntry).usage.ResetAt.After(now) {
+			q.remove(elem)
+		}
+	}
+}
+
+// snapshot 复制需要写入文件的计数, 在锁外编码, 避免阻塞请求路径上的配额检查
+func (q *QuotaManager) snapshot() map[string]QuotaUsage {
+	ret := make(map[string]QuotaUsage, len(q.usage))
+	for key, elem := range q.usage {
+		if usage := elem.Value.(*quotaEntry).usage; !usage.anonymous() {
+			ret[key] = *usage
+		}
+	}
+	return ret
+}
+
+func (q *QuotaManager) flushLoop() {
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := q.Flush(); err != nil {
+				logging.Errorf("flush quota usage error: %s, %v", q.file, err)
+			}
+		case <-q.stopChan:
+			return
+		}
+	}
+}
+
+// Flush 将有变化的配额计数写入文件, 写入失败时保留变化标记, 下次继续写入
+func (q *QuotaManager) Flush() error {
+	if q.file == "" {
+		return nil
+	}
+
+	// 定期写入与 Close 可能同时调用, 串行写入避免临时文件互相覆盖
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+
+	q.mu.Lock()
+	if !q.dirty {
+		q.mu.Unlock()
+		return nil
+	}
+	q.pruneExpired(time.Now())
+	snapshot := q.snapshot()
+	// 写入期间的新变化会重新设置变化标记
+	q.dirty = false
+	q.mu.Unlock()
+
+	data, err := json.MarshalIndent(snapshot, "", "    ")
+	if err == nil {
+		err = q.writeFile(data)
+	}
+	if err != nil {
+		q.mu.Lock()
+		q.dirty = true
+		q.mu.Unlock()
+	}
+	return err
+}
+
+func (q *QuotaManager) writeFile(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(q.file), 0755); err != nil {
+		return err
+	}
+	tmpFile := q.file + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, q.file)
+}
+
+// Close 停止定期写入并写入最后一次计数
+func (q *QuotaManager) Close() error {
+	q.stopOnce.Do(func() { close(q.stopChan) })
+	return q.Flush()
+}
+
+// periodWindow 返回统计周期的标识与重置时间, 按 UTC 自然日/自然月划分
+func periodWindow(period string, now time.Time) (string, time.Time, error) {
+	now = now.UTC()
+	switch period {
+	case PeriodDaily:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01-02"), start.AddDate(0, 0, 1), nil
+	case PeriodMonthly:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start.AddDate(0, 1, 0), nil
+	default:
+		return "", time.Time{}, fmt.Errorf("unsupported quota period: %s", period)
+	}
+}
+
+// Take 检查所有配额规则, 全部未超出时各计数加一
+// 返回剩余配额最少的使用情况, 以及超出配额时对应的规则
+func (q *QuotaManager) Take(r *http.Request, service string, quotas []config.ScopedQuota, now time.Time) (*QuotaUsage, *config.Quota, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries := make([]*QuotaUsage, 0, len(quotas))
+	for _, quota := range quotas {
+		periodID, resetAt, err := periodWindow(quota.Period, now)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		consumer := keyValue(r, quota.Key)
+		key := service + "|" + quota.Scope + "|" + consumer
+		usage := q.get(key)
+		if usage == nil || usage.Period != periodID {
+			usage = &QuotaUsage{
+				Service:  service,
+				Scope:    quota.Scope,
+				Consumer: consumer,
+				Period:   periodID,
+				ResetAt:  resetAt,
+			}
+			q.put(key, usage)
+		}
+		usage.Limit = quota.Limit
+
+		if usage.Used >= usage.Limit {
+			copied := *usage
+			return &copied, quota.Quota, nil
+		}
+		entries = append(entries, usage)
+	}
+
+	var final *QuotaUsage
+	for _, usage := range entries {
+		usage.Used++
+		if !usage.anonymous() {
+			q.dirty = true
+		}
+		if final == nil || usage.remaining() < final.remaining() {
+			final = usage
+		}
+	}
+	if final == nil {
+		return nil, nil, nil
+	}
+	copied := *final
+	return &copied, nil, nil
+}
+
+// Limit 执行配额检查并写入 X-Quota-* 响应头, 超出配额时写入配置的响应并返回 false
+func (q *QuotaManager) Limit(w http.ResponseWriter, r *http.Request, upstream *config.Upstream) bool {
+	if len(upstream.Quotas) == 0 {
+		return true
+	}
+
+	now := time.Now()
+	usage, exceeded, err := q.Take(r, upstream.Service, upstream.Quotas, now)
+	if err != nil {
+		logging.Errorf("quota error: %v", err)
+		return true
+	}
+
+	reset := usage.ResetAt.Sub(now)
+	header := w.Header()
+	header.Set("X-Quota-Limit", strconv.FormatInt(usage.Limit, 10))
+	header.Set("X-Quota-Remaining", strconv.FormatInt(usage.remaining(), 10))
+	header.Set("X-Quota-Reset", strconv.Itoa(ceilSeconds(reset)))
+	if exceeded == nil {
+		return true
+	}
+
+	logging.Debugc(r.Context(), "quota exceeded: %s", logging.JsonifyNoIndent(usage))
+	header.Set("Retry-After", strconv.Itoa(max(ceilSeconds(reset), 1)))
+	writeQuotaExceeded(w, r, exceeded.Response)
+	return false
+}
+
+func writeQuotaExceeded(w http.ResponseWriter, r *http.Request, resp *config.QuotaResponse) {
+	if resp == nil || (resp.Status == 0 && resp.Body == "") {
+		writeError(w, r, http.StatusTooManyRequests, "quota exceeded")
+		return
+	}
+
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+	if resp.Body == "" {
+		writeError(w, r, status, "quota exceeded")
+		return
+	}
+
+	contentType := resp.ContentType
+	if contentType == "" {
+		contentType = "application/json; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write([]byte(resp.Body))
+}
+
+// matchConsumer 消费者既可以按完整标识匹配(如 $header.X-Api-Key:abc), 也可以只按取值匹配(abc)
+func matchConsumer(consumer, filter string) bool {
+	return filter == "" || consumer == filter || strings.HasSuffix(consumer, ":"+filter)
+}
+
+// Usage 查询消费者在当前周期的配额使用情况, 参数为空时不过滤
+func (q *QuotaManager) Usage(service, consumer string) []*QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	ret := make([]*QuotaUsage, 0)
+	for _, elem := range q.usage {
+		usage := elem.Value.(*quotaEntry).usage
+		if !usage.ResetAt.After(now) {
+			continue
+		}
+		if (service == "" || usage.Service == service) && matchConsumer(usage.Consumer, consumer) {
+			copied := *usage
+			ret = append(ret, &copied)
+		}
+	}
+	return ret
+}
+
+// Reset 清零消费者在当前周期的配额使用, 返回清零的计数数量
+func (q *QuotaManager) Reset(service, consumer string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := 0
+	for _, elem := range q.usage {
+		if usage := elem.Value.(*quotaEntry).usage; (service == "" || usage.Service == service) && matchConsumer(usage.Consumer, consumer) {
+			q.remove(elem)
+			count++
+		}
+	}
+	if count > 0 {
+		q.dirty = true
+	}
+	return count
+}
@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestPeriodWindow(t *testing.T) {
+	now := time.Date(2026, 12, 31, 18, 0, 0, 0, time.UTC)
+
+	id, resetAt, _ := periodWindow(PeriodDaily, now)
+	assert.Equal(t, "2026-12-31", id)
+	assert.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), resetAt)
+
+	id, resetAt, _ = periodWindow(PeriodMonthly, now)
+	assert.Equal(t, "2026-12", id)
+	assert.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), resetAt)
+}
+
+func TestQuotaManager_LimitAndPersist(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quota.json")
+	q, err := NewQuotaManager(&config.QuotaConfig{File: file, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("new quota manager error: %v", err)
+	}
+
+	upstream := &config.Upstream{
+		Service: "test",
+		Quotas: []config.ScopedQuota{{Scope: "*", Quota: &config.Quota{
+			Period:   PeriodDaily,
+			Limit:    2,
+			Key:      "$header.X-Api-Key",
+			Response: &config.QuotaResponse{Status: http.StatusPaymentRequired, Body: `{"error":"upgrade"}`},
+		}}},
+	}
+	do := func(q *QuotaManager) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Api-Key", "abc")
+		w := httptest.NewRecorder()
+		q.Limit(w, r, upstream)
+		return w
+	}
+
+	w := do(q)
+	assert.Equal(t, "1", w.Header().Get("X-Quota-Remaining"))
+	if err := q.Close(); err != nil {
+		t.Fatalf("close quota manager error: %v", err)
+	}
+
+	// 重启后从文件恢复计数
+	q, err = NewQuotaManager(&config.QuotaConfig{File: file, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("reload quota manager error: %v", err)
+	}
+	defer q.Close()
+
+	w = do(q)
+	assert.Equal(t, "0", w.Header().Get("X-Quota-Remaining"))
+
+	w = do(q)
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+	assert.Equal(t, `{"error":"upgrade"}`, w.Body.String())
+
+	usage := q.Usage("test", "abc")
+	assert.Equal(t, 1, len(usage))
+	assert.Equal(t, int64(2), usage[0].Used)
+
+	assert.Equal(t, 1, q.Reset("", "abc"))
+	w = do(q)
+	assert.Equal(t, "1", w.Header().Get("X-Quota-Remaining"))
+}
+
+func TestQuotaManager_FlushRetriesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "quota", "quota.json")
+	q, err := NewQuotaManager(&config.QuotaConfig{File: file, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("new quota manager error: %v", err)
+	}
+	defer q.Close()
+
+	upstream := &config.Upstream{
+		Service: "test",
+		Quotas:  []config.ScopedQuota{{Scope: "*", Quota: &config.Quota{Period: PeriodDaily, Limit: 10, Key: "$header.X-Api-Key"}}},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Api-Key", "abc")
+	q.Limit(httptest.NewRecorder(), r, upstream)
+
+	// 目录位置被文件占用时写入失败, 变化标记保留
+	if err := os.WriteFile(filepath.Join(dir, "quota"), nil, 0644); err != nil {
+		t.Fatalf("write file error: %v", err)
+	}
+	if err := q.Flush(); err == nil {
+		t.Fatalf("expected flush error")
+	}
+
+	if err := os.Remove(filepath.Join(dir, "quota")); err != nil {
+		t.Fatalf("remove file error: %v", err)
+	}
+	if err := q.Flush(); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("expected quota file written after retry: %v", err)
+	}
+}
+
+func TestQuotaManager_BoundedAndAnonymousNotPersisted(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quota.json")
+	q, err := NewQuotaManager(&config.QuotaConfig{File: file, FlushInterval: time.Hour, MaxConsumers: 2})
+	if err != nil {
+		t.Fatalf("new quota manager error: %v", err)
+	}
+
+	upstream := &config.Upstream{
+		Service: "test",
+		Quotas:  []config.ScopedQuota{{Scope: "*", Quota: &config.Quota{Period: PeriodDaily, Limit: 100, Key: "$header.X-Api-Key"}}},
+	}
+	do := func(apiKey, remoteAddr string) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			r.Header.Set("X-Api-Key", apiKey)
+		}
+		q.Limit(httptest.NewRecorder(), r, upstream)
+	}
+
+	do("abc", "10.0.0.1:1234")
+	// 未携带 API Key 的请求按客户端IP计数, 超出上限时淘汰最久未使用的计数
+	for i := 0; i < 10; i++ {
+		do("", fmt.Sprintf("10.0.1.%d:1234", i))
+		do("abc", "10.0.0.1:1234")
+	}
+	assert.Equal(t, 2, len(q.Usage("", "")))
+	assert.Equal(t, int64(11), q.Usage("test", "abc")[0].Used)
+
+	if err := q.Close(); err != nil {
+		t.Fatalf("close quota manager error: %v", err)
+	}
+	q, err = NewQuotaManager(&config.QuotaConfig{File: file, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("reload quota manager error: %v", err)
+	}
+	defer q.Close()
+
+	// 按IP计数的消费者不写入文件
+	usage := q.Usage("", "")
+	assert.Equal(t, 1, len(usage))
+	assert.Equal(t, "$header.X-Api-Key:abc", usage[0].Consumer)
+}
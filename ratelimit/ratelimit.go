@@ -0,0 +1,280 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/clientip"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/requestid"
+	"github.com/superwhys/litegate/utils"
+)
+
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+
+	KeyIP    = "$ip"
+	KeyRoute = "$route"
+
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+
+	FailPolicyOpen   = "open"
+	FailPolicyClosed = "closed"
+)
+
+// Phase 限流规则的执行阶段
+type Phase int
+
+const (
+	// PhaseBeforeAuth 不依赖声明的规则在身份验证之前执行, 验证失败的请求同样受到限流
+	PhaseBeforeAuth Phase = iota
+	// PhaseAfterAuth 按声明限流的规则在身份验证之后执行
+	PhaseAfterAuth
+)
+
+// RulePhase 按限流维度返回规则的执行阶段
+func RulePhase(key string) Phase {
+	if place, _ := utils.ParsePlace(key); place == auth.PlaceClaim {
+		return PhaseAfterAuth
+	}
+	return PhaseBeforeAuth
+}
+
+// Rule 解析后的限流规则
+type Rule struct {
+	Algorithm string
+	Limit     int
+	Burst     int
+	Window    time.Duration
+}
+
+// Result 单次限流判断结果
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// 计数完全恢复的剩余时间
+	Reset time.Duration
+	// 被拒绝时建议的重试等待时间
+	RetryAfter time.Duration
+}
+
+// Store 限流计数存储
+type Store interface {
+	Take(ctx context.Context, key string, rule *Rule, now time.Time) (*Result, error)
+	// Refund 归还一次放行消耗的计数, 用于后续规则拒绝请求时撤销已通过的规则
+	Refund(ctx context.Context, key string, rule *Rule, now time.Time) error
+}
+
+type Limiter struct {
+	store Store
+	// 存储不可用时拒绝请求
+	failClosed bool
+	// 存储仅支持滑动窗口计数, 如 redis
+	slidingOnly bool
+}
+
+func NewLimiter(cfg *config.RateLimitConfig) (*Limiter, error) {
+	if cfg == nil {
+		cfg = &config.RateLimitConfig{}
+	}
+
+	limiter := &Limiter{failClosed: cfg.FailPolicy == FailPolicyClosed}
+	switch cfg.Store {
+	case "", StoreMemory:
+		limiter.store = NewMemoryStore(cfg.MaxKeys)
+	case StoreRedis:
+		store, err := NewRedisStore(cfg.Redis, cfg.MaxKeys)
+		if err != nil {
+			return nil, err
+		}
+		limiter.store = store
+		limiter.slidingOnly = true
+	default:
+		return nil, fmt.Errorf("unsupported rate limit store: %s", cfg.Store)
+	}
+	return limiter, nil
+}
+
+// parseRule 解析限流规则, 仅支持滑动窗口的存储未指定算法时使用滑动窗口, 显式配置令牌桶时返回错误
+func (l *Limiter) parseRule(rl *config.RateLimit) (*Rule, error) {
+	window, err := time.ParseDuration(rl.Window)
+	if err != nil {
+		return nil, fmt.Errorf("parse rate limit window error: %w", err)
+	}
+	if window <= 0 || rl.Limit <= 0 {
+		return nil, fmt.Errorf("invalid rate limit: %d/%s", rl.Limit, rl.Window)
+	}
+
+	rule := &Rule{
+		Algorithm: rl.Algorithm,
+		Limit:     rl.Limit,
+		Burst:     rl.Burst,
+		Window:    window,
+	}
+	if l.slidingOnly {
+		if rule.Algorithm == AlgorithmTokenBucket || rule.Burst > 0 {
+			return nil, fmt.Errorf("rate limit store only supports %s, token_bucket and burst are not allowed", AlgorithmSlidingWindow)
+		}
+		rule.Algorithm = AlgorithmSlidingWindow
+	}
+	if rule.Algorithm == "" {
+		rule.Algorithm = AlgorithmTokenBucket
+	}
+	if rule.Burst <= 0 {
+		rule.Burst = rule.Limit
+	}
+	return rule, nil
+}
+
+// Validate 校验服务及其所有路由的限流规则是否被当前存储支持
+func (l *Limiter) Validate(rc *config.RouteConfig) error {
+	if rc.RateLimit != nil {
+		if _, err := l.parseRule(rc.RateLimit); err != nil {
+			return err
+		}
+	}
+	for _, route := range rc.Routes {
+		if route.RateLimit == nil {
+			continue
+		}
+		if _, err := l.parseRule(route.RateLimit); err != nil {
+			return fmt.Errorf("route %s: %w", route.Match, err)
+		}
+	}
+	return nil
+}
+
+// keyValue 根据限流维度从请求中取值, 取不到时退化为客户端IP
+func keyValue(r *http.Request, key string) string {
+	var value string
+	switch key {
+	case "", KeyIP:
+	case KeyRoute:
+		return "*"
+	default:
+		place, name := utils.ParsePlace(key)
+		switch place {
+		case auth.PlaceHeader:
+			value = r.Header.Get(name)
+		case auth.PlaceQuery:
+			value = r.URL.Query().Get(name)
+		case auth.PlaceClaim:
+			value, _ = auth.ClaimsFromContext(r.Context()).String(name)
+		}
+	}
+	if value == "" {
+		return KeyIP + ":" + clientip.FromRequest(r)
+	}
+	return key + ":" + value
+}
+
+// Check 依次评估所有限流规则, 返回第一个被拒绝的结果或剩余配额最少的结果
+// 请求被拒绝或出错时归还之前规则已消耗的计数, 被拒绝的请求不消耗任何规则的配额
+func (l *Limiter) Check(r *http.Request, service string, limits []config.ScopedRateLimit) (*Result, error) {
+	type taken struct {
+		key  string
+		rule *Rule
+	}
+
+	var (
+		final  *Result
+		passed []taken
+	)
+	now := time.Now()
+	refund := func() {
+		for _, t := range passed {
+			if err := l.store.Refund(r.Context(), t.key, t.rule, now); err != nil {
+				logging.Errorf("refund rate limit error: key=%s, err=%v", t.key, err)
+			}
+		}
+	}
+
+	for _, limit := range limits {
+		rule, err := l.parseRule(limit.RateLimit)
+		if err != nil {
+			refund()
+			return nil, err
+		}
+
+		key := service + "|" + limit.Scope + "|" + keyValue(r, limit.Key)
+		result, err := l.store.Take(r.Context(), key, rule, now)
+		if err != nil {
+			refund()
+			return nil, err
+		}
+		if !result.Allowed {
+			refund()
+			return result, nil
+		}
+		passed = append(passed, taken{key: key, rule: rule})
+		if final == nil || result.Remaining < final.Remaining {
+			final = result
+		}
+	}
+	return final, nil
+}
+
+// Limit 执行指定阶段的限流规则并写入 X-RateLimit-* 响应头, 被拒绝时返回 429 并返回 false
+// 两个阶段都有规则时响应头取剩余配额较少的一个
+func (l *Limiter) Limit(w http.ResponseWriter, r *http.Request, upstream *config.Upstream, phase Phase) bool {
+	var limits []config.ScopedRateLimit
+	for _, limit := range upstream.RateLimits {
+		if RulePhase(limit.Key) == phase {
+			limits = append(limits, limit)
+		}
+	}
+	if len(limits) == 0 {
+		return true
+	}
+
+	result, err := l.Check(r, upstream.Service, limits)
+	if err != nil {
+		logging.Errorf("rate limit error: %v", err)
+		if !l.failClosed {
+			return true
+		}
+		w.Header().Set("Retry-After", "1")
+		writeError(w, r, http.StatusServiceUnavailable, "rate limit unavailable")
+		return false
+	}
+
+	header := w.Header()
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if !result.Allowed || err != nil || result.Remaining < remaining {
+		header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(max(result.Remaining, 0)))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	}
+	if result.Allowed {
+		return true
+	}
+
+	logging.Debugc(r.Context(), "rate limited: %s", logging.JsonifyNoIndent(result))
+	header.Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+	writeError(w, r, http.StatusTooManyRequests, "too many requests")
+	return false
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	b, _ := json.Marshal(requestid.ErrorRet(r.Context(), status, message))
+	w.Write(b)
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
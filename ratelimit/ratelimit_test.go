@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	store := NewMemoryStore(10)
+	rule := &Rule{Algorithm: AlgorithmTokenBucket, Limit: 2, Burst: 2, Window: time.Second}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		result, _ := store.Take(context.Background(), "k", rule, now)
+		assert.Equal(t, true, result.Allowed)
+		assert.Equal(t, 1-i, result.Remaining)
+	}
+
+	result, _ := store.Take(context.Background(), "k", rule, now)
+	assert.Equal(t, false, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+
+	// 500ms 后恢复一个令牌
+	result, _ = store.Take(context.Background(), "k", rule, now.Add(500*time.Millisecond))
+	assert.Equal(t, true, result.Allowed)
+}
+
+func TestMemoryStore_SlidingWindow(t *testing.T) {
+	store := NewMemoryStore(10)
+	rule := &Rule{Algorithm: AlgorithmSlidingWindow, Limit: 4, Window: time.Minute}
+	start := time.Now().Truncate(time.Minute)
+
+	for i := 0; i < 4; i++ {
+		result, _ := store.Take(context.Background(), "k", rule, start.Add(time.Second))
+		assert.Equal(t, true, result.Allowed)
+	}
+	result, _ := store.Take(context.Background(), "k", rule, start.Add(time.Second))
+	assert.Equal(t, false, result.Allowed)
+	assert.Equal(t, 59*time.Second, result.RetryAfter)
+
+	// 下一个窗口过去一半时, 上一个窗口的 4 个请求按 50% 计入
+	mid := start.Add(rule.Window + 30*time.Second)
+	for i := 0; i < 2; i++ {
+		result, _ = store.Take(context.Background(), "k", rule, mid)
+		assert.Equal(t, true, result.Allowed)
+	}
+	result, _ = store.Take(context.Background(), "k", rule, mid)
+	assert.Equal(t, false, result.Allowed)
+}
+
+func TestMemoryStore_LRUEviction(t *testing.T) {
+	store := NewMemoryStore(shardCount)
+	rule := &Rule{Algorithm: AlgorithmTokenBucket, Limit: 1, Burst: 1, Window: time.Hour}
+	now := time.Now()
+
+	for i := 0; i < 10*shardCount; i++ {
+		store.Take(context.Background(), fmt.Sprintf("k%d", i), rule, now)
+	}
+
+	total := 0
+	for _, shard := range store.shards {
+		total += shard.len()
+	}
+	if total > shardCount {
+		t.Errorf("expected at most %d keys, got %d", shardCount, total)
+	}
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	limiter, err := NewLimiter(nil)
+	if err != nil {
+		t.Fatalf("NewLimiter error: %v", err)
+	}
+
+	upstream := &config.Upstream{
+		Service: "test",
+		RateLimits: []config.ScopedRateLimit{
+			{Scope: "*", RateLimit: &config.RateLimit{Limit: 100, Window: "1m"}},
+			{Scope: "^/api/.*", RateLimit: &config.RateLimit{Limit: 1, Window: "1m", Key: "$claim.user_id"}},
+		},
+	}
+
+	// 与网关相同, 按IP的规则在身份验证之前执行, 按声明的规则在身份验证之后执行
+	limit := func(rr *httptest.ResponseRecorder, req *http.Request, upstream *config.Upstream) bool {
+		return limiter.Limit(rr, req, upstream, PhaseBeforeAuth) && limiter.Limit(rr, req, upstream, PhaseAfterAuth)
+	}
+
+	newRequest := func(userId, remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/api/hello", nil)
+		req.RemoteAddr = remoteAddr
+		if userId != "" {
+			req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"user_id": userId}))
+		}
+		return req
+	}
+
+	rr := httptest.NewRecorder()
+	assert.Equal(t, true, limit(rr, newRequest("1", "10.0.0.1:1234"), upstream))
+	assert.Equal(t, "1", rr.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+
+	// 同一用户从不同IP访问共享计数
+	rr = httptest.NewRecorder()
+	assert.Equal(t, false, limit(rr, newRequest("1", "10.0.0.2:1234"), upstream))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+
+	// 其他用户不受影响
+	rr = httptest.NewRecorder()
+	assert.Equal(t, true, limit(rr, newRequest("2", "10.0.0.1:1234"), upstream))
+
+	// 取不到声明时按客户端IP限流
+	rr = httptest.NewRecorder()
+	assert.Equal(t, true, limit(rr, newRequest("", "10.0.0.3:1234"), upstream))
+	rr = httptest.NewRecorder()
+	assert.Equal(t, false, limit(rr, newRequest("", "10.0.0.3:4321"), upstream))
+}
+
+func TestLimiter_RejectedRefundsEarlierRules(t *testing.T) {
+	limiter, err := NewLimiter(nil)
+	if err != nil {
+		t.Fatalf("NewLimiter error: %v", err)
+	}
+
+	limits := []config.ScopedRateLimit{
+		{Scope: "*", RateLimit: &config.RateLimit{Limit: 2, Window: "1m", Algorithm: AlgorithmSlidingWindow}},
+		{Scope: "^/api/.*", RateLimit: &config.RateLimit{Limit: 1, Window: "1m", Key: "$header.X-Api-Key"}},
+	}
+	newRequest := func(apiKey string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/api/hello", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		return req
+	}
+
+	result, _ := limiter.Check(newRequest("a"), "test", limits)
+	assert.Equal(t, true, result.Allowed)
+
+	// 第二条规则拒绝时, 第一条规则的计数被归还
+	for i := 0; i < 3; i++ {
+		result, _ = limiter.Check(newRequest("a"), "test", limits)
+		assert.Equal(t, false, result.Allowed)
+	}
+	result, _ = limiter.Check(newRequest("b"), "test", limits)
+	assert.Equal(t, true, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/config"
+)
+
+// unhealthyBackoff 存储访问失败后直接返回错误的时间, 避免每个请求都等待连接超时
+const unhealthyBackoff = time.Second
+
+// redisStore 基于 Redis 协议兼容存储的分布式滑动窗口计数
+// 每个窗口的计数通过 INCRBY 原子累加, 本地预聚合未同步的计数以减少网络往返
+// 只支持滑动窗口, 配置令牌桶或 burst 的规则由 Limiter 拒绝
+// 只有放行的请求计入窗口, 被拒绝的请求在同步时已累加的计数会在下次同步时扣除
+type redisStore struct {
+	client       *redisClient
+	prefix       string
+	syncInterval time.Duration
+	batchSize    int
+	local        *memoryStore
+
+	unhealthyUntil atomic.Int64
+}
+
+func NewRedisStore(cfg *config.RedisConfig, maxKeys int) (*redisStore, error) {
+	if cfg == nil || cfg.Addr == "" {
+		return nil, fmt.Errorf("redis addr is required")
+	}
+	cfg.SetDefault()
+
+	return &redisStore{
+		client:       newRedisClient(cfg.Addr, cfg.Password, cfg.DB, cfg.PoolSize, cfg.DialTimeout, cfg.IOTimeout),
+		prefix:       cfg.KeyPrefix,
+		syncInterval: cfg.SyncInterval,
+		batchSize:    cfg.BatchSize,
+		local:        NewMemoryStore(maxKeys),
+	}, nil
+}
+
+func (rs *redisStore) windowKey(key string, windowStart time.Time) string {
+	return rs.prefix + ":" + key + ":" + strconv.FormatInt(windowStart.UnixMilli(), 10)
+}
+
+// sync 将本地计数累加到当前窗口, 并返回当前窗口与上一个窗口的全局计数
+func (rs *redisStore) sync(ctx context.Context, key string, rule *Rule, windowStart time.Time, incr int) (current, previous int, err error) {
+	if time.Now().UnixNano() < rs.unhealthyUntil.Load() {
+		return 0, 0, fmt.Errorf("redis store unavailable")
+	}
+
+	currentKey := rs.windowKey(key, windowStart)
+	replies, err := rs.client.Pipeline(ctx,
+		[]string{"INCRBY", currentKey, strconv.Itoa(incr)},
+		[]string{"PEXPIRE", currentKey, strconv.FormatInt((2*rule.Window + time.Second).Milliseconds(), 10)},
+		[]string{"GET", rs.windowKey(key, windowStart.Add(-rule.Window))},
+	)
+	if err == nil {
+		err = firstError(replies)
+	}
+	if err != nil {
+		rs.unhealthyUntil.Store(time.Now().Add(unhealthyBackoff).UnixNano())
+		return 0, 0, err
+	}
+
+	cur, err := replyInt(replies[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	prev, err := replyInt(replies[2])
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(cur), int(prev), nil
+}
+
+func (rs *redisStore) Take(ctx context.Context, key string, rule *Rule, now time.Time) (*Result, error) {
+	e := rs.local.entry(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	windowStart := now.Truncate(rule.Window)
+	if !e.windowStart.Equal(windowStart) {
+		// 进入新窗口前先同步上一个窗口中尚未同步的计数
+		if e.pending != 0 {
+			if _, _, err := rs.sync(ctx, key, rule, e.windowStart, e.pending); err != nil {
+				logging.Errorf("sync rate limit count error: key=%s, pending=%d, err=%v", key, e.pending, err)
+			}
+		}
+		e.windowStart = windowStart
+		e.current, e.previous, e.pending = 0, 0, 0
+		e.lastSync = time.Time{}
+	}
+
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+
+	needSync := rs.batchSize <= 1 || e.lastSync.IsZero() ||
+		e.pending+1 >= rs.batchSize || now.Sub(e.lastSync) >= rs.syncInterval
+
+	var (
+		count   float64
+		allowed bool
+	)
+	if needSync {
+		current, previous, err := rs.sync(ctx, key, rule, windowStart, e.pending+1)
+		if err != nil {
+			return nil, err
+		}
+		e.current, e.previous, e.pending, e.lastSync = current, previous, 0, now
+
+		// 远端计数已包含本次请求, 被拒绝时在下次同步中扣除
+		count = float64(e.previous)*weight + float64(e.current)
+		allowed = count <= float64(rule.Limit)
+		if !allowed {
+			e.pending = -1
+		}
+	} else {
+		count = float64(e.previous)*weight + float64(e.current+e.pending+1)
+		allowed = count <= float64(rule.Limit)
+		if allowed {
+			e.pending++
+		}
+	}
+
+	result := &Result{
+		Allowed:   allowed,
+		Limit:     rule.Limit,
+		Remaining: rule.Limit - int(count),
+		Reset:     rule.Window - elapsed,
+	}
+	if !allowed {
+		result.RetryAfter = slidingRetryAfter(e, rule, elapsed)
+	}
+	return result, nil
+}
+
+// Refund 归还一次放行消耗的计数, 在下次同步时从远端计数中扣除
+func (rs *redisStore) Refund(ctx context.Context, key string, rule *Rule, now time.Time) error {
+	e := rs.local.entry(key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.windowStart.Equal(now.Truncate(rule.Window)) {
+		e.pending--
+	}
+	return nil
+}
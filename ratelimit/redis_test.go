@@ -0,0 +1,259 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+// fakeRedis 进程内的 Redis 协议替身, 仅实现限流用到的命令
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	data     map[string]int64
+	// 收到的命令批次数, 近似为网络往返次数
+	batches atomic.Int64
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen error: %v", err)
+	}
+	fr := &fakeRedis{listener: listener, data: make(map[string]int64)}
+	go fr.serve()
+	t.Cleanup(fr.Close)
+	return fr
+}
+
+func (fr *fakeRedis) Addr() string {
+	return fr.listener.Addr().String()
+}
+
+func (fr *fakeRedis) Close() {
+	fr.listener.Close()
+}
+
+func (fr *fakeRedis) serve() {
+	for {
+		conn, err := fr.listener.Accept()
+		if err != nil {
+			return
+		}
+		go fr.handle(conn)
+	}
+}
+
+func (fr *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	wr := bufio.NewWriter(conn)
+	for {
+		reply, err := readReply(rd)
+		if err != nil {
+			return
+		}
+		if rd.Buffered() == 0 {
+			fr.batches.Add(1)
+		}
+
+		items := reply.([]any)
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = item.(string)
+		}
+		wr.WriteString(fr.exec(args))
+		if rd.Buffered() == 0 {
+			wr.Flush()
+		}
+	}
+}
+
+func (fr *fakeRedis) exec(args []string) string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "INCRBY":
+		incr, _ := strconv.ParseInt(args[2], 10, 64)
+		fr.data[args[1]] += incr
+		return ":" + strconv.FormatInt(fr.data[args[1]], 10) + "\r\n"
+	case "PEXPIRE":
+		return ":1\r\n"
+	case "GET":
+		value, ok := fr.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		s := strconv.FormatInt(value, 10)
+		return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedisStore_SharedAcrossReplicas(t *testing.T) {
+	fr := newFakeRedis(t)
+	rule := &Rule{Algorithm: AlgorithmSlidingWindow, Limit: 5, Window: time.Minute}
+	now := time.Now().Truncate(time.Minute).Add(time.Second)
+
+	var replicas []*redisStore
+	for i := 0; i < 2; i++ {
+		store, err := NewRedisStore(&config.RedisConfig{Addr: fr.Addr(), Password: "secret", DB: 1}, 0)
+		if err != nil {
+			t.Fatalf("NewRedisStore error: %v", err)
+		}
+		replicas = append(replicas, store)
+	}
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		result, err := replicas[i%2].Take(context.Background(), "k", rule, now)
+		if err != nil {
+			t.Fatalf("Take error: %v", err)
+		}
+		if result.Allowed {
+			allowed++
+		}
+	}
+	assert.Equal(t, 5, allowed)
+}
+
+func TestRedisStore_LocalPreAggregation(t *testing.T) {
+	fr := newFakeRedis(t)
+	store, err := NewRedisStore(&config.RedisConfig{
+		Addr:         fr.Addr(),
+		BatchSize:    10,
+		SyncInterval: time.Hour,
+	}, 0)
+	if err != nil {
+		t.Fatalf("NewRedisStore error: %v", err)
+	}
+
+	rule := &Rule{Algorithm: AlgorithmSlidingWindow, Limit: 100, Window: time.Minute}
+	now := time.Now().Truncate(time.Minute).Add(time.Second)
+	for i := 0; i < 50; i++ {
+		result, err := store.Take(context.Background(), "k", rule, now)
+		if err != nil {
+			t.Fatalf("Take error: %v", err)
+		}
+		assert.Equal(t, true, result.Allowed)
+	}
+
+	// 首次请求同步一次, 之后每累计 10 次请求同步一次
+	if batches := fr.batches.Load(); batches > 6 {
+		t.Errorf("expected at most 6 round trips, got %d", batches)
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	var total int64
+	for _, count := range fr.data {
+		total += count
+	}
+	if total < 41 || total > 50 {
+		t.Errorf("unexpected synced count: %d", total)
+	}
+}
+
+func TestLimiter_FailPolicy(t *testing.T) {
+	fr := newFakeRedis(t)
+	fr.Close()
+
+	upstream := &config.Upstream{
+		Service: "test",
+		RateLimits: []config.ScopedRateLimit{
+			{Scope: "*", RateLimit: &config.RateLimit{Limit: 1, Window: "1m"}},
+		},
+	}
+
+	testCases := []struct {
+		policy string
+		ok     bool
+		status int
+	}{
+		{FailPolicyOpen, true, http.StatusOK},
+		{FailPolicyClosed, false, http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			limiter, err := NewLimiter(&config.RateLimitConfig{
+				Store:      StoreRedis,
+				FailPolicy: tc.policy,
+				Redis:      &config.RedisConfig{Addr: fr.Addr(), DialTimeout: 100 * time.Millisecond},
+			})
+			if err != nil {
+				t.Fatalf("NewLimiter error: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/", nil)
+			assert.Equal(t, tc.ok, limiter.Limit(rr, req, upstream, PhaseBeforeAuth))
+			assert.Equal(t, tc.status, rr.Code)
+		})
+	}
+}
+
+func TestRedisStore_RejectedNotCounted(t *testing.T) {
+	fr := newFakeRedis(t)
+	store, err := NewRedisStore(&config.RedisConfig{Addr: fr.Addr()}, 0)
+	if err != nil {
+		t.Fatalf("NewRedisStore error: %v", err)
+	}
+
+	rule := &Rule{Algorithm: AlgorithmSlidingWindow, Limit: 3, Window: time.Minute}
+	now := time.Now().Truncate(time.Minute).Add(time.Second)
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		result, err := store.Take(context.Background(), "k", rule, now)
+		if err != nil {
+			t.Fatalf("Take error: %v", err)
+		}
+		if result.Allowed {
+			allowed++
+		}
+	}
+	assert.Equal(t, 3, allowed)
+
+	// 远端计数最多包含最近一次被拒绝且尚未扣除的请求
+	fr.mu.Lock()
+	count := fr.data[store.windowKey("k", now.Truncate(time.Minute))]
+	fr.mu.Unlock()
+	if count > 4 {
+		t.Fatalf("rejected requests should not be counted, got %d", count)
+	}
+}
+
+func TestLimiter_RedisRejectsTokenBucket(t *testing.T) {
+	fr := newFakeRedis(t)
+	limiter, err := NewLimiter(&config.RateLimitConfig{Store: StoreRedis, Redis: &config.RedisConfig{Addr: fr.Addr()}})
+	if err != nil {
+		t.Fatalf("NewLimiter error: %v", err)
+	}
+
+	rc := &config.RouteConfig{RateLimit: &config.RateLimit{Limit: 10, Window: "1s"}}
+	assert.Equal(t, nil, limiter.Validate(rc))
+
+	rc.Routes = []config.Route{{Match: "/a", RateLimit: &config.RateLimit{Algorithm: AlgorithmTokenBucket, Limit: 10, Window: "1s"}}}
+	if limiter.Validate(rc) == nil {
+		t.Fatalf("expected token_bucket rejected by redis store")
+	}
+	rc.Routes = []config.Route{{Match: "/a", RateLimit: &config.RateLimit{Limit: 10, Burst: 20, Window: "1s"}}}
+	if limiter.Validate(rc) == nil {
+		t.Fatalf("expected burst rejected by redis store")
+	}
+}
@@ -0,0 +1,242 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// redisError Redis 返回的错误回复
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisClient 基于 RESP 协议的精简 Redis 客户端, 仅支持流水线执行命令
+type redisClient struct {
+	addr        string
+	password    string
+	db          int
+	dialTimeout time.Duration
+	ioTimeout   time.Duration
+	pool        chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+	wr   *bufio.Writer
+}
+
+func newRedisClient(addr, password string, db int, poolSize int, dialTimeout, ioTimeout time.Duration) *redisClient {
+	return &redisClient{
+		addr:        addr,
+		password:    password,
+		db:          db,
+		dialTimeout: dialTimeout,
+		ioTimeout:   ioTimeout,
+		pool:        make(chan *redisConn, poolSize),
+	}
+}
+
+func (c *redisClient) dial(ctx context.Context) (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: c.dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &redisConn{conn: conn, rd: bufio.NewReader(conn), wr: bufio.NewWriter(conn)}
+	var setup [][]string
+	if c.password != "" {
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		replies, err := rc.do(ctx, setup, c.ioTimeout)
+		if err == nil {
+			err = firstError(replies)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+func (c *redisClient) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case rc := <-c.pool:
+		return rc, nil
+	default:
+		return c.dial(ctx)
+	}
+}
+
+func (c *redisClient) put(rc *redisConn) {
+	select {
+	case c.pool <- rc:
+	default:
+		rc.conn.Close()
+	}
+}
+
+// Pipeline 在同一连接上一次性发送多条命令并按顺序返回回复
+// 回复中的 Redis 错误以 redisError 的形式返回, 由调用方自行判断
+func (c *redisClient) Pipeline(ctx context.Context, cmds ...[]string) ([]any, error) {
+	rc, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := rc.do(ctx, cmds, c.ioTimeout)
+	if err != nil {
+		rc.conn.Close()
+		return nil, err
+	}
+	c.put(rc)
+	return replies, nil
+}
+
+func (c *redisClient) Close() {
+	for {
+		select {
+		case rc := <-c.pool:
+			rc.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (rc *redisConn) do(ctx context.Context, cmds [][]string, timeout time.Duration) ([]any, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	rc.conn.SetDeadline(deadline)
+
+	for _, cmd := range cmds {
+		writeCommand(rc.wr, cmd)
+	}
+	if err := rc.wr.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]any, 0, len(cmds))
+	for range cmds {
+		reply, err := readReply(rc.rd)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+func writeCommand(wr *bufio.Writer, args []string) {
+	fmt.Fprintf(wr, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(wr, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+func readLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("invalid redis reply line")
+	}
+	return line[:len(line)-2], nil
+}
+
+// readReply 读取一条 RESP 回复: 字符串, 整数, 批量字符串(nil 表示不存在), 数组或 redisError
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := readLine(rd)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		items := make([]any, 0, size)
+		for i := 0; i < size; i++ {
+			item, err := readReply(rd)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply: %q", line)
+	}
+}
+
+func firstError(replies []any) error {
+	for _, reply := range replies {
+		if err, ok := reply.(redisError); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// replyInt 将整数或数字字符串回复转换为整数, nil 视为 0
+func replyInt(reply any) (int64, error) {
+	switch v := reply.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case redisError:
+		return 0, v
+	default:
+		return 0, fmt.Errorf("unexpected redis reply type: %T", reply)
+	}
+}
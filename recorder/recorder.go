@@ -0,0 +1,226 @@
+package recorder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/utils"
+)
+
+const (
+	redacted = "[REDACTED]"
+
+	// maxOpenFiles 同时打开的录制文件上限, 超出后关闭最久未写入的文件
+	maxOpenFiles = 64
+
+	BodyEncodingBase64 = "base64"
+)
+
+// Entry 一次录制的请求与响应, 每条占 JSONL 文件的一行
+type Entry struct {
+	Time         time.Time `json:"time"`
+	RequestID    string    `json:"request_id,omitempty"`
+	Service      string    `json:"service"`
+	Route        string    `json:"route,omitempty"`
+	Upstream     string    `json:"upstream,omitempty"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`          // 网关请求路径与脱敏后的查询参数, 如 /__test/api?id=1
+	UpstreamPath string    `json:"upstream_path"` // 转发给上游的路径与脱敏后的查询参数
+	DurationMs   float64   `json:"duration_ms"`
+	Request      Message   `json:"request"`
+	Response     Message   `json:"response"`
+}
+
+// Message 请求或响应的内容, 非 UTF-8 的消息体使用 base64 编码
+type Message struct {
+	Status       int         `json:"status,omitempty"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+	Truncated    bool        `json:"truncated,omitempty"`
+}
+
+// BodyBytes 返回解码后的消息体
+func (m Message) BodyBytes() ([]byte, error) {
+	if m.BodyEncoding == BodyEncodingBase64 {
+		return base64.StdEncoding.DecodeString(m.Body)
+	}
+	return []byte(m.Body), nil
+}
+
+// Recorder 流量录制器, 为 nil 时表示未启用
+type Recorder struct {
+	dir           string
+	services      []string
+	sampleRatio   float64
+	maxBodyBytes  int64
+	maxSize       int64
+	maxBackups    int
+	redactHeaders []string
+	redactQuery   []string
+
+	mu    sync.Mutex
+	files map[string]*recordFile
+}
+
+type recordFile struct {
+	*utils.RotatingFile
+	lastWrite time.Time
+}
+
+// NewRecorder 根据配置创建录制器, 未启用时返回 nil
+func NewRecorder(cfg *config.RecorderConfig) (*Recorder, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	cfg.SetDefault()
+
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		dir:           cfg.Dir,
+		services:      cfg.Services,
+		sampleRatio:   cfg.SampleRatio,
+		maxBodyBytes:  cfg.MaxBodyBytes,
+		maxSize:       int64(cfg.MaxSizeMB) << 20,
+		maxBackups:    cfg.MaxBackups,
+		redactHeaders: cfg.RedactHeaders,
+		redactQuery:   cfg.RedactQuery,
+		files:         make(map[string]*recordFile),
+	}, nil
+}
+
+// Sampled 判断是否录制该服务的本次请求
+func (rc *Recorder) Sampled(service string) bool {
+	if rc == nil {
+		return false
+	}
+	if len(rc.services) > 0 && !slices.Contains(rc.services, service) {
+		return false
+	}
+	return rc.sampleRatio >= 1 || rand.Float64() < rc.sampleRatio
+}
+
+// MaxBodyBytes 请求体与响应体各自录制的最大字节数
+func (rc *Recorder) MaxBodyBytes() int64 {
+	return rc.maxBodyBytes
+}
+
+// NewMessage 生成脱敏后的消息
+func (rc *Recorder) NewMessage(status int, header http.Header, body *LimitedBuffer) Message {
+	msg := Message{Status: status, Headers: header.Clone()}
+	for name := range msg.Headers {
+		if slices.ContainsFunc(rc.redactHeaders, func(redact string) bool { return strings.EqualFold(redact, name) }) {
+			msg.Headers[name] = []string{redacted}
+		}
+	}
+
+	if body != nil {
+		msg.Truncated = body.Truncated
+		if data := body.Bytes(); utf8.Valid(data) {
+			msg.Body = string(data)
+		} else {
+			msg.Body = base64.StdEncoding.EncodeToString(data)
+			msg.BodyEncoding = BodyEncodingBase64
+		}
+	}
+	return msg
+}
+
+// RedactQuery 返回脱敏后的查询参数
+func (rc *Recorder) RedactQuery(rawQuery string) string {
+	return utils.RedactQuery(rawQuery, rc.redactQuery, redacted)
+}
+
+// Write 将录制记录追加到服务对应的文件
+func (rc *Recorder) Write(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	file, ok := rc.files[entry.Service]
+	if !ok {
+		if len(rc.files) >= maxOpenFiles {
+			rc.closeOldest()
+		}
+		rotating, err := utils.NewRotatingFile(filepath.Join(rc.dir, entry.Service+".jsonl"), rc.maxSize, rc.maxBackups)
+		if err != nil {
+			return err
+		}
+		file = &recordFile{RotatingFile: rotating}
+		rc.files[entry.Service] = file
+	}
+	file.lastWrite = time.Now()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// closeOldest 关闭最久未写入的录制文件, 再次写入时重新打开
+func (rc *Recorder) closeOldest() {
+	var oldest string
+	for service, file := range rc.files {
+		if oldest == "" || file.lastWrite.Before(rc.files[oldest].lastWrite) {
+			oldest = service
+		}
+	}
+	rc.files[oldest].Close()
+	delete(rc.files, oldest)
+}
+
+// Close 关闭所有录制文件
+func (rc *Recorder) Close() error {
+	if rc == nil {
+		return nil
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var errs []error
+	for service, file := range rc.files {
+		errs = append(errs, file.Close())
+		delete(rc.files, service)
+	}
+	return errors.Join(errs...)
+}
+
+// LimitedBuffer 最多保存 limit 字节的缓冲区, 超出部分丢弃并标记截断, 写入始终成功
+type LimitedBuffer struct {
+	data      []byte
+	limit     int64
+	Truncated bool
+}
+
+func NewLimitedBuffer(limit int64) *LimitedBuffer {
+	return &LimitedBuffer{limit: limit}
+}
+
+func (b *LimitedBuffer) Write(p []byte) (int, error) {
+	remain := b.limit - int64(len(b.data))
+	if int64(len(p)) > remain {
+		b.Truncated = true
+		b.data = append(b.data, p[:max(remain, 0)]...)
+		return len(p), nil
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+func (b *LimitedBuffer) Bytes() []byte {
+	return b.data
+}
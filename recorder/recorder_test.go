@@ -0,0 +1,110 @@
+package recorder
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestRecorderWrite(t *testing.T) {
+	dir := t.TempDir()
+	rc, err := NewRecorder(&config.RecorderConfig{Enabled: true, Dir: dir, Services: []string{"test"}, MaxBodyBytes: 4})
+	if err != nil {
+		t.Fatalf("new recorder error: %v", err)
+	}
+	defer rc.Close()
+
+	assert.Equal(t, true, rc.Sampled("test"))
+	assert.Equal(t, false, rc.Sampled("other"))
+
+	body := NewLimitedBuffer(rc.MaxBodyBytes())
+	body.Write([]byte("hello"))
+	header := http.Header{"Authorization": {"Bearer secret"}, "Content-Type": {"text/plain"}}
+	request := rc.NewMessage(0, header, body)
+	assert.Equal(t, "hell", request.Body)
+	assert.Equal(t, true, request.Truncated)
+	assert.Equal(t, []string{redacted}, request.Headers["Authorization"])
+	assert.Equal(t, "Bearer secret", header.Get("Authorization"))
+
+	binary := NewLimitedBuffer(rc.MaxBodyBytes())
+	binary.Write([]byte{0xff, 0xfe})
+	response := rc.NewMessage(200, http.Header{}, binary)
+	assert.Equal(t, BodyEncodingBase64, response.BodyEncoding)
+	decoded, err := response.BodyBytes()
+	if err != nil {
+		t.Fatalf("decode body error: %v", err)
+	}
+	assert.Equal(t, []byte{0xff, 0xfe}, decoded)
+
+	for range 2 {
+		if err := rc.Write(&Entry{Service: "test", Method: "POST", Path: "/__test/api", Request: request, Response: response}); err != nil {
+			t.Fatalf("write entry error: %v", err)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, "test.jsonl"))
+	if err != nil {
+		t.Fatalf("open record file error: %v", err)
+	}
+	defer f.Close()
+	entries, err := ReadEntries(f)
+	if err != nil {
+		t.Fatalf("read entries error: %v", err)
+	}
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "/__test/api", entries[0].Path)
+	assert.Equal(t, "hell", entries[0].Request.Body)
+
+	var disabled *Recorder
+	assert.Equal(t, false, disabled.Sampled("test"))
+}
+
+func TestReadEntriesInvalidLine(t *testing.T) {
+	_, err := ReadEntries(strings.NewReader("{\"service\":\"test\"}\n\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected line 3 error, got %v", err)
+	}
+}
+
+func TestRecorderMaxOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	rc, err := NewRecorder(&config.RecorderConfig{Enabled: true, Dir: dir})
+	if err != nil {
+		t.Fatalf("new recorder error: %v", err)
+	}
+	defer rc.Close()
+
+	for i := range maxOpenFiles + 1 {
+		if err := rc.Write(&Entry{Service: fmt.Sprintf("svc-%d", i)}); err != nil {
+			t.Fatalf("write entry error: %v", err)
+		}
+	}
+	assert.Equal(t, maxOpenFiles, len(rc.files))
+	if _, ok := rc.files["svc-0"]; ok {
+		t.Fatalf("expected oldest file closed")
+	}
+
+	// 已关闭的文件再次写入时追加到原文件
+	if err := rc.Write(&Entry{Service: "svc-0"}); err != nil {
+		t.Fatalf("write entry error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "svc-0.jsonl"))
+	if err != nil {
+		t.Fatalf("read record file error: %v", err)
+	}
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+}
+
+func TestRecorderRedactQuery(t *testing.T) {
+	rc, err := NewRecorder(&config.RecorderConfig{Enabled: true, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new recorder error: %v", err)
+	}
+	assert.Equal(t, "id=1&token=%5BREDACTED%5D", rc.RedactQuery("id=1&token=secret"))
+}
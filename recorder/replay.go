@@ -0,0 +1,233 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ReplayModeGateway  = "gateway"  // 使用网关请求路径, 目标为网关地址
+	ReplayModeUpstream = "upstream" // 使用转发给上游的路径, 目标为上游地址
+)
+
+// hopHeaders 重放时不发送的请求头
+var hopHeaders = []string{"Connection", "Keep-Alive", "Te", "Trailer", "Transfer-Encoding", "Upgrade", "Content-Length"}
+
+// ReplayOptions 重放配置
+type ReplayOptions struct {
+	Target       string        // 目标地址, 如 http://127.0.0.1:8080
+	Mode         string        // gateway(默认) / upstream
+	Headers      http.Header   // 追加或覆盖的请求头, 用于补充录制时被脱敏的鉴权信息
+	IgnoreFields []string      // 比较 JSON 响应体时忽略的顶层字段, 如 id, timestamp
+	Timeout      time.Duration // 单个请求超时时间
+}
+
+// Result 单条记录的重放结果
+type Result struct {
+	Entry          *Entry
+	Status         int
+	Err            error
+	StatusMismatch bool
+	BodyMismatch   bool
+}
+
+func (r *Result) Passed() bool {
+	return r.Err == nil && !r.StatusMismatch && !r.BodyMismatch
+}
+
+// ReadEntries 读取 JSONL 录制文件, 忽略空行
+func ReadEntries(r io.Reader) ([]*Entry, error) {
+	var entries []*Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		entry := new(Entry)
+		if err := json.Unmarshal(data, entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Replay 重放单条记录并与录制的响应比较状态码与响应体
+// 录制的响应体被截断时只比较前缀
+func Replay(ctx context.Context, client *http.Client, opts *ReplayOptions, entry *Entry) *Result {
+	result := &Result{Entry: entry}
+
+	path := entry.Path
+	if opts.Mode == ReplayModeUpstream {
+		path = entry.UpstreamPath
+	}
+	if path == "" {
+		result.Err = errors.New("record has no path for the replay mode")
+		return result
+	}
+	body, err := entry.Request.BodyBytes()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	req, err := http.NewRequestWithContext(ctx, entry.Method, strings.TrimSuffix(opts.Target, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for name, values := range entry.Request.Headers {
+		if slices.Contains(hopHeaders, http.CanonicalHeaderKey(name)) || slices.Contains(values, redacted) {
+			continue
+		}
+		req.Header[name] = values
+	}
+	for name, values := range opts.Headers {
+		req.Header[name] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	actual, err := io.ReadAll(resp.Body)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Status = resp.StatusCode
+	result.StatusMismatch = resp.StatusCode != entry.Response.Status
+
+	expected, err := entry.Response.BodyBytes()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.BodyMismatch = !bodyEqual(expected, actual, entry.Response.Truncated, opts.IgnoreFields)
+	return result
+}
+
+func bodyEqual(expected, actual []byte, truncated bool, ignoreFields []string) bool {
+	if truncated {
+		return bytes.HasPrefix(actual, expected)
+	}
+
+	var expectedJSON, actualJSON any
+	if json.Unmarshal(expected, &expectedJSON) == nil && json.Unmarshal(actual, &actualJSON) == nil {
+		for _, field := range ignoreFields {
+			if m, ok := expectedJSON.(map[string]any); ok {
+				delete(m, field)
+			}
+			if m, ok := actualJSON.(map[string]any); ok {
+				delete(m, field)
+			}
+		}
+		return reflect.DeepEqual(expectedJSON, actualJSON)
+	}
+	return bytes.Equal(expected, actual)
+}
+
+type headerFlags http.Header
+
+func (h headerFlags) String() string { return "" }
+
+func (h headerFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("invalid header %q, expected Name: value", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	return nil
+}
+
+// RunReplay litegate replay 命令入口, 存在不一致或请求失败时返回非零退出码
+func RunReplay(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	var (
+		opts    = &ReplayOptions{Headers: http.Header{}}
+		file    = fs.String("file", "", "the recorded jsonl file")
+		service = fs.String("service", "", "only replay records of the service")
+		ignore  = fs.String("ignore-fields", "", "comma separated top-level json fields ignored when comparing bodies")
+	)
+	fs.StringVar(&opts.Target, "target", "http://127.0.0.1:8080", "the gateway or upstream address to replay against")
+	fs.StringVar(&opts.Mode, "mode", ReplayModeGateway, "gateway: replay gateway paths, upstream: replay upstream paths")
+	fs.DurationVar(&opts.Timeout, "timeout", 10*time.Second, "timeout of each replayed request")
+	fs.Var(headerFlags(opts.Headers), "H", "extra request header, e.g. -H 'Authorization: Bearer xxx', can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fmt.Fprintln(stdout, "replay: -file is required")
+		return 2
+	}
+	if opts.Mode != ReplayModeGateway && opts.Mode != ReplayModeUpstream {
+		fmt.Fprintf(stdout, "replay: unsupported mode %q\n", opts.Mode)
+		return 2
+	}
+	if *ignore != "" {
+		opts.IgnoreFields = strings.Split(*ignore, ",")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(stdout, "replay: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	entries, err := ReadEntries(f)
+	if err != nil {
+		fmt.Fprintf(stdout, "replay: read %s: %v\n", *file, err)
+		return 1
+	}
+
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		// 重定向作为响应的一部分比较, 不自动跟随
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	var total, failed int
+	for _, entry := range entries {
+		if *service != "" && entry.Service != *service {
+			continue
+		}
+		total++
+
+		result := Replay(context.Background(), client, opts, entry)
+		if result.Passed() {
+			continue
+		}
+		failed++
+		switch {
+		case result.Err != nil:
+			fmt.Fprintf(stdout, "ERROR %s %s: %v\n", entry.Method, entry.Path, result.Err)
+		default:
+			fmt.Fprintf(stdout, "DIFF  %s %s: status %d -> %d, body changed: %t\n",
+				entry.Method, entry.Path, entry.Response.Status, result.Status, result.BodyMismatch)
+		}
+	}
+
+	fmt.Fprintf(stdout, "replayed %d records, %d passed, %d failed\n", total, total-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
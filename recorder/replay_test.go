@@ -0,0 +1,98 @@
+package recorder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"path":   r.URL.RequestURI(),
+			"body":   string(body),
+			"auth":   r.Header.Get("Authorization"),
+			"nonce":  r.Header.Get("X-Nonce"),
+			"status": "ok",
+		})
+	}))
+	defer server.Close()
+
+	entry := &Entry{
+		Service:      "test",
+		Method:       http.MethodPost,
+		Path:         "/__test/api/orders?id=1",
+		UpstreamPath: "/orders?id=1",
+		Request: Message{
+			Headers: http.Header{"Authorization": {redacted}, "X-Nonce": {"a"}, "Content-Length": {"2"}},
+			Body:    "hi",
+		},
+		Response: Message{
+			Status: 200,
+			Body:   `{"path":"/orders?id=1","body":"hi","auth":"Bearer replay","nonce":"a","status":"ok"}`,
+		},
+	}
+
+	// 上游模式使用转发路径, 被脱敏的请求头由 -H 补充
+	opts := &ReplayOptions{Target: server.URL, Mode: ReplayModeUpstream, Headers: http.Header{"Authorization": {"Bearer replay"}}}
+	result := Replay(context.Background(), server.Client(), opts, entry)
+	if !result.Passed() {
+		t.Fatalf("replay should pass: %+v", result)
+	}
+
+	// 网关模式路径不同, 响应体不一致
+	opts.Mode = ReplayModeGateway
+	result = Replay(context.Background(), server.Client(), opts, entry)
+	assert.Equal(t, false, result.StatusMismatch)
+	assert.Equal(t, true, result.BodyMismatch)
+
+	// 忽略变化的字段
+	opts.IgnoreFields = []string{"path"}
+	result = Replay(context.Background(), server.Client(), opts, entry)
+	assert.Equal(t, true, result.Passed())
+}
+
+func TestRunReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/__test/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "test.jsonl")
+	var lines []string
+	for _, entry := range []*Entry{
+		{Service: "test", Method: "GET", Path: "/__test/ok", Response: Message{Status: 200, Body: "ok"}},
+		{Service: "test", Method: "GET", Path: "/__test/missing", Response: Message{Status: 200, Body: "ok"}},
+		{Service: "other", Method: "GET", Path: "/__other/missing", Response: Message{Status: 200, Body: "ok"}},
+	} {
+		b, _ := json.Marshal(entry)
+		lines = append(lines, string(b))
+	}
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatalf("write record file error: %v", err)
+	}
+
+	var out bytes.Buffer
+	code := RunReplay([]string{"-file", file, "-target", server.URL, "-service", "test"}, &out)
+	assert.Equal(t, 1, code)
+	if !strings.Contains(out.String(), "DIFF  GET /__test/missing: status 200 -> 404") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "replayed 2 records, 1 passed, 1 failed") {
+		t.Fatalf("unexpected summary: %s", out.String())
+	}
+}
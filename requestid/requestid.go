@@ -0,0 +1,109 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"github.com/miebyte/goutils/ginutils"
+	"github.com/superwhys/litegate/config"
+)
+
+// maxLength 客户端传入的请求ID最大长度, 超出或包含不可见字符时重新生成
+const maxLength = 128
+
+// crockford ULID 使用的 Crockford Base32 字符表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type requestIDContextKey struct{}
+
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// FromContext 返回请求ID, 未设置时返回空字符串
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// Valid 判断客户端传入的请求ID是否可以沿用
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// New 按生成器类型生成请求ID
+func New(generator string) string {
+	if generator == config.RequestIDGeneratorULID {
+		return NewULID()
+	}
+	return NewUUIDv7()
+}
+
+// NewUUIDv7 生成 RFC 9562 UUIDv7, 前 48 位为毫秒时间戳, 按时间有序
+func NewUUIDv7() string {
+	var b [16]byte
+	rand.Read(b[6:])
+	putTimestamp(b[:6])
+	b[6] = 0x70 | b[6]&0x0f
+	b[8] = 0x80 | b[8]&0x3f
+
+	var out [36]byte
+	hex.Encode(out[0:8], b[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], b[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], b[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], b[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], b[10:])
+	return string(out[:])
+}
+
+// NewULID 生成 26 位 ULID, 前 48 位为毫秒时间戳, 按时间有序
+func NewULID() string {
+	var b [16]byte
+	rand.Read(b[6:])
+	putTimestamp(b[:6])
+
+	// 128 位按 5 位一组编码, 首字符只使用高 3 位
+	var out [26]byte
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+func putTimestamp(b []byte) {
+	ms := uint64(time.Now().UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+// ErrorBody 网关错误响应体, 在通用错误响应的基础上附带请求ID
+type ErrorBody struct {
+	*ginutils.Ret
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrorRet 网关错误响应体, 附带请求ID便于与日志关联
+func ErrorRet(ctx context.Context, code int, message string) *ErrorBody {
+	return &ErrorBody{Ret: ginutils.ErrorRet(code, message), RequestID: FromContext(ctx)}
+}
@@ -0,0 +1,67 @@
+package requestid
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestNewUUIDv7(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first := NewUUIDv7()
+	if !pattern.MatchString(first) {
+		t.Fatalf("invalid uuidv7: %s", first)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	second := NewUUIDv7()
+	if second <= first {
+		t.Fatalf("uuidv7 should be time ordered: %s <= %s", second, first)
+	}
+}
+
+func TestNewULID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	first := NewULID()
+	if !pattern.MatchString(first) {
+		t.Fatalf("invalid ulid: %s", first)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	second := NewULID()
+	if second[:10] <= first[:10] {
+		t.Fatalf("ulid timestamp should increase: %s <= %s", second, first)
+	}
+}
+
+func TestValid(t *testing.T) {
+	assert.Equal(t, true, Valid("req-123"))
+	assert.Equal(t, false, Valid(""))
+	assert.Equal(t, false, Valid("has space"))
+	assert.Equal(t, false, Valid("line\nbreak"))
+	assert.Equal(t, false, Valid(string(make([]byte, maxLength+1))))
+}
+
+func TestErrorRet(t *testing.T) {
+	ctx := WithID(context.Background(), "req-123")
+	b, err := json.Marshal(ErrorRet(ctx, 413, "request body too large"))
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	assert.Equal(t, "req-123", body["request_id"])
+	assert.Equal(t, "request body too large", body["message"])
+
+	b, _ = json.Marshal(ErrorRet(context.Background(), 413, "request body too large"))
+	if regexp.MustCompile(`request_id`).Match(b) {
+		t.Fatalf("request id should be omitted when absent: %s", b)
+	}
+}
@@ -0,0 +1,138 @@
+package split
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"net/http"
+
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/clientip"
+	"github.com/superwhys/litegate/config"
+	"github.com/superwhys/litegate/utils"
+)
+
+// StickyIP 按客户端IP粘性分配
+const StickyIP = "$ip"
+
+// Select 为请求选择上游分组
+// 依次检查 overrides, 未命中时按权重分配: 配置了 sticky 时按加权最高随机权重哈希分配, 同一用户始终落在同一分组,
+// 调整权重或增删分组时只有得分排序发生变化的用户会切换分组, 且只会移入权重调大或新增的分组; 未配置时按权重随机
+func Select(r *http.Request, service string, cfg *config.TrafficSplit) *config.UpstreamGroup {
+	if cfg == nil || len(cfg.Groups) == 0 {
+		return nil
+	}
+
+	for _, override := range cfg.Overrides {
+		value := sourceValue(r, override.Source)
+		if value == "" {
+			continue
+		}
+
+		if override.Value == "" {
+			// 由客户端指定分组名称时, 权重为 0 的分组需要在 groups 中显式允许
+			if group := findGroup(cfg, value); group != nil && override.Allows(group) {
+				return group
+			}
+			continue
+		}
+		if override.Value != value {
+			continue
+		}
+		if group := findGroup(cfg, override.Group); group != nil {
+			return group
+		}
+	}
+
+	total := 0
+	for _, group := range cfg.Groups {
+		total += max(group.Weight, 0)
+	}
+	if total == 0 {
+		return &cfg.Groups[0]
+	}
+
+	if cfg.Sticky != "" {
+		key := sourceValue(r, cfg.Sticky)
+		if key == "" {
+			key = clientip.FromRequest(r)
+		}
+		return rendezvous(cfg, service+"|"+key)
+	}
+
+	point := rand.IntN(total)
+	for i := range cfg.Groups {
+		point -= max(cfg.Groups[i].Weight, 0)
+		if point < 0 {
+			return &cfg.Groups[i]
+		}
+	}
+	return &cfg.Groups[len(cfg.Groups)-1]
+}
+
+// rendezvous 加权最高随机权重哈希, 每个分组的得分为 -weight/ln(u), u 为 key 与分组名称组合后的哈希值映射到 (0,1),
+// 选中得分最高的分组, 各分组被选中的概率与权重成正比, 且不依赖分组顺序与权重总和
+func rendezvous(cfg *config.TrafficSplit, key string) *config.UpstreamGroup {
+	var (
+		best      *config.UpstreamGroup
+		bestScore float64
+	)
+	for i := range cfg.Groups {
+		group := &cfg.Groups[i]
+		if group.Weight <= 0 {
+			continue
+		}
+		u := (float64(mix(hash(key+"|"+group.Name))>>11) + 0.5) / (1 << 53)
+		if score := -float64(group.Weight) / math.Log(u); best == nil || score > bestScore {
+			best, bestScore = group, score
+		}
+	}
+	return best
+}
+
+func findGroup(cfg *config.TrafficSplit, name string) *config.UpstreamGroup {
+	for i := range cfg.Groups {
+		if cfg.Groups[i].Name == name {
+			return &cfg.Groups[i]
+		}
+	}
+	return nil
+}
+
+func sourceValue(r *http.Request, source string) string {
+	if source == StickyIP {
+		return clientip.FromRequest(r)
+	}
+
+	place, name := utils.ParsePlace(source)
+	switch place {
+	case auth.PlaceHeader:
+		return r.Header.Get(name)
+	case auth.PlaceQuery:
+		return r.URL.Query().Get(name)
+	case auth.PlaceCookie:
+		if cookie, err := r.Cookie(name); err == nil {
+			return cookie.Value
+		}
+	case auth.PlaceClaim:
+		value, _ := auth.ClaimsFromContext(r.Context()).String(name)
+		return value
+	}
+	return ""
+}
+
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// mix splitmix64 的终结函数, 打散只有末尾不同的输入的哈希值
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,127 @@
+package split
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/auth"
+	"github.com/superwhys/litegate/config"
+)
+
+func newSplit(stableWeight, canaryWeight int) *config.TrafficSplit {
+	return &config.TrafficSplit{
+		Groups: []config.UpstreamGroup{
+			{Name: "stable", Weight: stableWeight, Proxy: config.ProxyConfig{"http://stable:8080"}},
+			{Name: "canary", Weight: canaryWeight, Proxy: config.ProxyConfig{"http://canary:8080"}},
+		},
+		Overrides: []config.SplitOverride{
+			{Source: "$header.X-Canary", Value: "true", Group: "canary"},
+			{Source: "$cookie.upstream_group"},
+			{Source: "$claim.beta", Value: "true", Group: "canary"},
+		},
+		Sticky: "$claim.sub",
+	}
+}
+
+func TestSelect_Overrides(t *testing.T) {
+	cfg := newSplit(100, 0)
+
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	assert.Equal(t, "stable", Select(req, "test", cfg).Name)
+
+	req.Header.Set("X-Canary", "true")
+	assert.Equal(t, "canary", Select(req, "test", cfg).Name)
+
+	// 客户端指定分组名称时不能进入权重为 0 的分组, 除非显式允许
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.AddCookie(&http.Cookie{Name: "upstream_group", Value: "canary"})
+	assert.Equal(t, "stable", Select(req, "test", cfg).Name)
+	cfg.Overrides[1].Groups = []string{"stable", "canary"}
+	assert.Equal(t, "canary", Select(req, "test", cfg).Name)
+	assert.Equal(t, "canary", Select(req, "test", newSplit(90, 10)).Name)
+
+	// 不存在的分组被忽略
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.AddCookie(&http.Cookie{Name: "upstream_group", Value: "unknown"})
+	assert.Equal(t, "stable", Select(req, "test", cfg).Name)
+
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"beta": true}))
+	assert.Equal(t, "canary", Select(req, "test", cfg).Name)
+}
+
+func TestSelect_Sticky(t *testing.T) {
+	cfg := newSplit(50, 50)
+
+	counts := make(map[string]int)
+	assigned := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": user}))
+
+		group := Select(req, "test", cfg).Name
+		for j := 0; j < 3; j++ {
+			if again := Select(req, "test", cfg).Name; again != group {
+				t.Fatalf("user %s flipped from %s to %s", user, group, again)
+			}
+		}
+		counts[group]++
+		assigned[user] = group
+	}
+	if counts["stable"] < 400 || counts["canary"] < 400 {
+		t.Fatalf("unbalanced assignment: %v", counts)
+	}
+
+	// 扩大 canary 权重时已在 canary 的用户不会切回 stable
+	cfg = newSplit(30, 70)
+	for user, group := range assigned {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": user}))
+		if group == "canary" && Select(req, "test", cfg).Name != "canary" {
+			t.Fatalf("user %s left canary after ramping up", user)
+		}
+	}
+}
+
+func TestSelect_StickyAddGroup(t *testing.T) {
+	cfg := newSplit(50, 50)
+	expanded := newSplit(50, 50)
+	expanded.Groups = append(expanded.Groups, config.UpstreamGroup{Name: "next", Weight: 50, Proxy: config.ProxyConfig{"http://next:8080"}})
+
+	// 新增分组改变了权重总和, 用户只会移入新分组, 不会在原有分组之间切换
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{"sub": fmt.Sprintf("user-%d", i)}))
+
+		before, after := Select(req, "test", cfg).Name, Select(req, "test", expanded).Name
+		if after == "next" {
+			moved++
+		} else if after != before {
+			t.Fatalf("user-%d switched from %s to %s", i, before, after)
+		}
+	}
+	if moved < 250 || moved > 420 {
+		t.Fatalf("unexpected moved share: %d", moved)
+	}
+}
+
+func TestSelect_Weights(t *testing.T) {
+	cfg := newSplit(90, 10)
+	cfg.Sticky = ""
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[Select(httptest.NewRequest(http.MethodGet, "/api", nil), "test", cfg).Name]++
+	}
+	if counts["canary"] < 700 || counts["canary"] > 1300 {
+		t.Fatalf("unexpected canary share: %v", counts)
+	}
+
+	// 权重全部为 0 时使用第一个分组
+	assert.Equal(t, "stable", Select(httptest.NewRequest(http.MethodGet, "/api", nil), "test", newSplit(0, 0)).Name)
+}
@@ -0,0 +1,289 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miebyte/goutils/logging"
+	"github.com/superwhys/litegate/config"
+)
+
+// Exporter 跨度导出器
+type Exporter interface {
+	Export(span SpanData)
+	Shutdown() error
+}
+
+// NewExporter 根据配置创建导出器
+func NewExporter(cfg *config.TracingConfig) (Exporter, error) {
+	switch cfg.Exporter {
+	case config.TracingExporterOTLP:
+		return newOTLPExporter(cfg), nil
+	case config.TracingExporterLog:
+		return logExporter{}, nil
+	case config.TracingExporterMemory:
+		return NewMemoryExporter(), nil
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", cfg.Exporter)
+	}
+}
+
+// MemoryExporter 将跨度保存在内存中, 用于测试
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (e *MemoryExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+func (e *MemoryExporter) Shutdown() error { return nil }
+
+// Spans 按结束顺序返回已导出的跨度
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// logExporter 将跨度输出到日志, 用于本地调试
+type logExporter struct{}
+
+func (logExporter) Export(span SpanData) {
+	logging.Infof("trace span: %s", logging.JsonifyNoIndent(otlpSpanFromData(span)))
+}
+
+func (logExporter) Shutdown() error { return nil }
+
+// otlpExporter 以 OTLP/HTTP JSON 格式批量上报跨度
+type otlpExporter struct {
+	endpoint    string
+	headers     map[string]string
+	serviceName string
+	batchSize   int
+	client      *http.Client
+
+	mu      sync.Mutex
+	pending []SpanData
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+func newOTLPExporter(cfg *config.TracingConfig) *otlpExporter {
+	e := &otlpExporter{
+		endpoint:    cfg.Endpoint,
+		headers:     cfg.Headers,
+		serviceName: cfg.ServiceName,
+		batchSize:   cfg.BatchSize,
+		client:      &http.Client{Timeout: cfg.ExportTimeout},
+		flushCh:     make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+	go e.loop(cfg.FlushInterval)
+	return e
+}
+
+// Export 将跨度加入待上报队列, 队列积压超过批量大小的 4 倍时丢弃, 避免收集器不可用时占用过多内存
+func (e *otlpExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.pending) >= e.batchSize*4 {
+		return
+	}
+	e.pending = append(e.pending, span)
+	if len(e.pending) >= e.batchSize {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *otlpExporter) Shutdown() error {
+	close(e.stopCh)
+	<-e.doneCh
+	return nil
+}
+
+func (e *otlpExporter) loop(interval time.Duration) {
+	defer close(e.doneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-e.flushCh:
+			e.flush()
+		case <-e.stopCh:
+			e.flush()
+			return
+		}
+	}
+}
+
+func (e *otlpExporter) flush() {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	for len(spans) > 0 {
+		n := min(len(spans), e.batchSize)
+		if err := e.send(spans[:n]); err != nil {
+			logging.Errorf("export trace spans error: %v", err)
+		}
+		spans = spans[n:]
+	}
+}
+
+func (e *otlpExporter) send(spans []SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpExporter) request(spans []SpanData) otlpRequest {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, otlpSpanFromData(span))
+	}
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{otlpAttributeFrom("service.name", e.serviceName)},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "litegate"},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+// OTLP/HTTP JSON 编码, trace id 与 span id 使用十六进制字符串, 64 位整数使用字符串
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpSpanFromData(span SpanData) otlpSpan {
+	s := otlpSpan{
+		TraceID:           span.SpanContext.TraceID.String(),
+		SpanID:            span.SpanContext.SpanID.String(),
+		TraceState:        span.SpanContext.TraceState,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+	}
+	if span.ParentSpanID.IsValid() {
+		s.ParentSpanID = span.ParentSpanID.String()
+	}
+	for key, value := range span.Attributes {
+		s.Attributes = append(s.Attributes, otlpAttributeFrom(key, value))
+	}
+	return s
+}
+
+func otlpAttributeFrom(key string, value any) otlpAttribute {
+	var v map[string]any
+	switch value := value.(type) {
+	case string:
+		v = map[string]any{"stringValue": value}
+	case bool:
+		v = map[string]any{"boolValue": value}
+	case int:
+		v = map[string]any{"intValue": strconv.Itoa(value)}
+	case int64:
+		v = map[string]any{"intValue": strconv.FormatInt(value, 10)}
+	case float64:
+		v = map[string]any{"doubleValue": value}
+	default:
+		v = map[string]any{"stringValue": fmt.Sprint(value)}
+	}
+	return otlpAttribute{Key: key, Value: v}
+}
@@ -0,0 +1,165 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	PropagatorTraceContext = "tracecontext" // W3C traceparent / tracestate
+	PropagatorB3           = "b3"           // B3 单请求头, 提取时同时支持多请求头
+	PropagatorB3Multi      = "b3multi"      // B3 多请求头 X-B3-*
+
+	HeaderTraceParent = "Traceparent"
+	HeaderTraceState  = "Tracestate"
+	HeaderB3          = "B3"
+	HeaderB3TraceID   = "X-B3-TraceId"
+	HeaderB3SpanID    = "X-B3-SpanId"
+	HeaderB3ParentID  = "X-B3-ParentSpanId"
+	HeaderB3Sampled   = "X-B3-Sampled"
+	HeaderB3Flags     = "X-B3-Flags"
+)
+
+// propagationHeaders 网关注入链路上下文前移除的客户端请求头
+var propagationHeaders = []string{
+	HeaderTraceParent,
+	HeaderTraceState,
+	HeaderB3,
+	HeaderB3TraceID,
+	HeaderB3SpanID,
+	HeaderB3ParentID,
+	HeaderB3Sampled,
+	HeaderB3Flags,
+}
+
+// extract 按配置的传播格式顺序提取链路上下文, 取第一个有效的结果
+func (t *Tracer) extract(header http.Header) (SpanContext, bool) {
+	for _, propagator := range t.propagators {
+		var (
+			sc SpanContext
+			ok bool
+		)
+		switch propagator {
+		case PropagatorTraceContext:
+			sc, ok = extractTraceContext(header)
+		case PropagatorB3, PropagatorB3Multi:
+			sc, ok = extractB3(header)
+		}
+		if ok {
+			return sc, true
+		}
+	}
+	return SpanContext{}, false
+}
+
+// Inject 将上下文中跨度的链路上下文按配置的传播格式写入请求头
+// 未启用链路追踪时不修改请求头, 客户端传入的链路请求头原样转发
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	for _, name := range propagationHeaders {
+		header.Del(name)
+	}
+
+	sc := span.spanContext
+	for _, propagator := range span.tracer.propagators {
+		switch propagator {
+		case PropagatorTraceContext:
+			injectTraceContext(sc, header)
+		case PropagatorB3:
+			header.Set(HeaderB3, sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+b3Sampled(sc.Sampled))
+		case PropagatorB3Multi:
+			header.Set(HeaderB3TraceID, sc.TraceID.String())
+			header.Set(HeaderB3SpanID, sc.SpanID.String())
+			header.Set(HeaderB3Sampled, b3Sampled(sc.Sampled))
+		}
+	}
+}
+
+func injectTraceContext(sc SpanContext, header http.Header) {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	header.Set(HeaderTraceParent, "00-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+flags)
+	if sc.TraceState != "" {
+		header.Set(HeaderTraceState, sc.TraceState)
+	}
+}
+
+// extractTraceContext 解析 traceparent: {version}-{trace-id}-{parent-id}-{trace-flags}
+func extractTraceContext(header http.Header) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header.Get(HeaderTraceParent)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	// 版本 00 只允许 4 段, 更高版本向后兼容忽略多余字段
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if !decodeHex(parts[1], sc.TraceID[:]) || !decodeHex(parts[2], sc.SpanID[:]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	var flags [1]byte
+	if !decodeHex(parts[3], flags[:]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	sc.TraceState = strings.Join(header.Values(HeaderTraceState), ",")
+	return sc, true
+}
+
+// extractB3 解析 b3: {trace-id}-{span-id}-{sampled}-{parent-id}, 不存在时解析 X-B3-* 请求头
+func extractB3(header http.Header) (SpanContext, bool) {
+	if single := strings.TrimSpace(header.Get(HeaderB3)); single != "" {
+		parts := strings.Split(single, "-")
+		if len(parts) < 2 {
+			// 仅包含采样标记, 如 b3: 0
+			return SpanContext{}, false
+		}
+		sampled := ""
+		if len(parts) > 2 {
+			sampled = parts[2]
+		}
+		return parseB3(parts[0], parts[1], sampled, "")
+	}
+
+	return parseB3(header.Get(HeaderB3TraceID), header.Get(HeaderB3SpanID), header.Get(HeaderB3Sampled), header.Get(HeaderB3Flags))
+}
+
+func parseB3(traceID, spanID, sampled, flags string) (SpanContext, bool) {
+	var sc SpanContext
+	// 64 位的 trace id 高位补零
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if !decodeHex(traceID, sc.TraceID[:]) || !decodeHex(spanID, sc.SpanID[:]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	// 未携带采样标记时默认采样, debug 标记(b3 的 d 或 X-B3-Flags: 1)强制采样
+	sc.Sampled = sampled != "0" && sampled != "false" || flags == "1"
+	return sc, true
+}
+
+func b3Sampled(sampled bool) string {
+	if sampled {
+		return "1"
+	}
+	return "0"
+}
+
+// decodeHex 解析固定长度的小写十六进制字符串
+func decodeHex(s string, dst []byte) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
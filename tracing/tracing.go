@@ -0,0 +1,286 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/superwhys/litegate/config"
+)
+
+// TraceID 16 字节的链路ID
+type TraceID [16]byte
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID 8 字节的跨度ID
+type SpanID [8]byte
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext 在进程间传播的链路上下文
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string // W3C tracestate, 原样传递
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind 与 OTLP 定义一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode 与 OTLP 定义一致
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// SpanData 结束后交给导出器的跨度快照
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    map[string]any
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Tracer 创建跨度并在跨度结束时导出, 为 nil 时表示未启用链路追踪
+type Tracer struct {
+	exporter    Exporter
+	sampleRatio float64
+	propagators []string
+}
+
+// NewTracer 根据配置创建 Tracer, 未配置导出器时返回 nil
+func NewTracer(cfg *config.TracingConfig) (*Tracer, error) {
+	if cfg == nil || cfg.Exporter == "" || cfg.Exporter == config.TracingExporterNone {
+		return nil, nil
+	}
+	cfg.SetDefault()
+	if ratio := *cfg.SampleRatio; ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("invalid tracing sample ratio: %v", ratio)
+	}
+
+	exporter, err := NewExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewTracerWithExporter(exporter, *cfg.SampleRatio, cfg.Propagators), nil
+}
+
+// NewTracerWithExporter 使用指定导出器创建 Tracer, 便于测试时使用内存导出器
+func NewTracerWithExporter(exporter Exporter, sampleRatio float64, propagators []string) *Tracer {
+	if len(propagators) == 0 {
+		propagators = []string{PropagatorTraceContext, PropagatorB3}
+	}
+	return &Tracer{
+		exporter:    exporter,
+		sampleRatio: sampleRatio,
+		propagators: propagators,
+	}
+}
+
+// Shutdown 导出剩余的跨度并关闭导出器
+func (t *Tracer) Shutdown() error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.Shutdown()
+}
+
+// StartServer 从请求头中提取上游链路上下文并创建服务端跨度
+// 请求头携带采样标记时沿用其采样决定, 否则按采样率采样
+func (t *Tracer) StartServer(ctx context.Context, header http.Header, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	parent, ok := t.extract(header)
+	sc := SpanContext{SpanID: newSpanID()}
+	var parentID SpanID
+	if ok {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+		sc.TraceState = parent.TraceState
+		parentID = parent.SpanID
+	} else {
+		sc.TraceID = newTraceID()
+		sc.Sampled = t.sampleRatio >= 1 || rand.Float64() < t.sampleRatio
+	}
+
+	span := t.newSpan(name, SpanKindServer, sc, parentID)
+	return ContextWithSpan(ctx, span), span
+}
+
+// Start 创建当前跨度的子跨度, 上下文中没有跨度时返回 nil
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	sc := parent.spanContext
+	sc.SpanID = newSpanID()
+	span := parent.tracer.newSpan(name, kind, sc, parent.spanContext.SpanID)
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) newSpan(name string, kind SpanKind, sc SpanContext, parentID SpanID) *Span {
+	return &Span{
+		tracer:      t,
+		name:        name,
+		kind:        kind,
+		spanContext: sc,
+		parentID:    parentID,
+		start:       time.Now(),
+		attributes:  make(map[string]any),
+	}
+}
+
+// Span 一次操作的跨度, 方法均可在 nil 上调用
+type Span struct {
+	tracer      *Tracer
+	spanContext SpanContext
+	parentID    SpanID
+	kind        SpanKind
+	start       time.Time
+
+	mu            sync.Mutex
+	name          string
+	attributes    map[string]any
+	status        StatusCode
+	statusMessage string
+	ended         bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.spanContext
+}
+
+// SetName 修改跨度名称, 如路由匹配后将服务端跨度命名为 "GET /users/{id}"
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 结束后属性已交给导出器, 不再修改
+	if !s.ended {
+		s.attributes[key] = value
+	}
+}
+
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status = code
+	s.statusMessage = message
+	s.mu.Unlock()
+}
+
+// RecordError 将跨度标记为失败
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetAttribute("exception.message", err.Error())
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End 结束跨度, 已采样的跨度交给导出器, 重复调用无效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:          s.name,
+		Kind:          s.kind,
+		SpanContext:   s.spanContext,
+		ParentSpanID:  s.parentID,
+		StartTime:     s.start,
+		EndTime:       time.Now(),
+		Attributes:    s.attributes,
+		Status:        s.status,
+		StatusMessage: s.statusMessage,
+	}
+	s.mu.Unlock()
+
+	if s.spanContext.Sampled {
+		s.tracer.exporter.Export(data)
+	}
+}
+
+type spanKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
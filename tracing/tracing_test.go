@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/superwhys/litegate/config"
+)
+
+func TestExtractTraceContext(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(HeaderTraceState, "congo=t61rcWkgMzE")
+
+	sc, ok := extractTraceContext(header)
+	if !ok {
+		t.Fatalf("traceparent should be valid")
+	}
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.Equal(t, true, sc.Sampled)
+	assert.Equal(t, "congo=t61rcWkgMzE", sc.TraceState)
+
+	for _, value := range []string{
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"garbage",
+	} {
+		header.Set(HeaderTraceParent, value)
+		if _, ok := extractTraceContext(header); ok {
+			t.Fatalf("traceparent %q should be invalid", value)
+		}
+	}
+}
+
+func TestExtractB3(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderB3, "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0")
+	sc, ok := extractB3(header)
+	if !ok {
+		t.Fatalf("b3 should be valid")
+	}
+	assert.Equal(t, "80f198ee56343ba864fe8b2a57d3eff7", sc.TraceID.String())
+	assert.Equal(t, "e457b5a2e4d86bd1", sc.SpanID.String())
+	assert.Equal(t, false, sc.Sampled)
+
+	// 多请求头, 64 位 trace id
+	header = http.Header{}
+	header.Set(HeaderB3TraceID, "64fe8b2a57d3eff7")
+	header.Set(HeaderB3SpanID, "e457b5a2e4d86bd1")
+	header.Set(HeaderB3Sampled, "1")
+	sc, ok = extractB3(header)
+	if !ok {
+		t.Fatalf("b3 multi should be valid")
+	}
+	assert.Equal(t, "000000000000000064fe8b2a57d3eff7", sc.TraceID.String())
+	assert.Equal(t, true, sc.Sampled)
+
+	header = http.Header{}
+	header.Set(HeaderB3, "0")
+	if _, ok := extractB3(header); ok {
+		t.Fatalf("sampling only b3 should not produce a span context")
+	}
+}
+
+func TestSpansAndInject(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracerWithExporter(exporter, 1, []string{PropagatorTraceContext, PropagatorB3Multi})
+
+	incoming := http.Header{}
+	incoming.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, server := tracer.StartServer(context.Background(), incoming, "GET")
+
+	upstreamCtx, upstream := Start(ctx, "upstream", SpanKindClient)
+	outgoing := http.Header{}
+	outgoing.Set(HeaderB3, "spoofed")
+	Inject(upstreamCtx, outgoing)
+	upstream.End()
+	server.SetName("GET /api")
+	server.End()
+	// 重复结束不会重复导出
+	server.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	assert.Equal(t, "upstream", spans[0].Name)
+	assert.Equal(t, "GET /api", spans[1].Name)
+	assert.Equal(t, "00f067aa0ba902b7", spans[1].ParentSpanID.String())
+	assert.Equal(t, spans[1].SpanContext.SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, spans[1].SpanContext.TraceID, spans[0].SpanContext.TraceID)
+
+	upstreamID := upstream.SpanContext().SpanID.String()
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+upstreamID+"-01", outgoing.Get(HeaderTraceParent))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", outgoing.Get(HeaderB3TraceID))
+	assert.Equal(t, upstreamID, outgoing.Get(HeaderB3SpanID))
+	assert.Equal(t, "", outgoing.Get(HeaderB3))
+}
+
+func TestSampling(t *testing.T) {
+	exporter := NewMemoryExporter()
+	tracer := NewTracerWithExporter(exporter, 0.000001, nil)
+
+	// 上游已采样时沿用其决定
+	incoming := http.Header{}
+	incoming.Set(HeaderB3, "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1")
+	_, span := tracer.StartServer(context.Background(), incoming, "GET")
+	span.End()
+	assert.Equal(t, 1, len(exporter.Spans()))
+
+	// 未采样的跨度仍然传播链路上下文, 但不会导出
+	incoming.Set(HeaderB3, "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0")
+	ctx, span := tracer.StartServer(context.Background(), incoming, "GET")
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	span.End()
+	assert.Equal(t, 1, len(exporter.Spans()))
+	assert.Equal(t, "00-80f198ee56343ba864fe8b2a57d3eff7-"+span.SpanContext().SpanID.String()+"-00", outgoing.Get(HeaderTraceParent))
+
+	// 未启用时不创建跨度, 也不修改请求头
+	var disabled *Tracer
+	ctx, span = disabled.StartServer(context.Background(), incoming, "GET")
+	span.SetAttribute("k", "v")
+	span.End()
+	outgoing = http.Header{}
+	Inject(ctx, outgoing)
+	assert.Equal(t, 0, len(outgoing))
+}
+
+func TestNewTracer_SampleRatio(t *testing.T) {
+	// 未配置时默认全部采样, 显式配置为 0 时只沿用客户端的采样决定
+	tracer, err := NewTracer(&config.TracingConfig{Exporter: config.TracingExporterMemory})
+	if err != nil {
+		t.Fatalf("new tracer error: %v", err)
+	}
+	assert.Equal(t, 1.0, tracer.sampleRatio)
+
+	zero := 0.0
+	tracer, err = NewTracer(&config.TracingConfig{Exporter: config.TracingExporterMemory, SampleRatio: &zero})
+	if err != nil {
+		t.Fatalf("new tracer error: %v", err)
+	}
+	assert.Equal(t, 0.0, tracer.sampleRatio)
+	_, span := tracer.StartServer(context.Background(), http.Header{}, "GET")
+	assert.Equal(t, false, span.SpanContext().Sampled)
+	assert.Equal(t, nil, tracer.Shutdown())
+
+	invalid := 1.5
+	if _, err := NewTracer(&config.TracingConfig{Exporter: config.TracingExporterMemory, SampleRatio: &invalid}); err == nil {
+		t.Fatalf("expected invalid sample ratio rejected")
+	}
+}
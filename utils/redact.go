@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RedactQuery 将查询参数中的敏感参数值替换为 replacement, 参数名不区分大小写, 保留其余参数的原始编码与顺序
+func RedactQuery(rawQuery string, names []string, replacement string) string {
+	if rawQuery == "" || len(names) == 0 {
+		return rawQuery
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil {
+			key = name
+		}
+		for _, sensitive := range names {
+			if strings.EqualFold(sensitive, key) {
+				pairs[i] = url.QueryEscape(key) + "=" + url.QueryEscape(replacement)
+				break
+			}
+		}
+	}
+	return strings.Join(pairs, "&")
+}
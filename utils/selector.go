@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Select 按照 a.b.0.c 形式的路径从 JSON 解码后的数据中取值
+func Select(data any, path string) (any, bool) {
+	current := data
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, current != nil
+}